@@ -25,15 +25,14 @@ func main() {
 	if os.Getenv("MONGO_URI") == "" {
 		log.Fatal("MONGO_URI environment variable is required")
 	}
-	if os.Getenv("JWT_SECRET") == "" {
-		log.Fatal("JWT_SECRET environment variable is required")
-	}
 	if os.Getenv("REDIS_HOST") == "" {
 		log.Fatal("REDIS_HOST environment variable is required")
 	}
 
 	config.InitFirebase()
 	config.InitRedis()
+	config.InitMailer()
+	config.InitJWT()
 
 	if err := app.Run(); err != nil {
 		log.Fatal("Error In Running the App")
@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
 	"time"
 
 	"github.com/ndk123-web/fast-todo/internal/config"
 	"github.com/ndk123-web/fast-todo/internal/handler"
+	"github.com/ndk123-web/fast-todo/internal/identity"
+	"github.com/ndk123-web/fast-todo/internal/middleware"
 	"github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/internal/search"
 	"github.com/ndk123-web/fast-todo/internal/server"
 	"github.com/ndk123-web/fast-todo/internal/service"
 	"go.mongodb.org/mongo-driver/bson"
@@ -43,12 +47,16 @@ func Run() error {
 	userCollection := client.Database("golangdb").Collection("users")
 	goalCollection := client.Database("golangdb").Collection("goals")
 	workspaceCollection := client.Database("golangdb").Collection("workspaces")
+	accessTokenCollection := client.Database("golangdb").Collection("access_tokens")
+	lockoutCollection := client.Database("golangdb").Collection("lockout_events")
+	auditCollection := client.Database("golangdb").Collection("audit_events")
+	labelCollection := client.Database("golangdb").Collection("labels")
 
 	// Create Indexes on Collections
 	wsModel := mongo.IndexModel{
 		Keys: bson.D{
-			{"userId", 1},
-			{"workspaceName", 1},
+			{Key: "userId", Value: 1},
+			{Key: "workspaceName", Value: 1},
 		},
 		Options: options.Index().SetUnique(true), // prevents duplicates
 	}
@@ -56,47 +64,283 @@ func Run() error {
 
 	userMode := mongo.IndexModel{
 		Keys: bson.D{
-			{"email", 1},
+			{Key: "email", Value: 1},
 		},
 		Options: options.Index().SetUnique(true), // prevents duplicates
 	}
 	userCollection.Indexes().CreateOne(ctx, userMode)
 
-	todoModel := mongo.IndexModel{
+	// one Google identity links to at most one user, users without one aren't indexed
+	googleUidModel := mongo.IndexModel{
 		Keys: bson.D{
-			{"userId", 1},
-			{"workspaceId", 1},
+			{Key: "googleUid", Value: 1},
 		},
+		Options: options.Index().SetUnique(true).SetSparse(true),
 	}
-	todoCollection.Indexes().CreateOne(ctx, todoModel)
+	userCollection.Indexes().CreateOne(ctx, googleUidModel)
+
+	// same for the identities of the other providers
+	identitiesModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "identities.provider", Value: 1},
+			{Key: "identities.subject", Value: 1},
+		},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"identities.subject": bson.M{"$exists": true}}),
+	}
+	userCollection.Indexes().CreateOne(ctx, identitiesModel)
+
+	// the account purge looks for users past their grace period, only scheduled ones are indexed
+	deletionModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "deletionScheduledAt", Value: 1},
+		},
+		Options: options.Index().SetSparse(true),
+	}
+	userCollection.Indexes().CreateOne(ctx, deletionModel)
+
+	// one index per sort key of the workspace listing, _id last for the cursor tie break
+	// the dueAt one also serves the due date views
+	todoModels := []mongo.IndexModel{}
+	for _, sortKey := range []string{"dueAt", "createdAt", "updatedAt", "task", "rank"} {
+		todoModels = append(todoModels, mongo.IndexModel{
+			Keys: bson.D{
+				{Key: "userId", Value: 1},
+				{Key: "workspaceId", Value: 1},
+				{Key: sortKey, Value: 1},
+				{Key: "_id", Value: 1},
+			},
+		})
+	}
+	todoCollection.Indexes().CreateMany(ctx, todoModels)
+
+	// instances of a repeating todo are updated together, plain todos aren't indexed
+	seriesModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "recurrence.seriesId", Value: 1},
+			{Key: "recurrence.occurrence", Value: 1},
+		},
+		Options: options.Index().SetPartialFilterExpression(bson.M{"recurrence.seriesId": bson.M{"$exists": true}}),
+	}
+	todoCollection.Indexes().CreateOne(ctx, seriesModel)
+
+	// labels are filtered with $in / $all, multikey index next to the workspace
+	todoLabelModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "userId", Value: 1},
+			{Key: "workspaceId", Value: 1},
+			{Key: "labels", Value: 1},
+		},
+	}
+	todoCollection.Indexes().CreateOne(ctx, todoLabelModel)
+
+	// a label name is unique within its workspace, the catalog is read in order
+	labelModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "workspaceId", Value: 1},
+			{Key: "name", Value: 1},
+		},
+		Options: options.Index().SetUnique(true),
+	}
+	labelCollection.Indexes().CreateOne(ctx, labelModel)
+
+	// text indexes behind the search (a collection can only have one), the weights
+	// match the fields of search.GoalDocument
+	todoTextModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "task", Value: "text"},
+		},
+	}
+	todoCollection.Indexes().CreateOne(ctx, todoTextModel)
+
+	goalTextModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "title", Value: "text"},
+			{Key: "category", Value: "text"},
+		},
+		Options: options.Index().SetWeights(bson.D{{Key: "title", Value: 2}, {Key: "category", Value: 1}}),
+	}
+	goalCollection.Indexes().CreateOne(ctx, goalTextModel)
+
+	workspaceTextModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "workspaceName", Value: "text"},
+		},
+	}
+	workspaceCollection.Indexes().CreateOne(ctx, workspaceTextModel)
+
+	// manual ordering is per status column, moves look up the neighbours of a rank
+	columnModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "userId", Value: 1},
+			{Key: "workspaceId", Value: 1},
+			{Key: "status", Value: 1},
+			{Key: "rank", Value: 1},
+			{Key: "_id", Value: 1},
+		},
+	}
+	todoCollection.Indexes().CreateOne(ctx, columnModel)
+
+	// subtasks are looked up / counted by parent
+	subtaskModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "parentId", Value: 1},
+		},
+		Options: options.Index().SetPartialFilterExpression(bson.M{"parentId": bson.M{"$exists": true}}),
+	}
+	todoCollection.Indexes().CreateOne(ctx, subtaskModel)
 
 	// 
 	goalModel := mongo.IndexModel{
 		Keys: bson.D{
-			{"userId", 1},
-			{"workspaceId", 1},
+			{Key: "userId", Value: 1},
+			{Key: "workspaceId", Value: 1},
 		},
 	}
 	goalCollection.Indexes().CreateOne(ctx, goalModel)
 
+	// tokens are looked up by hash on every request, expired ones are dropped by mongo
+	accessTokenCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tokenHash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}},
+		},
+		{
+			Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+
+	lockoutModel := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "email", Value: 1},
+			{Key: "createdAt", Value: -1},
+		},
+	}
+	lockoutCollection.Indexes().CreateOne(ctx, lockoutModel)
+
+	// audit trails are read per user and per target ("who deleted this workspace"), newest first
+	auditCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "targetId", Value: 1}, {Key: "createdAt", Value: -1}},
+		},
+		{
+			Keys: bson.D{{Key: "createdAt", Value: -1}},
+		},
+	})
+
+	// every service writes its security / data events to the audit log
+	auditRepo := repository.NewAuditRepository(auditCollection)
+	auditService := service.NewAuditService(auditRepo)
+	auditHandler := handler.NewAuditHandler(auditService)
+
+	// workspace repo is shared, todo and goal services use it for ownership checks
+	workspaceRepo := repository.NewWorkspaceRepository(workspaceCollection)
+
 	// todorepos
 	todoRepo := repository.NewTodoRepository(todoCollection)
-	todoService := service.NewTodoService(todoRepo)
+	// todos from before the status workflow only have done, each one is migrated once
+	// transitions and the open-todo filters need a status on every todo, so no serving without it,
+	// on its own context as a large collection takes longer than the startup timeout
+	migrated, err := todoRepo.MigrateStatus(context.Background())
+	if err != nil {
+		return fmt.Errorf("todo status migration failed: %v", err)
+	}
+	if migrated > 0 {
+		log.Printf("todo status migration: %d todos migrated", migrated)
+	}
+	labelRepo := repository.NewLabelRepository(labelCollection)
+	// transactions need a replica set, without one a batch / move runs its steps one by one
+	transactor := repository.NewTransactor(ctx, client)
+	todoService := service.NewTodoService(todoRepo, workspaceRepo, labelRepo, auditService, transactor)
 	todoHandler := handler.NewTodoHandler(todoService)
 
+	labelService := service.NewLabelService(labelRepo, todoRepo, workspaceRepo, auditService)
+	labelHandler := handler.NewLabelHandler(labelService)
+
+	searchService := service.NewSearchService(search.NewMongoIndex(todoCollection, goalCollection, workspaceCollection), workspaceRepo)
+	searchHandler := handler.NewSearchHandler(searchService)
+
+	// sessions live in redis, AuthMiddleware checks them on every request
+	sessionRepo := repository.NewSessionRepository(config.RedisClient)
+	middleware.InitAuth(sessionRepo)
+
+	// allowed browser origins and the security headers of this environment
+	middleware.InitCors(middleware.CorsOptions{
+		AllowedOrigins:   cfg.CorsAllowedOrigins,
+		AllowedMethods:   cfg.CorsAllowedMethods,
+		AllowedHeaders:   cfg.CorsAllowedHeaders,
+		ExposedHeaders:   cfg.CorsExposedHeaders,
+		AllowCredentials: cfg.CorsAllowCredentials,
+		MaxAge:           cfg.CorsMaxAge,
+	})
+	middleware.InitSecurityHeaders(cfg.SecurityProfile, cfg.ContentSecurityPolicy)
+
 	// userrepos
 	userRepo := repository.NewUserRepository(todoCollection, userCollection)
-	userService := service.NewUserService(userRepo)
-	userHandler := handler.NewUserHandler(userService)
+	tokenRepo := repository.NewOneTimeTokenRepository(config.RedisClient)
+	rateLimitRepo := repository.NewRateLimitRepository(config.RedisClient)
+	middleware.InitEmailVerification(userRepo, cfg.RequireVerifiedEmail)
+	middleware.InitRoles(userRepo)
+	// failed password sign ins are counted per email / ip in redis, lockouts are kept in mongo
+	loginAttemptRepo := repository.NewLoginAttemptRepository(config.RedisClient)
+	lockoutRepo := repository.NewLockoutRepository(lockoutCollection)
+	loginGuard := service.NewLoginGuard(loginAttemptRepo, lockoutRepo, userRepo, config.Mailer)
+	userService := service.NewUserService(userRepo, sessionRepo, tokenRepo, rateLimitRepo, config.Mailer, loginGuard, auditService)
+	userHandler := handler.NewUserHandler(userService, identityProviders(cfg))
 
 	goalRepo := repository.NewGoalRepository(goalCollection)
-	goalService := service.NewGoalService(goalRepo)
+	goalService := service.NewGoalService(goalRepo, workspaceRepo, auditService)
 	goalHandler := handler.NewGoalHandler(goalService)
 
-	workspaceRepo := repository.NewWorkspaceRepository(workspaceCollection)
-	workspaceService := service.NewWorkSpaceService(workspaceRepo)
+	workspaceService := service.NewWorkSpaceService(workspaceRepo, auditService)
 	workspaceHandler := handler.NewWorkspaceHandler(workspaceService)
 
-	srv := server.NewServer(todoHandler, userHandler, goalHandler, workspaceHandler)
+	// offline replays, in one transaction when mongo runs as a replica set (Atlas does)
+	batchService := service.NewBatchService(todoService, goalService, workspaceService, transactor)
+	batchHandler := handler.NewBatchHandler(batchService)
+
+	sessionService := service.NewSessionService(sessionRepo)
+	sessionHandler := handler.NewSessionHandler(sessionService)
+
+	twoFactorService := service.NewTwoFactorService(userRepo, sessionRepo, rateLimitRepo, auditService)
+	twoFactorHandler := handler.NewTwoFactorHandler(twoFactorService)
+
+	// personal access tokens, AuthMiddleware accepts them next to JWTs
+	accessTokenRepo := repository.NewAccessTokenRepository(accessTokenCollection)
+	accessTokenService := service.NewAccessTokenService(accessTokenRepo, userRepo)
+	middleware.InitAccessTokens(accessTokenService)
+	accessTokenHandler := handler.NewAccessTokenHandler(accessTokenService)
+
+	// account deletion, the purge drops everything the user owns once the grace period is over
+	accountDataRepo := repository.NewAccountDataRepository(todoCollection, goalCollection, workspaceCollection, accessTokenCollection, labelCollection)
+	analyticsRepo := repository.NewAnalyticsCacheRepository(config.RedisClient)
+	accountService := service.NewAccountService(userRepo, sessionRepo, accessTokenRepo, lockoutRepo, loginAttemptRepo, auditRepo, accountDataRepo, analyticsRepo, config.Mailer, cfg.AccountDeletionGrace)
+	accountHandler := handler.NewAccountHandler(accountService)
+	service.StartAccountPurge(context.Background(), accountService, cfg.AccountPurgeInterval)
+
+	// admin API, ADMIN_EMAILS are promoted here so a fresh deploy has an admin
+	adminService := service.NewAdminService(userRepo, sessionRepo, accessTokenRepo, accountDataRepo, todoRepo, userService, loginGuard)
+	adminService.EnsureAdmins(ctx, cfg.AdminEmails)
+	adminHandler := handler.NewAdminHandler(adminService)
+
+	srv := server.NewServer(todoHandler, userHandler, goalHandler, workspaceHandler, sessionHandler, twoFactorHandler, accessTokenHandler, accountHandler, adminHandler, auditHandler, labelHandler, searchHandler, batchHandler)
 	return srv.Start(cfg.Port)
 }
+
+// identityProviders are the external sign in providers, Google when Firebase is set up and every OIDC_PROVIDERS entry
+func identityProviders(cfg *config.Config) *identity.Registry {
+	var providers []identity.Provider
+	if config.FirebaseAuth != nil {
+		providers = append(providers, identity.NewFirebaseProvider(config.FirebaseAuth))
+	}
+	for _, oidc := range cfg.OIDCProviders {
+		providers = append(providers, identity.NewOIDCProvider(oidc.Name, oidc.Issuer, oidc.ClientID, &http.Client{Timeout: 10 * time.Second}))
+	}
+	return identity.NewRegistry(providers...)
+}
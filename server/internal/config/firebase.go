@@ -4,21 +4,20 @@ import (
 	"context"
 	"log"
 	"os"
-	"path/filepath"
 
 	firebase "firebase.google.com/go"
 	"firebase.google.com/go/auth"
 	"google.golang.org/api/option"
 )
 
+// FirebaseAuth stays nil when FIREBASE_CREDENTIALS_PATH isn't set, Google sign in is off then
 var FirebaseAuth *auth.Client
 
 func InitFirebase() {
-	// Use env var for credentials path; fallback to local file in config directory.
 	credPath := os.Getenv("FIREBASE_CREDENTIALS_PATH")
 	if credPath == "" {
-		// Attempt common filename in current folder.
-		credPath = filepath.Join("./internal/config", "taskplexus-firebase-adminsdk-fbsvc-69245eb849.json")
+		log.Println("FIREBASE_CREDENTIALS_PATH not set, Google sign in is disabled")
+		return
 	}
 	opt := option.WithCredentialsFile(credPath)
 
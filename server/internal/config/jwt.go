@@ -0,0 +1,63 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ndk123-web/fast-todo/pkg/njwt"
+)
+
+// InitJWT loads the token signing keys, it must run after the .env file is loaded
+// JWT_PRIVATE_KEY_FILE is the current RSA (2048+ bits) or Ed25519 PEM key
+// JWT_PREVIOUS_KEY_FILES is a comma separated list of rotated out keys (private or public PEM)
+// still accepted for verification, drop them once RefreshTokenTTL has passed
+// JWT_SECRET (the old HS256 secret) is not used anymore, its tokens are refused and users sign in again
+// without a key file a throwaway key is generated, which is refused when GO_ENV=production
+func InitJWT() {
+	var km *njwt.KeyManager
+
+	keyFile := os.Getenv("JWT_PRIVATE_KEY_FILE")
+	if keyFile == "" {
+		if os.Getenv("GO_ENV") == "production" {
+			log.Fatal("JWT_PRIVATE_KEY_FILE environment variable is required in production")
+		}
+
+		ephemeral, err := njwt.NewEphemeralKeyManager()
+		if err != nil {
+			log.Fatalf("Failed to generate JWT key: %v", err)
+		}
+		km = ephemeral
+		log.Println("⚠️  JWT_PRIVATE_KEY_FILE not set, using a throwaway key (tokens die on restart)")
+	} else {
+		current, err := os.ReadFile(keyFile)
+		if err != nil {
+			log.Fatalf("Failed to read JWT_PRIVATE_KEY_FILE: %v", err)
+		}
+
+		var previous [][]byte
+		for _, path := range strings.Split(os.Getenv("JWT_PREVIOUS_KEY_FILES"), ",") {
+			path = strings.TrimSpace(path)
+			if path == "" {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				log.Fatalf("Failed to read previous JWT key %s: %v", path, err)
+			}
+			previous = append(previous, data)
+		}
+
+		km, err = njwt.NewKeyManager(current, previous...)
+		if err != nil {
+			log.Fatalf("Failed to load JWT keys: %v", err)
+		}
+	}
+
+	if os.Getenv("JWT_SECRET") != "" {
+		log.Println("⚠️  JWT_SECRET is not used anymore, remove it (tokens signed with it are refused)")
+	}
+
+	njwt.SetKeyManager(km)
+	log.Println("🔑 JWT Keys Initialized")
+}
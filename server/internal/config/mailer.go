@@ -0,0 +1,34 @@
+package config
+
+import (
+	"log"
+	"os"
+
+	"github.com/ndk123-web/fast-todo/pkg/nmailer"
+)
+
+var Mailer nmailer.Mailer
+
+// InitMailer picks the mailer from MAILER_DRIVER
+// "smtp" needs SMTP_HOST / SMTP_PORT / SMTP_USERNAME / SMTP_PASSWORD / MAIL_FROM
+// anything else writes mail to MAIL_LOG_PATH (or the log) so local runs need no mail server
+func InitMailer() {
+	if os.Getenv("MAILER_DRIVER") == "smtp" {
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			log.Fatal("SMTP_HOST environment variable is required for MAILER_DRIVER=smtp")
+		}
+
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+
+		Mailer = nmailer.NewSMTPMailer(host, port, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), os.Getenv("MAIL_FROM"))
+		log.Println("📧 SMTP Mailer Initialized")
+		return
+	}
+
+	Mailer = nmailer.NewFileMailer(os.Getenv("MAIL_LOG_PATH"))
+	log.Println("📧 File Mailer Initialized (mail is not sent)")
+}
@@ -3,14 +3,45 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	MongoUri  string
-	Port      string
-	JwtSecret []byte
+	MongoUri string
+	Port     string
+	// RequireVerifiedEmail blocks unverified users from creating workspaces / todos
+	RequireVerifiedEmail bool
+	// AccountDeletionGrace is how long a deleted account can still be restored by signing in
+	AccountDeletionGrace time.Duration
+	// AccountPurgeInterval is how often accounts past their grace period are purged
+	AccountPurgeInterval time.Duration
+	// AdminEmails are promoted to admin on start, so the first admin doesn't need a db edit
+	AdminEmails []string
+	// OIDCProviders are the OpenID Connect issuers users can sign in with next to Google
+	OIDCProviders []OIDCProviderConfig
+
+	// CORS_* settings, empty lists keep the defaults of middleware.DefaultCorsOptions
+	CorsAllowedOrigins   []string
+	CorsAllowedMethods   []string
+	CorsAllowedHeaders   []string
+	CorsExposedHeaders   []string
+	CorsAllowCredentials bool
+	CorsMaxAge           time.Duration
+	// SecurityProfile picks the security headers ("development" / "production"), defaults to GO_ENV
+	SecurityProfile string
+	// ContentSecurityPolicy overrides the CSP of the profile
+	ContentSecurityPolicy string
+}
+
+// OIDCProviderConfig is one entry of OIDC_PROVIDERS, read from OIDC_<NAME>_ISSUER / OIDC_<NAME>_CLIENT_ID
+type OIDCProviderConfig struct {
+	Name     string
+	Issuer   string
+	ClientID string
 }
 
 func LoadConfig() (*Config, error) {
@@ -21,8 +52,87 @@ func LoadConfig() (*Config, error) {
 	}
 
 	return &Config{
-		MongoUri:  os.Getenv("MONGO_URI"),
-		Port:      os.Getenv("DEVLOPMENT_PORT"),
-		JwtSecret: []byte(os.Getenv("JWT_SECRET")),
+		MongoUri: os.Getenv("MONGO_URI"),
+		Port:     os.Getenv("DEVLOPMENT_PORT"),
+
+		RequireVerifiedEmail: os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true",
+
+		AccountDeletionGrace: time.Duration(envInt("ACCOUNT_DELETION_GRACE_DAYS", 14)) * 24 * time.Hour,
+		AccountPurgeInterval: envDuration("ACCOUNT_PURGE_INTERVAL", time.Hour),
+
+		AdminEmails: envList("ADMIN_EMAILS"),
+
+		OIDCProviders: oidcProviders(),
+
+		CorsAllowedOrigins:   envList("CORS_ALLOWED_ORIGINS"),
+		CorsAllowedMethods:   envList("CORS_ALLOWED_METHODS"),
+		CorsAllowedHeaders:   envList("CORS_ALLOWED_HEADERS"),
+		CorsExposedHeaders:   envList("CORS_EXPOSED_HEADERS"),
+		CorsAllowCredentials: os.Getenv("CORS_ALLOW_CREDENTIALS") != "false",
+		CorsMaxAge:           envDuration("CORS_MAX_AGE", 10*time.Minute),
+
+		SecurityProfile:       securityProfile(),
+		ContentSecurityPolicy: os.Getenv("SECURITY_CSP"),
 	}, nil
 }
+
+// securityProfile is SECURITY_PROFILE, or production / development following GO_ENV
+func securityProfile() string {
+	if profile := os.Getenv("SECURITY_PROFILE"); profile != "" {
+		return profile
+	}
+	if os.Getenv("GO_ENV") == "production" {
+		return "production"
+	}
+	return "development"
+}
+
+// oidcProviders reads OIDC_PROVIDERS=gitlab,okta and the issuer / client id of each,
+// providers missing either are skipped so a typo doesn't stop the server
+func oidcProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range envList("OIDC_PROVIDERS") {
+		name = strings.ToLower(name)
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		provider := OIDCProviderConfig{
+			Name:     name,
+			Issuer:   os.Getenv(prefix + "_ISSUER"),
+			ClientID: os.Getenv(prefix + "_CLIENT_ID"),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			log.Printf("OIDC_PROVIDERS: %s needs %s_ISSUER and %s_CLIENT_ID, skipped", name, prefix, prefix)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// envInt falls back to def when the variable is unset or not a non negative number
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return def
+	}
+	return value
+}
+
+// envDuration reads values like "30m" or "1h", falling back to def
+func envDuration(name string, def time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// envList splits a comma separated variable, empty entries are dropped
+func envList(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
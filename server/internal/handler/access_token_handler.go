@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ndk123-web/fast-todo/internal/service"
+)
+
+type AccessTokenHandler interface {
+	CreateToken(w http.ResponseWriter, r *http.Request)
+	ListTokens(w http.ResponseWriter, r *http.Request)
+	RevokeToken(w http.ResponseWriter, r *http.Request)
+}
+
+type accessTokenHandler struct {
+	service service.AccessTokenService
+}
+
+type createAccessTokenBody struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+// CreateToken returns the token in plain text, this is the only time it can be read
+func (h *accessTokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var reqBody createAccessTokenBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	plain, token, err := h.service.CreateToken(r.Context(), userId, reqBody.Name, reqBody.Scopes, reqBody.ExpiresInDays)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": map[string]any{"token": plain, "accessToken": token}, "success": "true"})
+}
+
+func (h *accessTokenHandler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	tokens, err := h.service.ListTokens(r.Context(), userId)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": tokens, "success": "true"})
+}
+
+// RevokeToken deletes the token, scripts using it get 401 on their next call
+func (h *accessTokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokenId := r.PathValue("tokenId")
+	if tokenId == "" {
+		json.NewEncoder(w).Encode(map[string]string{"Error": "Token Id is Empty In Handler", "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.RevokeToken(r.Context(), userId, tokenId); err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Token Revoked", "success": "true"})
+}
+
+func NewAccessTokenHandler(service service.AccessTokenService) AccessTokenHandler {
+	return &accessTokenHandler{
+		service: service,
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/service"
+)
+
+type AccountHandler interface {
+	DeleteAccount(w http.ResponseWriter, r *http.Request)
+}
+
+type accountHandler struct {
+	service service.AccountService
+}
+
+type deleteAccountBody struct {
+	// not needed for accounts that never had a password (Google sign up)
+	Password string `json:"password"`
+}
+
+// DeleteAccount schedules the deletion, the data stays until the grace period is over
+func (h *accountHandler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var reqBody deleteAccountBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	purgeAt, err := h.service.ScheduleDeletion(r.Context(), userId, reqBody.Password)
+	if err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"response": map[string]time.Time{"deletionScheduledAt": purgeAt},
+		"success":  "true",
+	})
+}
+
+func NewAccountHandler(service service.AccountService) AccountHandler {
+	return &accountHandler{
+		service: service,
+	}
+}
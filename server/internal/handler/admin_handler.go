@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ndk123-web/fast-todo/internal/service"
+)
+
+// AdminHandler serves /api/v1/admin, every route is behind RequireRole(model.RoleAdmin)
+type AdminHandler interface {
+	ListUsers(w http.ResponseWriter, r *http.Request)
+	GetUser(w http.ResponseWriter, r *http.Request)
+	DisableUser(w http.ResponseWriter, r *http.Request)
+	EnableUser(w http.ResponseWriter, r *http.Request)
+	SetRole(w http.ResponseWriter, r *http.Request)
+	ForcePasswordReset(w http.ResponseWriter, r *http.Request)
+	UnlockUser(w http.ResponseWriter, r *http.Request)
+	ListTodos(w http.ResponseWriter, r *http.Request)
+}
+
+type adminHandler struct {
+	service service.AdminService
+}
+
+// pageQuery reads ?page=&limit=, missing or invalid values are 0 and the service picks defaults
+func pageQuery(r *http.Request) (int64, int64) {
+	page, _ := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64)
+	limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+	return page, limit
+}
+
+// writeAdminStatus is 404 for unknown users and 400 for an admin acting on their own account
+func writeAdminStatus(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrSelfAdminAction) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	writeErrorStatus(w, err)
+}
+
+// ListUsers: GET /api/v1/admin/users?search=&page=&limit=
+func (h *adminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	page, limit := pageQuery(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	users, err := h.service.ListUsers(r.Context(), r.URL.Query().Get("search"), page, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": users, "success": "true"})
+}
+
+// GetUser returns the user with their usage counts (todos, goals, workspaces, tokens, sessions)
+func (h *adminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, err := h.service.GetUser(r.Context(), r.PathValue("userId"))
+	if err != nil {
+		writeAdminStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": user, "success": "true"})
+}
+
+func (h *adminHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, true)
+}
+
+func (h *adminHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, false)
+}
+
+func (h *adminHandler) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	adminId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.SetDisabled(r.Context(), adminId, r.PathValue("userId"), disabled); err != nil {
+		writeAdminStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	message := "User Enabled"
+	if disabled {
+		message = "User Disabled"
+	}
+	json.NewEncoder(w).Encode(map[string]string{"response": message, "success": "true"})
+}
+
+type setRoleBody struct {
+	Role string `json:"role"`
+}
+
+func (h *adminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	adminId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var reqBody setRoleBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.SetRole(r.Context(), adminId, r.PathValue("userId"), reqBody.Role); err != nil {
+		writeAdminStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Role Updated", "success": "true"})
+}
+
+// ForcePasswordReset invalidates the password, signs the user out and mails them a reset link
+func (h *adminHandler) ForcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.ForcePasswordReset(r.Context(), r.PathValue("userId")); err != nil {
+		writeAdminStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Password Reset, Link Sent", "success": "true"})
+}
+
+// UnlockUser lifts a sign in lockout before it runs out
+func (h *adminHandler) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	adminId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.UnlockUser(r.Context(), adminId, r.PathValue("userId")); err != nil {
+		writeAdminStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "User Unlocked", "success": "true"})
+}
+
+// ListTodos: GET /api/v1/admin/todos?page=&limit=, todos of every user
+func (h *adminHandler) ListTodos(w http.ResponseWriter, r *http.Request) {
+	page, limit := pageQuery(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	todos, err := h.service.ListTodos(r.Context(), page, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": todos, "success": "true"})
+}
+
+func NewAdminHandler(service service.AdminService) AdminHandler {
+	return &adminHandler{
+		service: service,
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/internal/service"
+)
+
+type AuditHandler interface {
+	ListMyEvents(w http.ResponseWriter, r *http.Request)
+	QueryEvents(w http.ResponseWriter, r *http.Request)
+}
+
+type auditHandler struct {
+	service service.AuditService
+}
+
+// ListMyEvents: GET /api/v1/users/audit?action=&page=&limit=, the caller's own trail
+func (h *auditHandler) ListMyEvents(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	page, limit := pageQuery(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	events, err := h.service.ListUserEvents(r.Context(), userId, r.URL.Query().Get("action"), page, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": events, "success": "true"})
+}
+
+// QueryEvents: GET /api/v1/admin/audit?userId=&actorId=&action=&targetId=&from=&to=&page=&limit=
+// from / to are RFC 3339 times, e.g. "who deleted this workspace" is ?targetId=<id>&action=workspace.delete
+func (h *auditHandler) QueryEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := repository.AuditFilter{
+		UserId:   query.Get("userId"),
+		ActorId:  query.Get("actorId"),
+		Action:   query.Get("action"),
+		TargetId: query.Get("targetId"),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	for name, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"Error": name + " must be an RFC 3339 time", "success": "false"})
+			return
+		}
+		*dst = parsed
+	}
+
+	page, limit := pageQuery(r)
+	events, err := h.service.QueryEvents(r.Context(), filter, page, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": events, "success": "true"})
+}
+
+func NewAuditHandler(service service.AuditService) AuditHandler {
+	return &auditHandler{
+		service: service,
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ndk123-web/fast-todo/internal/middleware"
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/service"
+)
+
+type BatchHandler interface {
+	RunBatch(w http.ResponseWriter, r *http.Request)
+}
+
+type batchHandler struct {
+	service service.BatchService
+}
+
+type batchBody struct {
+	Operations []model.BatchOperation `json:"operations"`
+}
+
+// RunBatch: POST /api/v1/batch {"operations": [{"type": "workspace.create", "tempId": "ws-1", "payload": {...}}, ...]}
+// a personal access token needs the scope of every operation, creates need a verified email like their single endpoints
+func (h *batchHandler) RunBatch(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	var body batchBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	touchesTodos, creates := false, false
+	for _, op := range body.Operations {
+		if scope, ok := model.BatchScopes[op.Type]; ok && !middleware.HasScope(r.Context(), scope) {
+			http.Error(w, "Token is missing scope "+scope, http.StatusForbidden)
+			return
+		}
+		touchesTodos = touchesTodos || strings.HasPrefix(op.Type, "todo.")
+		creates = creates || op.Type == model.BatchTodoCreate || op.Type == model.BatchWorkspaceCreate
+	}
+	if creates {
+		verified, err := middleware.EmailVerified(r.Context())
+		if err != nil {
+			http.Error(w, "Verification lookup failed", http.StatusInternalServerError)
+			return
+		}
+		if !verified {
+			http.Error(w, "Email not verified", http.StatusForbidden)
+			return
+		}
+	}
+
+	outcome, err := h.service.RunBatch(r.Context(), userId, body.Operations)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidBatch) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	// the operations can touch todos of any year, every year of the user is dropped
+	if touchesTodos {
+		clearAnalyticsCache(userId)
+	}
+
+	// the results say which operation failed, the batch itself went through
+	success := "true"
+	if !outcome.Succeeded {
+		success = "false"
+	}
+	json.NewEncoder(w).Encode(map[string]any{"response": outcome, "success": success})
+}
+
+func NewBatchHandler(service service.BatchService) BatchHandler {
+	return &batchHandler{
+		service: service,
+	}
+}
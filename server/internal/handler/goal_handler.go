@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/ndk123-web/fast-todo/internal/service"
@@ -31,8 +30,14 @@ func (h *goalHandler) GetUserGoals(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	goals, err := h.service.GetUserGoals(context.Background(), userId, workspaceId)
+	userId, ok := resolveCaller(w, r, userId)
+	if !ok {
+		return
+	}
+
+	goals, err := h.service.GetUserGoals(r.Context(), userId, workspaceId)
 	if err != nil {
+		writeErrorStatus(w, err)
 		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
 		return
 	}
@@ -54,7 +59,10 @@ func (h *goalHandler) CreateUserGoal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId := r.PathValue("userId")
+	userId, ok := resolveCaller(w, r, r.PathValue("userId"))
+	if !ok {
+		return
+	}
 	workspaceId := r.PathValue("workspaceId")
 
 	// convert string to int
@@ -64,8 +72,9 @@ func (h *goalHandler) CreateUserGoal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	goal, err := h.service.CreateUserGoal(context.Background(), userId, workspaceId, reqBody.GoalName, convertedTargetDays, reqBody.Category)
+	goal, err := h.service.CreateUserGoal(r.Context(), userId, workspaceId, reqBody.GoalName, convertedTargetDays, reqBody.Category)
 	if err != nil {
+		writeErrorStatus(w, err)
 		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
 		return
 	}
@@ -103,8 +112,14 @@ func (h *goalHandler) UpdateUserGoal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ok, err := h.service.UpdateUserGoal(context.Background(), goalId, reqBody.UpdatedGoalName, newTargetDays, reqBody.UpdatedCategory)
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	ok, err = h.service.UpdateUserGoal(r.Context(), userId, goalId, reqBody.UpdatedGoalName, newTargetDays, reqBody.UpdatedCategory)
 	if err != nil || !ok {
+		writeErrorStatus(w, err)
 		json.NewEncoder(w).Encode(map[string]any{"Error": err.Error(), "success": "false"})
 		return
 	}
@@ -120,8 +135,14 @@ func (h *goalHandler) DeleteUserGoal(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	isDeleted, err := h.service.DeleteUserGoal(context.Background(), goalIdTobeDelete)
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	isDeleted, err := h.service.DeleteUserGoal(r.Context(), userId, goalIdTobeDelete)
 	if err != nil || !isDeleted {
+		writeErrorStatus(w, err)
 		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
 		return
 	}
@@ -164,9 +185,15 @@ func (h *goalHandler) IncreamentGoalProgress(w http.ResponseWriter, r *http.Requ
 
 	fmt.Println("Count after conversion", count)
 
-	isUpdated, err := h.service.IncreamentGoalProgress(context.Background(), goalId, count)
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	isUpdated, err := h.service.IncreamentGoalProgress(r.Context(), userId, goalId, count)
 
 	if err != nil || !isUpdated {
+		writeErrorStatus(w, err)
 		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
 		return
 	}
@@ -199,9 +226,15 @@ func (h *goalHandler) DecreamentGoalProgress(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	isUpdated, err := h.service.DecreamentGoalProgress(context.Background(), goalId, count)
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	isUpdated, err := h.service.DecreamentGoalProgress(r.Context(), userId, goalId, count)
 
 	if err != nil || !isUpdated {
+		writeErrorStatus(w, err)
 		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
 		return
 	}
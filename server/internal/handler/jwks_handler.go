@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ndk123-web/fast-todo/pkg/njwt"
+)
+
+// JWKS serves the public signing keys so other services can verify fast-todo tokens
+// previous keys stay listed until they are rotated out, so cached copies keep working
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := njwt.JWKS()
+	if err != nil {
+		http.Error(w, "Keys not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(set)
+}
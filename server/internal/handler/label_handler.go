@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/internal/service"
+)
+
+type LabelHandler interface {
+	ListLabels(w http.ResponseWriter, r *http.Request)
+	CreateLabel(w http.ResponseWriter, r *http.Request)
+	UpdateLabel(w http.ResponseWriter, r *http.Request)
+	DeleteLabel(w http.ResponseWriter, r *http.Request)
+	ReorderLabels(w http.ResponseWriter, r *http.Request)
+}
+
+type labelHandler struct {
+	service service.LabelService
+}
+
+// writeLabelStatus is 409 for a name the workspace already has, 404 for labels of someone else
+func writeLabelStatus(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrLabelExists) {
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	writeErrorStatus(w, err)
+}
+
+// ListLabels: GET /api/v1/workspaces/{workspaceId}/labels, the catalog in its order
+func (h *labelHandler) ListLabels(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	labels, err := h.service.ListLabels(r.Context(), userId, r.PathValue("workspaceId"))
+	if err != nil {
+		writeLabelStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": labels, "success": "true"})
+}
+
+type labelBody struct {
+	// all optional on update, left out keeps the value
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+	Order *int    `json:"order"`
+}
+
+// CreateLabel: POST /api/v1/workspaces/{workspaceId}/labels, body { name, color? }
+func (h *labelHandler) CreateLabel(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	var reqBody labelBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+	if reqBody.Name == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": "name is required", "success": "false"})
+		return
+	}
+	color := ""
+	if reqBody.Color != nil {
+		color = *reqBody.Color
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	label, err := h.service.CreateLabel(r.Context(), userId, r.PathValue("workspaceId"), *reqBody.Name, color)
+	if err != nil {
+		writeLabelStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": label, "success": "true"})
+}
+
+// UpdateLabel: PUT /api/v1/labels/{labelId}, a new name is applied to the todos using the label
+func (h *labelHandler) UpdateLabel(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	var reqBody labelBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	update := model.LabelUpdate{Name: reqBody.Name, Color: reqBody.Color, Order: reqBody.Order}
+	label, err := h.service.UpdateLabel(r.Context(), userId, r.PathValue("labelId"), update)
+	if err != nil {
+		writeLabelStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": label, "success": "true"})
+}
+
+// DeleteLabel: DELETE /api/v1/labels/{labelId}, the label is taken off every todo too
+func (h *labelHandler) DeleteLabel(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.DeleteLabel(r.Context(), userId, r.PathValue("labelId")); err != nil {
+		writeLabelStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Label Deleted", "success": "true"})
+}
+
+type labelOrderBody struct {
+	// LabelIds lists every label of the workspace in the new order
+	LabelIds []string `json:"labelIds"`
+}
+
+// ReorderLabels: PUT /api/v1/workspaces/{workspaceId}/labels/order
+func (h *labelHandler) ReorderLabels(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	var reqBody labelOrderBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	labels, err := h.service.ReorderLabels(r.Context(), userId, r.PathValue("workspaceId"), reqBody.LabelIds)
+	if err != nil {
+		writeLabelStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": labels, "success": "true"})
+}
+
+func NewLabelHandler(service service.LabelService) LabelHandler {
+	return &labelHandler{
+		service: service,
+	}
+}
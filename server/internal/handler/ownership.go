@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/ndk123-web/fast-todo/internal/middleware"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+)
+
+// callerId returns the userId that AuthMiddleware resolved from the token
+func callerId(r *http.Request) (string, bool) {
+	userId, ok := r.Context().Value(middleware.UserId).(string)
+	return userId, ok && userId != ""
+}
+
+// resolveCaller checks that a userId sent by the client (path / body / query) is the caller itself
+// an empty claimed id is allowed so clients can stop sending it, a different id is 403
+func resolveCaller(w http.ResponseWriter, r *http.Request, claimedUserId string) (string, bool) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+
+	if claimedUserId != "" && claimedUserId != userId {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return "", false
+	}
+
+	return userId, true
+}
+
+// writeErrorStatus sets 404 when the caller touched a document it doesn't own
+// any other error keeps the existing status so the JSON error body still reaches the client
+func writeErrorStatus(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// writeRateLimitStatus sets 429 with Retry-After (in whole seconds) for rate limited calls
+func writeRateLimitStatus(w http.ResponseWriter, err error) {
+	var limited *repository.RateLimitError
+	if errors.As(err, &limited) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+}
+
+// writeSignInStatus sets 403 for accounts an admin disabled, 429 for locked out sign ins
+func writeSignInStatus(w http.ResponseWriter, err error) {
+	if errors.Is(err, repository.ErrAccountDisabled) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	writeRateLimitStatus(w, err)
+}
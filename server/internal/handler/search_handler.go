@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/middleware"
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/search"
+	"github.com/ndk123-web/fast-todo/internal/service"
+)
+
+type SearchHandler interface {
+	Search(w http.ResponseWriter, r *http.Request)
+}
+
+type searchHandler struct {
+	service service.SearchService
+}
+
+// searchScopes is the scope a personal access token needs to search each kind
+var searchScopes = map[string]string{
+	search.KindTodo:      model.ScopeTodosRead,
+	search.KindGoal:      model.ScopeGoalsRead,
+	search.KindWorkspace: model.ScopeWorkspacesRead,
+}
+
+// parseQueryDate reads a date of the query string, RFC3339 or a plain day (2025-01-31, UTC)
+func parseQueryDate(name string, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return &parsed, nil
+	}
+	parsed, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a RFC3339 date or a day like 2025-01-31", name)
+	}
+	return &parsed, nil
+}
+
+// Search: GET /api/v1/search?q=invoice&kinds=todo,goal&workspaceId=&status=&priority=&from=&to=&limit=
+// a personal access token only searches the kinds its scopes can read
+func (h *searchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	params := r.URL.Query()
+	q := search.Query{
+		UserId:      userId,
+		Text:        strings.TrimSpace(params.Get("q")),
+		WorkspaceId: params.Get("workspaceId"),
+		Status:      params.Get("status"),
+		Priority:    params.Get("priority"),
+	}
+
+	var err error
+	if q.From, err = parseQueryDate("from", params.Get("from")); err == nil {
+		q.To, err = parseQueryDate("to", params.Get("to"))
+	}
+	if err == nil && params.Get("limit") != "" {
+		if q.Limit, err = strconv.Atoi(params.Get("limit")); err != nil {
+			err = errors.New("limit must be a number")
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	if kinds := params.Get("kinds"); kinds != "" {
+		q.Kinds = strings.Split(kinds, ",")
+		for _, kind := range q.Kinds {
+			if scope, ok := searchScopes[kind]; ok && !middleware.HasScope(r.Context(), scope) {
+				http.Error(w, "Token is missing scope "+scope, http.StatusForbidden)
+				return
+			}
+		}
+	} else {
+		for _, kind := range search.Kinds {
+			if middleware.HasScope(r.Context(), searchScopes[kind]) {
+				q.Kinds = append(q.Kinds, kind)
+			}
+		}
+		if len(q.Kinds) == 0 {
+			http.Error(w, "Token has no read scope to search with", http.StatusForbidden)
+			return
+		}
+	}
+
+	results, err := h.service.Search(r.Context(), q)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSearch) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			writeErrorStatus(w, err)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": results, "success": "true"})
+}
+
+func NewSearchHandler(service service.SearchService) SearchHandler {
+	return &searchHandler{
+		service: service,
+	}
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ndk123-web/fast-todo/internal/middleware"
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/service"
+)
+
+type SessionHandler interface {
+	ListSessions(w http.ResponseWriter, r *http.Request)
+	RevokeSession(w http.ResponseWriter, r *http.Request)
+}
+
+type sessionHandler struct {
+	service service.SessionService
+}
+
+// ListSessions returns every device the caller is signed in on
+func (h *sessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentSessionId, _ := r.Context().Value(middleware.SessionId).(string)
+
+	w.Header().Set("Content-Type", "application/json")
+	sessions, err := h.service.ListSessions(r.Context(), userId, currentSessionId)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": sessions, "success": "true"})
+}
+
+// RevokeSession signs out one device, its access and refresh tokens stop working right away
+func (h *sessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessionId := r.PathValue("sessionId")
+	if sessionId == "" {
+		json.NewEncoder(w).Encode(map[string]string{"Error": "Session Id is Empty In Handler", "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.RevokeSession(r.Context(), userId, sessionId); err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Session Revoked", "success": "true"})
+}
+
+// clientInfo collects the user agent and ip recorded on a new session
+func clientInfo(r *http.Request) model.ClientInfo {
+	return model.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IP:        middleware.ClientIP(r),
+	}
+}
+
+func NewSessionHandler(service service.SessionService) SessionHandler {
+	return &sessionHandler{
+		service: service,
+	}
+}
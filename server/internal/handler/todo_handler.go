@@ -4,26 +4,39 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ndk123-web/fast-todo/internal/config"
-	"github.com/ndk123-web/fast-todo/internal/middleware"
 	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
 	"github.com/ndk123-web/fast-todo/internal/service"
 	"github.com/redis/go-redis/v9"
 )
 
 // TodoHandler defines the interface for HTTP request handlers for todo operations
 type TodoHandler interface {
-	GetTodos(w http.ResponseWriter, r *http.Request)
 	CreateTodo(w http.ResponseWriter, r *http.Request)
 	UpdateTodo(w http.ResponseWriter, r *http.Request)
 	DeleteTodo(w http.ResponseWriter, r *http.Request)
 	GetSpecificTodo(w http.ResponseWriter, r *http.Request)
 	ToogleTodo(w http.ResponseWriter, r *http.Request)
 	AnalyticsOfTodos(w http.ResponseWriter, r *http.Request)
+	SkipOccurrence(w http.ResponseWriter, r *http.Request)
+	AddException(w http.ResponseWriter, r *http.Request)
+	AddChecklistItem(w http.ResponseWriter, r *http.Request)
+	UpdateChecklistItem(w http.ResponseWriter, r *http.Request)
+	ReorderChecklist(w http.ResponseWriter, r *http.Request)
+	DeleteChecklistItem(w http.ResponseWriter, r *http.Request)
+	ListSubtasks(w http.ResponseWriter, r *http.Request)
+	TransitionTodo(w http.ResponseWriter, r *http.Request)
+	MoveTodo(w http.ResponseWriter, r *http.Request)
+	MoveTodos(w http.ResponseWriter, r *http.Request)
+	CopyTodos(w http.ResponseWriter, r *http.Request)
 }
 
 // todoHandler implements TodoHandler with a service layer dependency
@@ -38,24 +51,6 @@ func NewTodoHandler(service service.TodoService) TodoHandler {
 	}
 }
 
-// GetTodos handles HTTP GET requests to retrieve all todo items
-// Returns a JSON array of todos or an error message
-func (h *todoHandler) GetTodos(w http.ResponseWriter, r *http.Request) {
-	todos, err := h.service.GetTodos(context.Background())
-	if err != nil {
-		http.Error(w, "Error fetching todos", http.StatusInternalServerError)
-		return
-	}
-
-	userEmail, ok := r.Context().Value(middleware.UserEmailKey).(string)
-	if !ok {
-		http.Error(w, "UserEmail Not Exists in Email", http.StatusExpectationFailed)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{"response": todos, "userEmail": userEmail})
-}
-
 type toggleBody struct {
 	Toggle string `json:"toggle"`
 	ID     string `json:"id"`
@@ -71,28 +66,24 @@ func (h *todoHandler) ToogleTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// redis caching for removing key of analytics
-	rdb := config.RedisClient
-	redisKey := fmt.Sprintf("analytics:%s:%s", reqBody.UserId, "2025") // assuming current year is 2025
-	err := rdb.Del(context.Background(), redisKey).Err()
-	if err != redis.Nil {
-		fmt.Println("Redis error:", err)
-	} else {
-		fmt.Printf(" ToogleTodo: Deleted cache key %s\n", redisKey)
+	userId, ok := resolveCaller(w, r, reqBody.UserId)
+	if !ok {
+		return
 	}
 
-	ok, err := h.service.ToggleTodo(context.Background(), reqBody.ID, reqBody.Toggle, reqBody.UserId)
+	todo, err := h.service.ToggleTodo(r.Context(), reqBody.ID, reqBody.Toggle, userId)
 	w.Header().Set("Content-Type", "application/json")
-	if err != nil || !ok {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]any{"response": "false", "error": func() string {
-			if err != nil {
-				return err.Error()
-			}
-			return "toggle failed"
-		}()})
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"response": "false", "error": err.Error()})
 		return
 	}
+	clearTodoAnalytics(userId, todo)
+
 	json.NewEncoder(w).Encode(map[string]any{"response": "true"})
 }
 
@@ -120,6 +111,11 @@ func (h *todoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	userId, ok := resolveCaller(w, r, userId)
+	if !ok {
+		return
+	}
+
 	var todo model.Todo
 	err := json.NewDecoder(r.Body).Decode(&todo)
 
@@ -130,10 +126,12 @@ func (h *todoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Println("Body: ", r.Body)
 
-	todores, todoerr := h.service.CreateTodo(context.Background(), todo, workspaceId, userId)
+	todores, todoerr := h.service.CreateTodo(r.Context(), todo, workspaceId, userId)
 
 	if todoerr != nil {
+		writeErrorStatus(w, todoerr)
 		json.NewEncoder(w).Encode(map[string]string{"Error": todoerr.Error(), "success": "false"})
+		return
 	}
 
 	fmt.Println("Todo Create : ", todores)
@@ -143,10 +141,38 @@ func (h *todoHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
 
 // updateTodo represents the request payload for updating a todo
 type updateTodo struct {
-	ID   string `json:"id"`   // ID of the todo to update
-	Task string `json:"task"` // New task text
+	ID   string  `json:"id"`   // ID of the todo to update
+	Task *string `json:"task"` // New task text, left out keeps it
 	// WorkspaceId string `json:"workspaceId"`  // No Need of workspaceID because ID is already unique
-	Priority string `json:"priority"`
+	Priority *string `json:"priority"`
+	// RFC3339 dates, left out (or null) keeps the date and "" removes it
+	DueAt   *string `json:"dueAt"`
+	StartAt *string `json:"startAt"`
+	// RRule of a repeating todo, "" stops repeating, left out keeps it
+	RRule    *string `json:"rrule"`
+	TimeZone string  `json:"timeZone"`
+	// Scope is "this" (default) or "future" for the instances of a repeating todo
+	Scope string `json:"scope"`
+	// AutoComplete marks the todo done once its checklist / subtasks are, left out keeps it
+	AutoComplete *bool `json:"autoComplete"`
+	// Labels replaces the labels of the todo, left out keeps them and [] removes them
+	Labels *[]string `json:"labels"`
+}
+
+// parseOptionalDate reads a date of an update body, see updateTodo
+func parseOptionalDate(name string, value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if *value == "" {
+		return &time.Time{}, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC3339 date", name)
+	}
+	return &parsed, nil
 }
 
 // UpdateTodo handles HTTP PUT requests to update an existing todo
@@ -159,8 +185,43 @@ func (h *todoHandler) UpdateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	todo, err2 := h.service.UpdateTodo(context.Background(), tobeUpdate.ID, tobeUpdate.Task, tobeUpdate.Priority)
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	dueAt, err := parseOptionalDate("dueAt", tobeUpdate.DueAt)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+	startAt, err := parseOptionalDate("startAt", tobeUpdate.StartAt)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	update := model.TodoUpdate{
+		Task:     tobeUpdate.Task,
+		Priority: tobeUpdate.Priority,
+		DueAt:    dueAt,
+		StartAt:  startAt,
+		RRule:    tobeUpdate.RRule,
+		TimeZone: tobeUpdate.TimeZone,
+		Scope:    tobeUpdate.Scope,
+
+		AutoComplete: tobeUpdate.AutoComplete,
+		Labels:       tobeUpdate.Labels,
+	}
+	todo, err2 := h.service.UpdateTodo(r.Context(), tobeUpdate.ID, update, userId)
 	if err2 != nil {
+		if errors.Is(err2, service.ErrInvalidTodoUpdate) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			writeErrorStatus(w, err2)
+		}
 		json.NewEncoder(w).Encode(map[string]string{"Error": err2.Error(), "success": "false"})
 		return
 	}
@@ -179,18 +240,31 @@ func (h *todoHandler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ok, err2 := h.service.DeleteTodo(context.Background(), todoId)
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	ok, err2 := h.service.DeleteTodo(r.Context(), todoId, userId)
 	if err2 != nil {
+		writeErrorStatus(w, err2)
 		json.NewEncoder(w).Encode(map[string]string{"error": err2.Error(), "success": "false"})
+		return
 	}
 
 	if !ok {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Delete False", "success": "false"})
+		return
 	}
 
 	json.NewEncoder(w).Encode(map[string]string{"success": "true"})
 }
 
+// GetSpecificTodo: GET .../get-ws-todo/{workspaceID}?view=overdue|today|week|no-date&tz=Europe/Berlin&labels=bug,waiting&match=any|all
+// &status=in-progress,blocked&priority=high&createdFrom=&createdTo=&updatedFrom=&updatedTo=&prefix=&sort=-updatedAt&limit=&cursor=&all=
+// tz (IANA name, UTC by default) decides where "today" and "this week" start, the To dates are exclusive
+// the response is a page { items, nextCursor, limit } of limit todos (50 by default, at most 200),
+// all=true answers with the plain list of every todo as it did before paging
 func (h *todoHandler) GetSpecificTodo(w http.ResponseWriter, r *http.Request) {
 	// var todoPath = regexp.MustCompile(`^/api/v1/users/([0-9a-zA-Z\-]+)/get-specific-todo/([0-9a-zA-Z\-]+)$`)
 	// matchers := todoPath.FindStringSubmatch(r.URL.Path)
@@ -204,18 +278,108 @@ func (h *todoHandler) GetSpecificTodo(w http.ResponseWriter, r *http.Request) {
 	// workspaceId := matchers[2]
 
 	userId := r.PathValue("userId")
-	workspaceId := r.PathValue("workspaceId")
+	workspaceId := r.PathValue("workspaceID")
 
 	fmt.Println("User ID:", userId)
 	fmt.Println("Workspace ID:", workspaceId)
 
-	todo, err := h.service.GetSpecificTodo(context.Background(), workspaceId, userId)
+	userId, ok := resolveCaller(w, r, userId)
+	if !ok {
+		return
+	}
+
+	loc := time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			http.Error(w, "Unknown time zone", http.StatusBadRequest)
+			return
+		}
+	}
+
+	query, err := todoListQuery(r)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.Location = loc
+
+	page, err := h.service.GetSpecificTodo(r.Context(), workspaceId, userId, query)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, service.ErrUnknownTodoView) || errors.Is(err, service.ErrUnknownLabelMatch) ||
+			errors.Is(err, service.ErrUnknownStatus) || errors.Is(err, service.ErrInvalidTodoQuery) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]any{"response": todo})
+	if query.All {
+		json.NewEncoder(w).Encode(map[string]any{"response": page.Items})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"response": page})
+}
+
+// splitList reads a comma separated query parameter, nil when it's missing
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// todoListQuery reads the filters / sort / page of GetSpecificTodo from the query string
+func todoListQuery(r *http.Request) (model.TodoQuery, error) {
+	params := r.URL.Query()
+	query := model.TodoQuery{
+		View:       params.Get("view"),
+		Labels:     splitList(params.Get("labels")),
+		LabelMatch: params.Get("match"),
+		Statuses:   splitList(params.Get("status")),
+		Priorities: splitList(params.Get("priority")),
+		TaskPrefix: params.Get("prefix"),
+		Sort:       params.Get("sort"),
+		Cursor:     params.Get("cursor"),
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || parsed < 1 {
+			return model.TodoQuery{}, errors.New("limit must be a positive number")
+		}
+		query.Limit = parsed
+	}
+	if all := params.Get("all"); all != "" {
+		parsed, err := strconv.ParseBool(all)
+		if err != nil {
+			return model.TodoQuery{}, errors.New("all must be true or false")
+		}
+		query.All = parsed
+	}
+
+	dates := []struct {
+		name   string
+		target **time.Time
+	}{
+		{"createdFrom", &query.CreatedFrom},
+		{"createdTo", &query.CreatedBefore},
+		{"updatedFrom", &query.UpdatedFrom},
+		{"updatedTo", &query.UpdatedBefore},
+	}
+	for _, date := range dates {
+		parsed, err := parseQueryDate(date.name, params.Get(date.name))
+		if err != nil {
+			return model.TodoQuery{}, err
+		}
+		*date.target = parsed
+	}
+	return query, nil
 }
 
 type analyticsRequest struct {
@@ -225,13 +389,24 @@ type analyticsRequest struct {
 
 func (h *todoHandler) AnalyticsOfTodos(w http.ResponseWriter, r *http.Request) {
 	year := r.PathValue("year")
-	userId := r.PathValue("userId")
+	userId, ok := resolveCaller(w, r, r.PathValue("userId"))
+	if !ok {
+		return
+	}
+
+	// Get request body for workspaceId
+	var reqBody analyticsRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		fmt.Printf("❌ Analytics: Error decoding request body: %v\n", err)
+		json.NewEncoder(w).Encode(map[string]any{"success": "false", "Error": "Invalid request body"})
+		return
+	}
 
 	// initialize redis client
 	rdb := config.RedisClient
 
-	// Added Redis Caching Layer
-	redisKey := fmt.Sprintf("analytics:%s:%s", userId, year)
+	// Added Redis Caching Layer, one entry per workspace
+	redisKey := analyticsKey(userId, reqBody.WorkspaceId, year)
 	result, err := rdb.Get(context.Background(), redisKey).Result()
 	if err != redis.Nil {
 		fmt.Printf("Analytics: Redis GET error: %v\n", err)
@@ -243,14 +418,6 @@ func (h *todoHandler) AnalyticsOfTodos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get request body for workspaceId
-	var reqBody analyticsRequest
-	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
-		fmt.Printf("❌ Analytics: Error decoding request body: %v\n", err)
-		json.NewEncoder(w).Encode(map[string]any{"success": "false", "Error": "Invalid request body"})
-		return
-	}
-
 	fmt.Printf("Analytics Request - Year: %s, UserId: %s, WorkspaceId: %s\n", year, userId, reqBody.WorkspaceId)
 
 	if year == "" || userId == "" {
@@ -258,9 +425,10 @@ func (h *todoHandler) AnalyticsOfTodos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	analytics, err := h.service.AnalyticsOfTodos(context.Background(), year, userId, reqBody.WorkspaceId)
+	analytics, err := h.service.AnalyticsOfTodos(r.Context(), year, userId, reqBody.WorkspaceId)
 	if err != nil {
 		fmt.Printf("Analytics: Service error: %v\n", err)
+		writeErrorStatus(w, err)
 		json.NewEncoder(w).Encode(map[string]any{"success": "false", "Error": err.Error()})
 		return
 	}
@@ -282,3 +450,386 @@ func (h *todoHandler) AnalyticsOfTodos(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf(" Analytics: Returning data: %+v\n", analytics)
 	json.NewEncoder(w).Encode(map[string]any{"success": "true", "response": analytics})
 }
+
+// writeSeriesStatus is 404 for todos of someone else, 400 for todos that don't repeat
+func writeSeriesStatus(w http.ResponseWriter, err error) {
+	if errors.Is(err, service.ErrNotRecurring) || errors.Is(err, service.ErrSeriesEnded) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	writeErrorStatus(w, err)
+}
+
+// SkipOccurrence: POST /api/v1/todos/{todoId}/skip, moves a repeating todo to its next occurrence
+func (h *todoHandler) SkipOccurrence(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	todo, err := h.service.SkipOccurrence(r.Context(), r.PathValue("todoId"), userId)
+	if err != nil {
+		writeSeriesStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": todo, "success": "true"})
+}
+
+type exceptionBody struct {
+	// Date of the occurrence to skip, RFC3339 (only the day counts)
+	Date string `json:"date"`
+}
+
+// AddException: POST /api/v1/todos/{todoId}/exceptions, skips one later occurrence of the series
+func (h *todoHandler) AddException(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	var reqBody exceptionBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	date, err := time.Parse(time.RFC3339, reqBody.Date)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": "date must be an RFC3339 date", "success": "false"})
+		return
+	}
+
+	if err := h.service.AddException(r.Context(), r.PathValue("todoId"), userId, date); err != nil {
+		writeSeriesStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Occurrence Skipped", "success": "true"})
+}
+
+type checklistItemBody struct {
+	// both optional on update, left out keeps the value
+	Text *string `json:"text"`
+	Done *bool   `json:"done"`
+}
+
+// AddChecklistItem: POST /api/v1/todos/{todoId}/checklist, returns the todo with the new item
+func (h *todoHandler) AddChecklistItem(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	var reqBody checklistItemBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+	if reqBody.Text == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": "text is required", "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	todo, err := h.service.AddChecklistItem(r.Context(), r.PathValue("todoId"), userId, *reqBody.Text)
+	if err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": todo, "success": "true"})
+}
+
+// UpdateChecklistItem: PUT /api/v1/todos/{todoId}/checklist/{itemId}, renames and / or ticks an item
+func (h *todoHandler) UpdateChecklistItem(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	var reqBody checklistItemBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	todo, err := h.service.UpdateChecklistItem(r.Context(), r.PathValue("todoId"), userId, r.PathValue("itemId"), reqBody.Text, reqBody.Done)
+	if err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": todo, "success": "true"})
+}
+
+type checklistOrderBody struct {
+	// ItemIds lists every item of the checklist in the new order
+	ItemIds []string `json:"itemIds"`
+}
+
+// ReorderChecklist: PUT /api/v1/todos/{todoId}/checklist/order
+func (h *todoHandler) ReorderChecklist(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	var reqBody checklistOrderBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	todo, err := h.service.ReorderChecklist(r.Context(), r.PathValue("todoId"), userId, reqBody.ItemIds)
+	if err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": todo, "success": "true"})
+}
+
+// DeleteChecklistItem: DELETE /api/v1/todos/{todoId}/checklist/{itemId}
+func (h *todoHandler) DeleteChecklistItem(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	todo, err := h.service.DeleteChecklistItem(r.Context(), r.PathValue("todoId"), userId, r.PathValue("itemId"))
+	if err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": todo, "success": "true"})
+}
+
+// ListSubtasks: GET /api/v1/todos/{todoId}/subtasks, subtasks are created with parentId on create-todo
+func (h *todoHandler) ListSubtasks(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	subtasks, err := h.service.ListSubtasks(r.Context(), r.PathValue("todoId"), userId)
+	if err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": subtasks, "success": "true"})
+}
+
+// analyticsKey is where the analytics of one workspace and year are cached
+func analyticsKey(userId string, workspaceId string, year string) string {
+	return fmt.Sprintf("analytics:%s:%s:%s", userId, workspaceId, year)
+}
+
+// clearAnalyticsCache drops every cached analytics of the user, all workspaces and all years,
+// for changes that don't say which todos (and so which years) they touched
+func clearAnalyticsCache(userId string) {
+	ctx := context.Background()
+
+	var keys []string
+	iter := config.RedisClient.Scan(ctx, 0, analyticsKey(userId, "*", "*"), 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		fmt.Println("Redis error:", err)
+		return
+	}
+	deleteAnalyticsKeys(ctx, keys)
+}
+
+// clearTransferAnalytics drops the cached analytics a move / copy changed
+func clearTransferAnalytics(userId string, workspaceId string, transfer model.TodoTransfer) {
+	deleteAnalyticsKeys(context.Background(), transferAnalyticsKeys(userId, workspaceId, transfer))
+}
+
+// transferAnalyticsKeys are the cached analytics of every year a transferred todo was created in
+// (analytics count a todo in that year), for the workspaces the todos left, the one they went to
+// and the total over all workspaces
+func transferAnalyticsKeys(userId string, workspaceId string, transfer model.TodoTransfer) []string {
+	workspaceIds := append([]string{workspaceId, ""}, transfer.FromWorkspaceIds...)
+	years := map[int]bool{}
+	var keys []string
+	for _, todo := range transfer.Todos {
+		year := todo.CreatedAt.Year()
+		if years[year] {
+			continue
+		}
+		years[year] = true
+		for _, id := range workspaceIds {
+			keys = append(keys, analyticsKey(userId, id, strconv.Itoa(year)))
+		}
+	}
+	return keys
+}
+
+// clearTodoAnalytics drops the cached analytics a status change of the todo touched, the ones
+// of the year it was created in, for its workspace and the total
+func clearTodoAnalytics(userId string, todo model.Todo) {
+	deleteAnalyticsKeys(context.Background(), todoAnalyticsKeys(userId, todo))
+}
+
+func todoAnalyticsKeys(userId string, todo model.Todo) []string {
+	return transferAnalyticsKeys(userId, todo.WorkspaceId.Hex(), model.TodoTransfer{Todos: []model.Todo{todo}})
+}
+
+func deleteAnalyticsKeys(ctx context.Context, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+
+	if err := config.RedisClient.Del(ctx, keys...).Err(); err != nil && err != redis.Nil {
+		fmt.Println("Redis error:", err)
+	}
+}
+
+type statusBody struct {
+	// Status is not-started, in-progress, blocked, done or cancelled
+	Status string `json:"status"`
+}
+
+// writeTransitionStatus is 400 for an unknown status, 409 for a move that isn't allowed
+// (or lost to another client) and 404 for todos of someone else
+func writeTransitionStatus(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnknownStatus):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, service.ErrInvalidTransition), errors.Is(err, repository.ErrStatusChanged):
+		w.WriteHeader(http.StatusConflict)
+	default:
+		writeErrorStatus(w, err)
+	}
+}
+
+// TransitionTodo: POST /api/v1/todos/{todoId}/status, moves a todo along the status workflow
+func (h *todoHandler) TransitionTodo(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	var reqBody statusBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	todo, err := h.service.TransitionTodo(r.Context(), r.PathValue("todoId"), reqBody.Status, userId)
+	if err != nil {
+		writeTransitionStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+	clearTodoAnalytics(userId, todo)
+
+	json.NewEncoder(w).Encode(map[string]any{"response": todo, "success": "true"})
+}
+
+type moveBody struct {
+	// Before is the todo the moved one goes right above, After the one it goes right below,
+	// one of them is enough
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// MoveTodo: POST /api/v1/todos/{todoId}/move, drag ordering within the status column of the todo
+func (h *todoHandler) MoveTodo(w http.ResponseWriter, r *http.Request) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	var reqBody moveBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	todo, err := h.service.MoveTodo(r.Context(), r.PathValue("todoId"), userId, reqBody.Before, reqBody.After)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidMove) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			writeErrorStatus(w, err)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": todo, "success": "true"})
+}
+
+type transferBody struct {
+	// TodoIds are the todos to move / copy (one or many), their subtasks come along
+	TodoIds []string `json:"todoIds"`
+}
+
+// MoveTodos: POST /api/v1/workspaces/{workspaceId}/todos/move, moves todos into the workspace
+func (h *todoHandler) MoveTodos(w http.ResponseWriter, r *http.Request) {
+	h.transferTodos(w, r, h.service.MoveTodos)
+}
+
+// CopyTodos: POST /api/v1/workspaces/{workspaceId}/todos/copy, copies todos into the workspace
+func (h *todoHandler) CopyTodos(w http.ResponseWriter, r *http.Request) {
+	h.transferTodos(w, r, h.service.CopyTodos)
+}
+
+// transferTodos runs a move / copy, the analytics of the target and of every source workspace change
+func (h *todoHandler) transferTodos(w http.ResponseWriter, r *http.Request, transfer func(ctx context.Context, todoIds []string, workspaceId string, userId string) (model.TodoTransfer, error)) {
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
+	var reqBody transferBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	workspaceId := r.PathValue("workspaceId")
+	result, err := transfer(r.Context(), reqBody.TodoIds, workspaceId, userId)
+	if err != nil && len(result.Todos) > 0 {
+		// no transaction and the move stopped partway, the todos it moved stay moved
+		clearTransferAnalytics(userId, workspaceId, result)
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]any{"Error": err.Error(), "response": result, "success": "false"})
+		return
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTransfer) {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			writeErrorStatus(w, err)
+		}
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	clearTransferAnalytics(userId, workspaceId, result)
+	json.NewEncoder(w).Encode(map[string]any{"response": result, "success": "true"})
+}
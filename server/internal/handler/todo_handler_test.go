@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTransferAnalyticsKeys(t *testing.T) {
+	created := func(year int) model.Todo {
+		return model.Todo{CreatedAt: time.Date(year, 6, 1, 9, 0, 0, 0, time.UTC)}
+	}
+
+	tests := []struct {
+		name     string
+		transfer model.TodoTransfer
+		want     []string
+	}{
+		{
+			name:     "nothing transferred",
+			transfer: model.TodoTransfer{FromWorkspaceIds: []string{"a"}},
+			want:     nil,
+		},
+		{
+			name:     "todos of past years",
+			transfer: model.TodoTransfer{Todos: []model.Todo{created(2024), created(2026), created(2024)}, FromWorkspaceIds: []string{"a"}},
+			want: []string{
+				"analytics:u::2024", "analytics:u::2026",
+				"analytics:u:a:2024", "analytics:u:a:2026",
+				"analytics:u:t:2024", "analytics:u:t:2026",
+			},
+		},
+		{
+			name:     "from several workspaces",
+			transfer: model.TodoTransfer{Todos: []model.Todo{created(2025)}, FromWorkspaceIds: []string{"a", "b"}},
+			want:     []string{"analytics:u::2025", "analytics:u:a:2025", "analytics:u:b:2025", "analytics:u:t:2025"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := transferAnalyticsKeys("u", "t", tt.transfer)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("transferAnalyticsKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTodoAnalyticsKeys(t *testing.T) {
+	workspaceId := primitive.NewObjectID()
+	// a todo of last year finished today is counted in last year's analytics
+	todo := model.Todo{WorkspaceId: workspaceId, CreatedAt: time.Date(2025, 12, 30, 9, 0, 0, 0, time.UTC)}
+
+	got := todoAnalyticsKeys("u", todo)
+	sort.Strings(got)
+	want := []string{"analytics:u:" + workspaceId.Hex() + ":2025", "analytics:u::2025"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("todoAnalyticsKeys() = %v, want %v", got, want)
+	}
+}
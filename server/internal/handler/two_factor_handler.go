@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/internal/service"
+)
+
+type TwoFactorHandler interface {
+	Enroll(w http.ResponseWriter, r *http.Request)
+	Confirm(w http.ResponseWriter, r *http.Request)
+	Disable(w http.ResponseWriter, r *http.Request)
+	Verify(w http.ResponseWriter, r *http.Request)
+}
+
+type twoFactorHandler struct {
+	service service.TwoFactorService
+}
+
+type twoFactorCodeBody struct {
+	Code string `json:"code"`
+}
+
+type twoFactorVerifyBody struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
+
+// Enroll returns the otpauth uri for the QR code plus the recovery codes, both shown once
+func (h *twoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enrollment, err := h.service.Enroll(r.Context(), userId)
+	if err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": enrollment, "success": "true"})
+}
+
+// Confirm turns 2FA on once the authenticator app produced a valid code
+func (h *twoFactorHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var reqBody twoFactorCodeBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.Confirm(r.Context(), userId, reqBody.Code); err != nil {
+		writeTwoFactorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Two-Factor Authentication Enabled", "success": "true"})
+}
+
+func (h *twoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var reqBody twoFactorCodeBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.Disable(r.Context(), userId, reqBody.Code); err != nil {
+		writeTwoFactorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Two-Factor Authentication Disabled", "success": "true"})
+}
+
+// Verify is the second step of a password sign in, it is called without an access token
+func (h *twoFactorHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var reqBody twoFactorVerifyBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	if reqBody.ChallengeToken == "" || reqBody.Code == "" {
+		json.NewEncoder(w).Encode(map[string]string{"Error": "Challenge Token / Code is Empty", "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	resp, err := h.service.Verify(r.Context(), reqBody.ChallengeToken, reqBody.Code, clientInfo(r))
+	if err != nil {
+		writeTwoFactorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{"response": resp, "success": "true"})
+}
+
+// writeTwoFactorStatus maps a wrong code or a dead challenge to 401, too many tries to 429
+// and a disabled account to 403
+func writeTwoFactorStatus(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidTwoFactorCode), errors.Is(err, repository.ErrInvalidToken):
+		w.WriteHeader(http.StatusUnauthorized)
+	default:
+		writeSignInStatus(w, err)
+	}
+}
+
+func NewTwoFactorHandler(service service.TwoFactorService) TwoFactorHandler {
+	return &twoFactorHandler{
+		service: service,
+	}
+}
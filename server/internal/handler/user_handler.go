@@ -1,17 +1,16 @@
 package handler
 
 import (
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
-	"github.com/golang-jwt/jwt"
-	// "github.com/ndk123-web/fast-todo/internal/config"
-	cfg "github.com/ndk123-web/fast-todo/internal/config"
-	// "github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/internal/identity"
+	"github.com/ndk123-web/fast-todo/internal/middleware"
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
 	"github.com/ndk123-web/fast-todo/internal/service"
 )
 
@@ -21,10 +20,43 @@ type UserHandler interface {
 	RefreshToken(w http.ResponseWriter, r *http.Request)
 	SignInUser(w http.ResponseWriter, r *http.Request)
 	UpdateUserName(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	LogoutAll(w http.ResponseWriter, r *http.Request)
+	ForgotPassword(w http.ResponseWriter, r *http.Request)
+	ResetPassword(w http.ResponseWriter, r *http.Request)
+	VerifyEmail(w http.ResponseWriter, r *http.Request)
+	ResendVerificationEmail(w http.ResponseWriter, r *http.Request)
+	ChangePassword(w http.ResponseWriter, r *http.Request)
+	SetPassword(w http.ResponseWriter, r *http.Request)
+	LinkGoogle(w http.ResponseWriter, r *http.Request)
+	UnlinkGoogle(w http.ResponseWriter, r *http.Request)
+	LinkIdentity(w http.ResponseWriter, r *http.Request)
+	UnlinkIdentity(w http.ResponseWriter, r *http.Request)
+	ListProviders(w http.ResponseWriter, r *http.Request)
 }
 
 type userHandler struct {
-	service service.UserService
+	service   service.UserService
+	providers *identity.Registry
+}
+
+// signInProvider is the provider a sign up / sign in body asks for, older clients
+// only know Google and send googleLogin: true instead of a provider name
+func signInProvider(provider string, googleLogin bool) string {
+	if provider == "" && googleLogin {
+		return model.GoogleProvider
+	}
+	return provider
+}
+
+// writeIdentityStatus sets 400 for a provider that isn't configured, 401 for a refused ID token
+func writeIdentityStatus(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, identity.ErrUnknownProvider):
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.Is(err, identity.ErrInvalidIDToken):
+		w.WriteHeader(http.StatusUnauthorized)
+	}
 }
 
 type getUserTodoStruct struct {
@@ -42,7 +74,7 @@ func (h *userHandler) GetUserTodos(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	userTodos, err2 := h.service.GetUserTodos(context.Background(), userStruct.UserId)
+	userTodos, err2 := h.service.GetUserTodos(r.Context(), userStruct.UserId)
 	if err2 != nil {
 		json.NewEncoder(w).Encode(map[string]string{"Error": err2.Error()})
 	}
@@ -55,7 +87,10 @@ type signUpBody struct {
 	Password    string `json:"password"`
 	FullName    string `json:"fullName"`
 	GoogleLogin bool   `json:"googleLogin"`
+	Provider    string `json:"provider"`
 	IdToken     string `json:"idToken"`
+	// Nonce of the auth request that got IdToken, optional
+	Nonce string `json:"nonce"`
 }
 
 // sign up user handler
@@ -66,32 +101,16 @@ func (h *userHandler) SignUpUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fmt.Println("Google Login: ", bodyResponse.GoogleLogin)
-
-	if bodyResponse.GoogleLogin {
-		if bodyResponse.IdToken == "" { // idToken required for google flow
-			json.NewEncoder(w).Encode(map[string]string{"Error": "Missing Google ID token"})
-			return
-		}
-		token, err := cfg.FirebaseAuth.VerifyIDToken(context.Background(), bodyResponse.IdToken)
+	// Branch: sign up with an identity provider (google, oidc...)
+	if provider := signInProvider(bodyResponse.Provider, bodyResponse.GoogleLogin); provider != "" {
+		external, err := h.providers.Verify(r.Context(), provider, bodyResponse.IdToken, bodyResponse.Nonce)
 		if err != nil {
-			json.NewEncoder(w).Encode(map[string]string{"Error": "Invalid Google Token"})
-			return
-		}
-
-		emailClaim, ok := token.Claims["email"].(string)
-		if !ok || emailClaim == "" {
-			json.NewEncoder(w).Encode(map[string]string{"Error": "Email Missing in Google Token"})
-			return
-		}
-
-		nameClaim, ok := token.Claims["name"].(string)
-		if !ok || nameClaim == "" {
-			json.NewEncoder(w).Encode(map[string]string{"Error": "Name Missing in Google Token"})
+			writeIdentityStatus(w, err)
+			json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
 			return
 		}
 
-		result, err := h.service.SignUpWithGoogle(context.Background(), emailClaim, nameClaim)
+		result, err := h.service.SignUpWithIdentity(r.Context(), external, clientInfo(r))
 		if err != nil {
 			json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
 			return
@@ -106,7 +125,7 @@ func (h *userHandler) SignUpUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err2 := h.service.SignUpUser(context.Background(), bodyResponse.Email, bodyResponse.Password, bodyResponse.FullName)
+	result, err2 := h.service.SignUpUser(r.Context(), bodyResponse.Email, bodyResponse.Password, bodyResponse.FullName, clientInfo(r))
 	if err2 != nil {
 		json.NewEncoder(w).Encode(map[string]string{"Error": err2.Error()})
 		return
@@ -120,6 +139,7 @@ func (h *userHandler) SignUpUser(w http.ResponseWriter, r *http.Request) {
 }
 
 // refresh token user handler
+// every call rotates the refresh token, the old one is dead after this returns
 func (h *userHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	refreshToken := r.Header.Get("Authorization")
 
@@ -132,43 +152,66 @@ func (h *userHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	refreshToken = strings.TrimPrefix(refreshToken, "Bearer ")
 	refreshToken = strings.TrimSpace(refreshToken)
 
-	claims := jwt.MapClaims{}
-	_, err := jwt.ParseWithClaims(refreshToken, claims, func(t *jwt.Token) (interface{}, error) {
-		return []byte(service.JWTSECRET), nil
-	})
-
+	tokenStr, refreshStr, err := h.service.RefreshToken(r.Context(), refreshToken)
 	if err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenReused) {
+			fmt.Println("Refresh token reuse detected, session revoked")
+		}
 		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
 		return
 	}
 
-	// create new access token
-	newAccess := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"email": claims["email"],
-		"exp":   time.Now().Add(15 * time.Minute).Unix(),
-	})
-
 	// w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
 
-	tokenStr, _ := newAccess.SignedString([]byte(service.JWTSECRET))
+	json.NewEncoder(w).Encode(map[string]string{"_accessToken": tokenStr, "_refreshToken": refreshStr, "success": "true"})
+}
 
-	// create refresh token too if needed
-	newRefresh := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"email": claims["email"],
-		"exp":   time.Now().Add(7 * 24 * time.Hour).Unix(),
-	})
-	refreshStr, _ := newRefresh.SignedString([]byte(service.JWTSECRET))
+// Logout revokes the session of the access token used for this request
+func (h *userHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	sessionId, _ := r.Context().Value(middleware.SessionId).(string)
+	if !ok || sessionId == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-	json.NewEncoder(w).Encode(map[string]string{"_accessToken": tokenStr, "_refreshToken": refreshStr, "success": "true"})
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.Logout(r.Context(), userId, sessionId); err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Logged Out", "success": "true"})
+}
+
+// LogoutAll revokes every session of the caller, including the current one
+func (h *userHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.LogoutAll(r.Context(), userId); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Logged Out From All Sessions", "success": "true"})
 }
 
 type userSignInBody struct {
 	Email       string `json:"email"`
 	Password    string `json:"password"`
 	GoogleLogin bool   `json:"googleLogin"`
+	Provider    string `json:"provider"`
 	IdToken     string `json:"idToken"`
+	// Nonce of the auth request that got IdToken, optional
+	Nonce string `json:"nonce"`
 }
 
 // sign in user handler
@@ -180,26 +223,17 @@ func (h *userHandler) SignInUser(w http.ResponseWriter, r *http.Request) {
 	}
 	fmt.Println("user Sign in body: ", body)
 
-	// Branch: Google login
-	if body.GoogleLogin {
-		if body.IdToken == "" { // idToken required for google flow
-			json.NewEncoder(w).Encode(map[string]string{"Error": "Missing Google ID token", "success": "false"})
-			return
-		}
-		// Verify ID token with Firebase
-		token, err := cfg.FirebaseAuth.VerifyIDToken(context.Background(), body.IdToken)
+	// Branch: identity provider login (google, oidc...)
+	if provider := signInProvider(body.Provider, body.GoogleLogin); provider != "" {
+		external, err := h.providers.Verify(r.Context(), provider, body.IdToken, body.Nonce)
 		if err != nil {
-			json.NewEncoder(w).Encode(map[string]string{"Error": "Invalid Google token", "success": "false"})
-			return
-		}
-		emailClaim, ok := token.Claims["email"].(string)
-		if !ok || emailClaim == "" {
-			json.NewEncoder(w).Encode(map[string]string{"Error": "Email missing in Google token", "success": "false"})
+			writeIdentityStatus(w, err)
+			json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
 			return
 		}
-		nameClaim, _ := token.Claims["name"].(string)
-		resp, err := h.service.SignInGoogleUser(context.Background(), emailClaim, nameClaim)
+		resp, err := h.service.SignInWithIdentity(r.Context(), external, clientInfo(r))
 		if err != nil {
+			writeSignInStatus(w, err)
 			json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
 			return
 		}
@@ -213,8 +247,9 @@ func (h *userHandler) SignInUser(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{"Error": "Email/Password Empty", "success": "false"})
 		return
 	}
-	resp, err := h.service.SignInUser(context.Background(), body.Email, body.Password)
+	resp, err := h.service.SignInUser(r.Context(), body.Email, body.Password, clientInfo(r))
 	if err != nil {
+		writeSignInStatus(w, err)
 		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
 		return
 	}
@@ -239,7 +274,13 @@ func (h *userHandler) UpdateUserName(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	isUpdated, err := h.service.UpdateUserName(context.Background(), userId, reqBody.NewName)
+	// users can only rename themselves
+	userId, ok := resolveCaller(w, r, userId)
+	if !ok {
+		return
+	}
+
+	isUpdated, err := h.service.UpdateUserName(r.Context(), userId, reqBody.NewName)
 	if err != nil || !isUpdated {
 		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
 		return
@@ -248,8 +289,229 @@ func (h *userHandler) UpdateUserName(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"response": "Success Update User Name", "success": "true"})
 }
 
-func NewUserHandler(service service.UserService) UserHandler {
+type forgotPasswordBody struct {
+	Email string `json:"email"`
+}
+
+// ForgotPassword always answers the same way so it doesn't reveal which emails have accounts
+func (h *userHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var reqBody forgotPasswordBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	if reqBody.Email == "" {
+		json.NewEncoder(w).Encode(map[string]string{"Error": "Email is Empty", "success": "false"})
+		return
+	}
+
+	if err := h.service.ForgotPassword(r.Context(), reqBody.Email); err != nil {
+		// logged only, the client gets the generic answer below
+		fmt.Println("ForgotPassword error:", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"response": "If an account exists for this email, a reset link has been sent", "success": "true"})
+}
+
+type resetPasswordBody struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+func (h *userHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var reqBody resetPasswordBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.ResetPassword(r.Context(), reqBody.Token, reqBody.NewPassword); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Password Reset Successfully", "success": "true"})
+}
+
+type verifyEmailBody struct {
+	Token string `json:"token"`
+}
+
+func (h *userHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var reqBody verifyEmailBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.VerifyEmail(r.Context(), reqBody.Token); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Email Verified", "success": "true"})
+}
+
+func (h *userHandler) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.ResendVerificationEmail(r.Context(), userId); err != nil {
+		writeRateLimitStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Verification Email Sent", "success": "true"})
+}
+
+type changePasswordBody struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// ChangePassword keeps the current session signed in and signs out the others
+func (h *userHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	sessionId, _ := r.Context().Value(middleware.SessionId).(string)
+
+	var reqBody changePasswordBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.ChangePassword(r.Context(), userId, sessionId, reqBody.CurrentPassword, reqBody.NewPassword); err != nil {
+		writeRateLimitStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Password Changed", "success": "true"})
+}
+
+type setPasswordBody struct {
+	NewPassword string `json:"newPassword"`
+}
+
+// SetPassword is for accounts created with Google that never had a password
+func (h *userHandler) SetPassword(w http.ResponseWriter, r *http.Request) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var reqBody setPasswordBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.SetPassword(r.Context(), userId, reqBody.NewPassword); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Password Set", "success": "true"})
+}
+
+type linkIdentityBody struct {
+	IdToken string `json:"idToken"`
+	Nonce   string `json:"nonce"`
+}
+
+// LinkGoogle is the route clients used before other providers existed, same as LinkIdentity for "google"
+func (h *userHandler) LinkGoogle(w http.ResponseWriter, r *http.Request) {
+	h.linkIdentity(w, r, model.GoogleProvider)
+}
+
+func (h *userHandler) UnlinkGoogle(w http.ResponseWriter, r *http.Request) {
+	h.unlinkIdentity(w, r, model.GoogleProvider)
+}
+
+// LinkIdentity: POST /api/v1/users/identities/{provider}, links the identity of idToken
+// to the caller so both logins reach one account
+func (h *userHandler) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	h.linkIdentity(w, r, r.PathValue("provider"))
+}
+
+func (h *userHandler) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	h.unlinkIdentity(w, r, r.PathValue("provider"))
+}
+
+func (h *userHandler) linkIdentity(w http.ResponseWriter, r *http.Request, provider string) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var reqBody linkIdentityBody
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	external, err := h.providers.Verify(r.Context(), provider, reqBody.IdToken, reqBody.Nonce)
+	if err != nil {
+		writeIdentityStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	if err := h.service.LinkIdentity(r.Context(), userId, external); err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Account Linked", "success": "true"})
+}
+
+func (h *userHandler) unlinkIdentity(w http.ResponseWriter, r *http.Request, provider string) {
+	userId, ok := callerId(r)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.service.UnlinkIdentity(r.Context(), userId, provider); err != nil {
+		writeErrorStatus(w, err)
+		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "success": "false"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"response": "Account Unlinked", "success": "true"})
+}
+
+// ListProviders: GET /api/v1/auth/providers, the sign in providers configured on this server
+func (h *userHandler) ListProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"response": h.providers.Names(), "success": "true"})
+}
+
+func NewUserHandler(service service.UserService, providers *identity.Registry) UserHandler {
 	return &userHandler{
-		service: service,
+		service:   service,
+		providers: providers,
 	}
 }
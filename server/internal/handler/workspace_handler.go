@@ -3,10 +3,12 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/ndk123-web/fast-todo/internal/middleware"
+	"github.com/ndk123-web/fast-todo/internal/repository"
 	"github.com/ndk123-web/fast-todo/internal/service"
 )
 
@@ -26,9 +28,12 @@ func (h *workspaceHandler) GetAllUserWorkspace(w http.ResponseWriter, r *http.Re
 
 	// get the userId fromt the Query ?userId=ado13
 	values := r.URL.Query()
-	var userId string = values.Get("userId")
+	userId, ok := resolveCaller(w, r, values.Get("userId"))
+	if !ok {
+		return
+	}
 
-	workspaces, err := h.service.GetAllUserWorkspace(context.Background(), userId)
+	workspaces, err := h.service.GetAllUserWorkspace(r.Context(), userId)
 
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error(), "Success": "false"})
@@ -50,8 +55,13 @@ func (h *workspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if requestBody.WokspaceName == "" || requestBody.UserId == "" {
-		http.Error(w, "Empty Workspace Name / UserId", 401)
+	if requestBody.WokspaceName == "" {
+		http.Error(w, "Empty Workspace Name", 401)
+		return
+	}
+
+	userId, ok := resolveCaller(w, r, requestBody.UserId)
+	if !ok {
 		return
 	}
 
@@ -68,7 +78,7 @@ func (h *workspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Reques
 	// debug
 	fmt.Println("User Email in Create Workspace: ", userEmail)
 
-	workspaceId, err := h.service.CreateWorkspace(context.Background(), requestBody.UserId, requestBody.WokspaceName)
+	workspaceId, err := h.service.CreateWorkspace(r.Context(), userId, requestBody.WokspaceName)
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]any{"response": map[string]any{"success": "false", "Error": err.Error()}})
 		return
@@ -91,13 +101,19 @@ func (h *workspaceHandler) UpdateWorkspace(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if updateBody.UserId == "" || updateBody.WorkspaceName == "" {
-		json.NewEncoder(w).Encode(map[string]string{"Error": "UserId / Workspace Name is Empty"})
+	if updateBody.WorkspaceName == "" {
+		json.NewEncoder(w).Encode(map[string]string{"Error": "Workspace Name is Empty"})
 		return
 	}
 
-	err = h.service.UpdatedWorkspace(context.Background(), updateBody.UserId, updateBody.WorkspaceName, updateBody.UpdatedWorkspaceName)
+	userId, ok := resolveCaller(w, r, updateBody.UserId)
+	if !ok {
+		return
+	}
+
+	err = h.service.UpdatedWorkspace(r.Context(), userId, updateBody.WorkspaceName, updateBody.UpdatedWorkspaceName)
 	if err != nil {
+		writeErrorStatus(w, err)
 		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
 		return
 	}
@@ -122,15 +138,21 @@ func (h *workspaceHandler) DeleteWorkspace(w http.ResponseWriter, r *http.Reques
 	}
 
 	// validate
-	if deleteBody.UserId == "" || deleteBody.WorkspaceName == "" {
-		json.NewEncoder(w).Encode(map[string]string{"Error": "UserId / Workspace Name is Empty"})
+	if deleteBody.WorkspaceName == "" {
+		json.NewEncoder(w).Encode(map[string]string{"Error": "Workspace Name is Empty"})
+		return
+	}
+
+	userId, ok := resolveCaller(w, r, deleteBody.UserId)
+	if !ok {
 		return
 	}
 
 	// call the service delete method
-	err := h.service.DeleteWorkspace(context.Background(), deleteBody.UserId, deleteBody.WorkspaceName)
+	err := h.service.DeleteWorkspace(r.Context(), userId, deleteBody.WorkspaceName)
 	if err != nil {
 		// error response
+		writeErrorStatus(w, err)
 		json.NewEncoder(w).Encode(map[string]string{"Error": err.Error()})
 		return
 	}
@@ -159,15 +181,25 @@ func (h *workspaceHandler) UpdateWorkspaceLayout(w http.ResponseWriter, r *http.
 		return
 	}
 
+	userId, ok := resolveCaller(w, r, "")
+	if !ok {
+		return
+	}
+
 	// Call service to update layout
 	isUpdated, err := h.service.UpdateWorkspaceLayout(
 		context.Background(),
+		userId,
 		workspaceId,
 		reqBody.InitialNodes,
 		reqBody.InitialEdges,
 	)
 
 	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "Workspace not found", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Failed to update layout: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,44 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+
+	"firebase.google.com/go/auth"
+	"github.com/ndk123-web/fast-todo/internal/model"
+)
+
+// firebaseProvider verifies Firebase ID tokens, this is the "google" sign in of the client
+type firebaseProvider struct {
+	client *auth.Client
+}
+
+func NewFirebaseProvider(client *auth.Client) Provider {
+	return &firebaseProvider{client: client}
+}
+
+func (p *firebaseProvider) Name() string {
+	return model.GoogleProvider
+}
+
+func (p *firebaseProvider) Verify(ctx context.Context, idToken string, nonce string) (*model.ExternalIdentity, error) {
+	token, err := p.client.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if tokenNonce, _ := token.Claims["nonce"].(string); nonce != "" && tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	email, _ := token.Claims["email"].(string)
+	verified, _ := token.Claims["email_verified"].(bool)
+	name, _ := token.Claims["name"].(string)
+
+	return &model.ExternalIdentity{
+		Provider:      model.GoogleProvider,
+		Subject:       token.UID,
+		Email:         email,
+		EmailVerified: verified,
+		Name:          name,
+	}, nil
+}
@@ -0,0 +1,187 @@
+package identity
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/pkg/njwt"
+)
+
+// an unknown kid triggers a JWKS refetch (the issuer rotated), but at most once per interval
+// so tokens with made up kids can't make us hammer the issuer
+const jwksRefreshInterval = time.Minute
+
+// oidcProvider verifies ID tokens of any OpenID Connect issuer, the signing keys come from
+// the jwks_uri of the issuer's discovery document and are cached until a token needs a new one
+type oidcProvider struct {
+	name     string
+	issuer   string
+	clientID string
+	client   *http.Client
+
+	mu          sync.Mutex
+	jwksURI     string
+	keys        map[string]crypto.PublicKey
+	lastFetched time.Time
+}
+
+type discoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// NewOIDCProvider needs the issuer URL (discovery is at {issuer}/.well-known/openid-configuration)
+// and the client id tokens must be issued for, client may be nil to use http.DefaultClient
+func NewOIDCProvider(name string, issuer string, clientID string, client *http.Client) Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &oidcProvider{
+		name:     name,
+		issuer:   strings.TrimSuffix(issuer, "/"),
+		clientID: clientID,
+		client:   client,
+	}
+}
+
+func (p *oidcProvider) Name() string {
+	return p.name
+}
+
+func (p *oidcProvider) Verify(ctx context.Context, idToken string, nonce string) (*model.ExternalIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "EdDSA"}),
+		jwt.WithIssuer(p.issuer),
+		jwt.WithAudience(p.clientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	// a token replayed from another auth request carries another nonce
+	if tokenNonce, _ := claims["nonce"].(string); nonce != "" && tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	subject, _ := claims["sub"].(string)
+	email, _ := claims["email"].(string)
+	name, _ := claims["name"].(string)
+
+	// some issuers send email_verified as a string
+	verified := false
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+
+	return &model.ExternalIdentity{
+		Provider:      p.name,
+		Subject:       subject,
+		Email:         strings.ToLower(email),
+		EmailVerified: verified,
+		Name:          name,
+	}, nil
+}
+
+// key returns the public key for kid, fetching the JWKS on first use or when kid is unknown
+func (p *oidcProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	if p.keys != nil && time.Since(p.lastFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	if err := p.fetchKeys(ctx); err != nil {
+		return nil, err
+	}
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup finds kid in the cached keys, a token without kid is fine if the issuer has a single key
+func (p *oidcProvider) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *oidcProvider) fetchKeys(ctx context.Context) error {
+	if p.jwksURI == "" {
+		var doc discoveryDocument
+		if err := p.getJSON(ctx, p.issuer+"/.well-known/openid-configuration", &doc); err != nil {
+			return fmt.Errorf("oidc discovery: %w", err)
+		}
+		if strings.TrimSuffix(doc.Issuer, "/") != p.issuer {
+			return fmt.Errorf("oidc discovery: issuer mismatch, got %q", doc.Issuer)
+		}
+		if doc.JWKSURI == "" {
+			return errors.New("oidc discovery: no jwks_uri")
+		}
+		p.jwksURI = doc.JWKSURI
+	}
+
+	var set njwt.JWKSet
+	if err := p.getJSON(ctx, p.jwksURI, &set); err != nil {
+		return fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			// keys of types we don't know are skipped, the others may still verify
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	p.keys = keys
+	p.lastFetched = time.Now()
+	return nil
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,172 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/ndk123-web/fast-todo/pkg/njwt"
+)
+
+const testClientID = "fast-todo-test"
+
+// fakeIssuer is an OpenID Connect issuer serving discovery and a JWKS with one RSA key
+type fakeIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &fakeIssuer{key: key, kid: "key-1"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(discoveryDocument{Issuer: issuer.server.URL, JWKSURI: issuer.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(njwt.JWKSet{Keys: []njwt.JWK{{
+			Kty: "RSA",
+			Kid: issuer.kid,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+// claims are the claims of a token the fake issuer would hand out for testClientID
+func (f *fakeIssuer) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            f.server.URL,
+		"aud":            testClientID,
+		"sub":            "subject-1",
+		"email":          "Someone@Example.com",
+		"email_verified": "true",
+		"name":           "Someone",
+		"nonce":          "nonce-1",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+}
+
+func (f *fakeIssuer) sign(t *testing.T, claims jwt.MapClaims, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(f.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestOIDCProviderVerify(t *testing.T) {
+	issuer := newFakeIssuer(t)
+
+	tests := []struct {
+		name    string
+		change  func(claims jwt.MapClaims)
+		kid     string
+		nonce   string
+		wantErr bool
+	}{
+		{name: "valid token", nonce: "nonce-1"},
+		{name: "valid token, no nonce asked", nonce: ""},
+		{name: "wrong audience", change: func(c jwt.MapClaims) { c["aud"] = "another-client" }, wantErr: true},
+		{name: "wrong issuer", change: func(c jwt.MapClaims) { c["iss"] = "https://issuer.example.com" }, wantErr: true},
+		{name: "wrong nonce", nonce: "nonce-2", wantErr: true},
+		{name: "nonce asked, token has none", change: func(c jwt.MapClaims) { delete(c, "nonce") }, nonce: "nonce-1", wantErr: true},
+		{name: "expired token", change: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, wantErr: true},
+		{name: "no expiry", change: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: true},
+		{name: "unknown kid", kid: "key-2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// a provider per case, an unknown kid would otherwise hold back the refetch of the next one
+			provider := NewOIDCProvider("test", issuer.server.URL, testClientID, issuer.server.Client())
+
+			claims := issuer.claims()
+			if tt.change != nil {
+				tt.change(claims)
+			}
+			kid := tt.kid
+			if kid == "" {
+				kid = issuer.kid
+			}
+
+			identity, err := provider.Verify(context.Background(), issuer.sign(t, claims, kid), tt.nonce)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidIDToken) {
+					t.Fatalf("Verify() error = %v, want ErrInvalidIDToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify() error = %v", err)
+			}
+			if identity.Provider != "test" || identity.Subject != "subject-1" || identity.Email != "someone@example.com" || !identity.EmailVerified || identity.Name != "Someone" {
+				t.Fatalf("Verify() = %+v", identity)
+			}
+		})
+	}
+}
+
+func TestOIDCProviderRejectsOtherSigner(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	other := newFakeIssuer(t)
+	provider := NewOIDCProvider("test", issuer.server.URL, testClientID, issuer.server.Client())
+
+	// right claims and kid, signed with a key the issuer never published
+	if _, err := provider.Verify(context.Background(), other.sign(t, issuer.claims(), issuer.kid), ""); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("Verify() error = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestOIDCProviderDiscoveryIssuerMismatch(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	// the discovery document names issuer.server.URL, not this one
+	provider := NewOIDCProvider("test", issuer.server.URL+"/tenant", testClientID, issuer.server.Client())
+
+	claims := issuer.claims()
+	claims["iss"] = issuer.server.URL + "/tenant"
+	if _, err := provider.Verify(context.Background(), issuer.sign(t, claims, issuer.kid), ""); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("Verify() error = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestRegistryVerify(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	registry := NewRegistry(NewOIDCProvider("test", issuer.server.URL, testClientID, issuer.server.Client()))
+	token := issuer.sign(t, issuer.claims(), issuer.kid)
+
+	if _, err := registry.Verify(context.Background(), "unknown", token, ""); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("Verify(unknown) error = %v, want ErrUnknownProvider", err)
+	}
+	if _, err := registry.Verify(context.Background(), "test", "", ""); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("Verify(empty token) error = %v, want ErrInvalidIDToken", err)
+	}
+	if _, err := registry.Verify(context.Background(), "test", token, "nonce-1"); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+// Package identity verifies ID tokens of external sign in providers (Firebase / Google, OpenID Connect)
+package identity
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+)
+
+var (
+	// ErrUnknownProvider is returned for a provider name that isn't configured
+	ErrUnknownProvider = errors.New("unknown sign in provider")
+	// ErrInvalidIDToken covers every reason a token is refused (signature, issuer, audience, expiry...)
+	ErrInvalidIDToken = errors.New("invalid or expired ID token")
+)
+
+// Provider verifies an ID token issued by one external provider, nonce is the one the
+// client sent in the auth request, "" when it didn't use one
+type Provider interface {
+	Name() string
+	Verify(ctx context.Context, idToken string, nonce string) (*model.ExternalIdentity, error)
+}
+
+// Registry holds the configured providers by name, the sign in handlers dispatch on it
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	registry := &Registry{providers: map[string]Provider{}}
+	for _, provider := range providers {
+		registry.providers[provider.Name()] = provider
+	}
+	return registry
+}
+
+// Verify checks idToken with the provider called name, a token for another nonce is refused
+func (r *Registry) Verify(ctx context.Context, name string, idToken string, nonce string) (*model.ExternalIdentity, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if idToken == "" {
+		return nil, ErrInvalidIDToken
+	}
+
+	identity, err := provider.Verify(ctx, idToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	if identity.Subject == "" || identity.Email == "" {
+		return nil, errors.New("ID token has no subject / email")
+	}
+	return identity, nil
+}
+
+// Names lists the configured providers so the client knows which buttons to show
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -3,17 +3,14 @@ package middleware
 import (
 	"context"
 	// "encoding/json"
-	"fmt"
 	"net/http"
-	"os"
 	"strings"
 
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/pkg/njwt"
 	// FirebaseAuth "github.com/ndk123-web/fast-todo/internal/config"
 )
 
-var JwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
 // we created the custom string type to inject the userEmail in context , to forward handlers
 // why ? becuase if any package also doing with same name and type string then it will overwrite
 // so instead what we do is just create the new type of contextKey
@@ -21,6 +18,113 @@ type contextKey string
 
 const UserEmailKey contextKey = "userEmail"
 const UserId contextKey = "userId"
+const SessionId contextKey = "sessionId"
+
+// Scopes is only set for personal access tokens, a JWT session can call every route
+const Scopes contextKey = "scopes"
+
+// SessionChecker is satisfied by repository.SessionRepository
+// kept as a small interface so middleware doesn't depend on the repository package
+// TouchSession reports whether the session is alive and bumps its last seen time
+type SessionChecker interface {
+	TouchSession(ctx context.Context, sessionId string) (bool, error)
+}
+
+var sessionChecker SessionChecker
+
+// InitAuth wires the session store used to reject tokens of logged out sessions
+// must be called before the server starts, like config.InitRedis
+func InitAuth(sessions SessionChecker) {
+	sessionChecker = sessions
+}
+
+// AccessTokenChecker is satisfied by service.AccessTokenService
+type AccessTokenChecker interface {
+	IsAccessToken(token string) bool
+	AuthenticateAccessToken(ctx context.Context, token string) (*model.AccessToken, error)
+}
+
+var accessTokenChecker AccessTokenChecker
+
+// InitAccessTokens lets AuthMiddleware accept personal access tokens next to JWTs
+func InitAccessTokens(tokens AccessTokenChecker) {
+	accessTokenChecker = tokens
+}
+
+// VerificationChecker is satisfied by repository.UserRepository
+type VerificationChecker interface {
+	IsEmailVerified(ctx context.Context, userId string) (bool, error)
+}
+
+var verificationChecker VerificationChecker
+var requireVerifiedEmail bool
+
+// InitEmailVerification turns RequireVerifiedEmail on when required is true (REQUIRE_EMAIL_VERIFICATION)
+func InitEmailVerification(users VerificationChecker, required bool) {
+	verificationChecker = users
+	requireVerifiedEmail = required
+}
+
+// RequireVerifiedEmail blocks users that haven't opened their verification link yet
+// must run after AuthMiddleware, it is a no-op unless enabled in config
+func RequireVerifiedEmail(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		verified, err := EmailVerified(r.Context())
+		if err != nil {
+			http.Error(w, "Verification lookup failed", http.StatusInternalServerError)
+			return
+		}
+		if !verified {
+			http.Error(w, "Email not verified", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// EmailVerified is the check of RequireVerifiedEmail for handlers that only need it for some
+// requests (a batch with a create in it), always true unless enabled in config
+func EmailVerified(ctx context.Context) (bool, error) {
+	if !requireVerifiedEmail {
+		return true, nil
+	}
+
+	userId, _ := ctx.Value(UserId).(string)
+	return verificationChecker.IsEmailVerified(ctx, userId)
+}
+
+// RoleChecker is satisfied by repository.UserRepository
+type RoleChecker interface {
+	GetUserRole(ctx context.Context, userId string) (string, error)
+}
+
+var roleChecker RoleChecker
+
+// InitRoles wires the user store RequireRole reads roles from
+func InitRoles(users RoleChecker) {
+	roleChecker = users
+}
+
+// RequireRole lets only users with role through, the role is read from the user document
+// on every request so a demoted admin loses access right away instead of at token expiry
+// must run after AuthMiddleware
+func RequireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userId, _ := r.Context().Value(UserId).(string)
+		userRole, err := roleChecker.GetUserRole(r.Context(), userId)
+		if err != nil {
+			http.Error(w, "Role lookup failed", http.StatusInternalServerError)
+			return
+		}
+		if userRole != role {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
 
 // type AuthReq struct {
 // 	Email       string `json:"email"`
@@ -74,40 +178,105 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		tokenString = strings.TrimSpace(tokenString)
 
-		// parse and verify jwt
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-
-			// (*jwt.SigningMethodHMAC) it means Method is interface and jwt.SigningMethodHMAC implementing the interface Method
-			// thats why we wrote like .(*jwt.SigningMethodHMAC)
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method")
-			}
-
-			// return the JwtSecret to the jwt.Parse
-			return JwtSecret, nil
-		})
+		if accessTokenChecker != nil && accessTokenChecker.IsAccessToken(tokenString) {
+			authenticateAccessToken(w, r, next, tokenString)
+			return
+		}
 
+		// parse and verify jwt, njwt.ParseToken picks the key by kid and checks the token type
+		// refresh tokens are only accepted by the refresh endpoint
+		claims, err := njwt.ParseToken(tokenString, "access")
 		if err != nil {
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 			return
 		}
 
-		// now just validate the token
-		claims, ok := token.Claims.(jwt.MapClaims)
+		userEmail, ok := claims["email"].(string)
 		if !ok {
-			http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+			http.Error(w, "Invalid token payload", http.StatusUnauthorized)
 			return
 		}
 
-		userEmail, ok := claims["email"].(string)
-		if !ok {
+		// tokens issued before userId was stamped on them can't be scoped to an owner
+		userId, ok := claims["userId"].(string)
+		if !ok || userId == "" {
 			http.Error(w, "Invalid token payload", http.StatusUnauthorized)
 			return
 		}
 
-		//  Inject email into context
+		// a logged out session kills its access tokens right away instead of at expiry
+		sessionId, _ := claims["sid"].(string)
+		active, err := sessionChecker.TouchSession(r.Context(), sessionId)
+		if err != nil {
+			http.Error(w, "Session lookup failed", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			http.Error(w, "Session revoked or expired", http.StatusUnauthorized)
+			return
+		}
+
+		//  Inject email, userId and sessionId into context
 		ctx := context.WithValue(r.Context(), UserEmailKey, userEmail)
+		ctx = context.WithValue(ctx, UserId, userId)
+		ctx = context.WithValue(ctx, SessionId, sessionId)
 		//  Call next handler with updated context
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// authenticateAccessToken handles requests made with a personal access token
+// the token's scopes go in the context, RequireScope checks them per route
+func authenticateAccessToken(w http.ResponseWriter, r *http.Request, next http.Handler, tokenString string) {
+	token, err := accessTokenChecker.AuthenticateAccessToken(r.Context(), tokenString)
+	if err != nil {
+		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), UserEmailKey, token.Email)
+	ctx = context.WithValue(ctx, UserId, token.UserId.Hex())
+	ctx = context.WithValue(ctx, Scopes, token.Scopes)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// HasScope reports whether the caller may use scope, for handlers whose answer depends on it
+// must run after AuthMiddleware, JWT sessions have every scope
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, isAccessToken := ctx.Value(Scopes).([]string)
+	if !isAccessToken {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope rejects personal access tokens that weren't granted scope
+// must run after AuthMiddleware, JWT sessions always pass
+func RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !HasScope(r.Context(), scope) {
+			http.Error(w, "Token is missing scope "+scope, http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireSession keeps personal access tokens away from account routes (tokens, sessions, 2FA...)
+// so a leaked script token can't mint new tokens or take over the account
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, isAccessToken := r.Context().Value(Scopes).([]string); isAccessToken {
+			http.Error(w, "Personal access tokens can't be used here", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
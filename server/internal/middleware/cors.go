@@ -1,13 +1,131 @@
 package middleware
 
-import "net/http"
+import (
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
 
+// CorsOptions says which browser origins may call the API, set from the CORS_* env vars
+type CorsOptions struct {
+	// AllowedOrigins are exact origins ("https://app.example.com"), "https://*.example.com"
+	// for any subdomain (not the bare domain) or "*" for every origin
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long a browser may cache a preflight answer, 0 leaves it to the browser
+	MaxAge time.Duration
+}
+
+// DefaultCorsOptions is what the server used before CORS was configurable, the vite dev server
+func DefaultCorsOptions() CorsOptions {
+	return CorsOptions{
+		AllowedOrigins:   []string{"http://localhost:5173"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"X-Request-ID", "Retry-After"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+}
+
+var corsOptions = DefaultCorsOptions()
+
+// InitCors replaces the default options, empty lists keep their defaults
+func InitCors(options CorsOptions) {
+	defaults := DefaultCorsOptions()
+	if len(options.AllowedOrigins) == 0 {
+		options.AllowedOrigins = defaults.AllowedOrigins
+	}
+	if len(options.AllowedMethods) == 0 {
+		options.AllowedMethods = defaults.AllowedMethods
+	}
+	if len(options.AllowedHeaders) == 0 {
+		options.AllowedHeaders = defaults.AllowedHeaders
+	}
+	if len(options.ExposedHeaders) == 0 {
+		options.ExposedHeaders = defaults.ExposedHeaders
+	}
+	for i, method := range options.AllowedMethods {
+		options.AllowedMethods[i] = strings.ToUpper(method)
+	}
+
+	// browsers refuse credentials with "Access-Control-Allow-Origin: *", so "*" never sends them
+	if slices.Contains(options.AllowedOrigins, "*") && options.AllowCredentials {
+		log.Println("CORS: credentials are not sent when every origin (*) is allowed")
+		options.AllowCredentials = false
+	}
+
+	corsOptions = options
+}
+
+// originAllowed matches origin against the exact and wildcard subdomain entries
+func originAllowed(origin string, allowed []string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if pattern == origin {
+			return true
+		}
+
+		scheme, host, ok := strings.Cut(pattern, "://*.")
+		if !ok {
+			continue
+		}
+		// "https://*.example.com" matches "https://a.example.com" and "https://a.b.example.com"
+		prefix := scheme + "://"
+		if strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, "."+host) && len(origin) > len(prefix)+len(host)+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// CorsMiddleware answers preflights and adds the CORS headers for allowed origins,
+// other origins get no CORS headers at all so the browser blocks the response
 func CorsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173") // specific origin allowed
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		options := corsOptions
+		origin := r.Header.Get("Origin")
+		anyOrigin := slices.Contains(options.AllowedOrigins, "*")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		// the answer depends on the origin, caches must not hand one origin's answer to another
+		if !anyOrigin {
+			w.Header().Add("Vary", "Origin")
+		}
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if origin != "" && (anyOrigin || originAllowed(origin, options.AllowedOrigins)) {
+			if anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			if options.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if preflight {
+				if slices.Contains(options.AllowedMethods, strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))) {
+					w.Header().Set("Access-Control-Allow-Methods", strings.Join(options.AllowedMethods, ", "))
+					w.Header().Set("Access-Control-Allow-Headers", strings.Join(options.AllowedHeaders, ", "))
+					if options.MaxAge > 0 {
+						w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(options.MaxAge.Seconds())))
+					}
+				}
+			} else if len(options.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(options.ExposedHeaders, ", "))
+			}
+		}
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
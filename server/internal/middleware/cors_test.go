@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"http://localhost:5173", "https://app.example.com", "https://*.example.org"}
+
+	tests := []struct {
+		name   string
+		origin string
+		want   bool
+	}{
+		{name: "exact", origin: "https://app.example.com", want: true},
+		{name: "exact other case", origin: "HTTPS://App.Example.com", want: true},
+		{name: "exact with port", origin: "http://localhost:5173", want: true},
+		{name: "other port", origin: "http://localhost:5174"},
+		{name: "port added", origin: "https://app.example.com:8443"},
+		{name: "wrong scheme", origin: "http://app.example.com"},
+		{name: "subdomain of an exact entry", origin: "https://x.app.example.com"},
+		{name: "wildcard subdomain", origin: "https://a.example.org", want: true},
+		{name: "wildcard nested subdomain", origin: "https://a.b.example.org", want: true},
+		{name: "wildcard bare domain", origin: "https://example.org"},
+		{name: "wildcard empty label", origin: "https://.example.org"},
+		{name: "wildcard wrong scheme", origin: "http://a.example.org"},
+		{name: "wildcard with port", origin: "https://a.example.org:8443"},
+		{name: "wildcard lookalike domain", origin: "https://evilexample.org"},
+		{name: "wildcard as a prefix", origin: "https://a.example.org.evil.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := originAllowed(tt.origin, allowed); got != tt.want {
+				t.Fatalf("originAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+// useCors sets the options for one test and puts the defaults back after it
+func useCors(t *testing.T, options CorsOptions) {
+	t.Helper()
+	InitCors(options)
+	t.Cleanup(func() { corsOptions = DefaultCorsOptions() })
+}
+
+func TestCorsMiddleware(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	tests := []struct {
+		name            string
+		allowed         []string
+		origin          string
+		wantOrigin      string
+		wantCredentials bool
+		wantVary        bool
+	}{
+		{name: "allowed origin", allowed: []string{"https://app.example.com"}, origin: "https://app.example.com", wantOrigin: "https://app.example.com", wantCredentials: true, wantVary: true},
+		{name: "other origin", allowed: []string{"https://app.example.com"}, origin: "https://evil.example.com", wantVary: true},
+		{name: "no origin", allowed: []string{"https://app.example.com"}, wantVary: true},
+		{name: "every origin drops credentials", allowed: []string{"*"}, origin: "https://evil.example.com", wantOrigin: "*"},
+		{name: "every origin among others", allowed: []string{"https://app.example.com", "*"}, origin: "https://app.example.com", wantOrigin: "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useCors(t, CorsOptions{AllowedOrigins: tt.allowed, AllowCredentials: true})
+			r := httptest.NewRequest("GET", "/api/v1/todos", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			CorsMiddleware(next).ServeHTTP(w, r)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Fatalf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tt.wantCredentials {
+				t.Fatalf("credentials = %v, want %v", got, tt.wantCredentials)
+			}
+			if got := slices.Contains(w.Header().Values("Vary"), "Origin"); got != tt.wantVary {
+				t.Fatalf("Vary: Origin = %v, want %v (Vary %v)", got, tt.wantVary, w.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestCorsPreflight(t *testing.T) {
+	useCors(t, CorsOptions{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true, MaxAge: 10 * time.Minute})
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+
+	r := httptest.NewRequest("OPTIONS", "/api/v1/todos", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", "delete")
+	w := httptest.NewRecorder()
+	CorsMiddleware(next).ServeHTTP(w, r)
+
+	if called || w.Code != http.StatusNoContent {
+		t.Fatalf("preflight = %d, handler called %v, want 204 without the handler", w.Code, called)
+	}
+	if w.Header().Get("Access-Control-Allow-Methods") == "" || w.Header().Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("preflight headers = %v", w.Header())
+	}
+	if vary := w.Header().Values("Vary"); !slices.Contains(vary, "Origin") || !slices.Contains(vary, "Access-Control-Request-Method") {
+		t.Fatalf("Vary = %v", vary)
+	}
+}
@@ -3,11 +3,13 @@ package middleware
 import (
 	"log"
 	"net/http"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
 )
 
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Println("Request: ", r.Method, " ", r.URL.Path)
+		log.Println("Request: ", r.Method, " ", r.URL.Path, " ", model.RequestInfoFrom(r.Context()).RequestId)
 
 		// call actual Handler
 		next.ServeHTTP(w, r)
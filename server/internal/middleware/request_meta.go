@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+)
+
+// an incoming X-Request-ID is kept when it looks sane, so ids from a proxy line up with ours
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{8,64}$`)
+
+// RequestMeta gives every request an id (echoed in X-Request-ID) and puts it, the client ip
+// and user agent in the context as model.RequestInfo for logging and the audit log
+func RequestMeta(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get("X-Request-ID")
+		if !requestIdPattern.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+		w.Header().Set("X-Request-ID", requestId)
+
+		ctx := model.WithRequestInfo(r.Context(), model.RequestInfo{
+			RequestId: requestId,
+			IP:        ClientIP(r),
+			UserAgent: r.UserAgent(),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestId() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ClientIP returns the caller ip, X-Forwarded-For is only trusted behind a proxy
+// (TRUST_PROXY_HEADERS=true) because anyone can send that header
+// the client may put anything at the start of the list and each proxy appends the address it
+// got the request from, so the ip is read TRUSTED_PROXY_HOPS (default 1) entries from the right
+func ClientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		if ip := forwardedFor(r.Header.Values("X-Forwarded-For"), trustedProxyHops()); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func trustedProxyHops() int {
+	hops, err := strconv.Atoi(os.Getenv("TRUSTED_PROXY_HOPS"))
+	if err != nil || hops < 1 {
+		return 1
+	}
+	return hops
+}
+
+// forwardedFor picks the entry hops from the right of the X-Forwarded-For headers, "" when
+// the list is shorter (the request didn't pass every proxy) or the entry isn't an ip
+func forwardedFor(headers []string, hops int) string {
+	var entries []string
+	for _, header := range headers {
+		for _, entry := range strings.Split(header, ",") {
+			entries = append(entries, strings.TrimSpace(entry))
+		}
+	}
+	if len(entries) < hops {
+		return ""
+	}
+
+	ip := net.ParseIP(entries[len(entries)-hops])
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
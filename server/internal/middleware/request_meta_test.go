@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trust     string
+		hops      string
+		forwarded []string
+		want      string
+	}{
+		{name: "no proxy", want: "192.0.2.10"},
+		{name: "header ignored without trust", forwarded: []string{"203.0.113.7"}, want: "192.0.2.10"},
+		{name: "one proxy", trust: "true", forwarded: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "spoofed entry in front", trust: "true", forwarded: []string{"1.2.3.4, 203.0.113.7"}, want: "203.0.113.7"},
+		{name: "spoofed header line in front", trust: "true", forwarded: []string{"1.2.3.4", "203.0.113.7"}, want: "203.0.113.7"},
+		{name: "two proxies", trust: "true", hops: "2", forwarded: []string{"1.2.3.4, 203.0.113.7, 10.0.0.2"}, want: "203.0.113.7"},
+		{name: "shorter than the hops", trust: "true", hops: "2", forwarded: []string{"203.0.113.7"}, want: "192.0.2.10"},
+		{name: "not an ip", trust: "true", forwarded: []string{"1.2.3.4, evil"}, want: "192.0.2.10"},
+		{name: "bad hops count", trust: "true", hops: "zero", forwarded: []string{"1.2.3.4, 203.0.113.7"}, want: "203.0.113.7"},
+		{name: "ipv6", trust: "true", forwarded: []string{"2001:DB8::1"}, want: "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUST_PROXY_HEADERS", tt.trust)
+			t.Setenv("TRUSTED_PROXY_HOPS", tt.hops)
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "192.0.2.10:51234"
+			for _, header := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+
+			if got := ClientIP(r); got != tt.want {
+				t.Fatalf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIPSpoofingKeepsTheSameIP(t *testing.T) {
+	t.Setenv("TRUST_PROXY_HEADERS", "true")
+	t.Setenv("TRUSTED_PROXY_HOPS", "")
+
+	// a client sending a new X-Forwarded-For every time still counts as one ip for the lockout
+	seen := map[string]bool{}
+	for _, spoofed := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3, 4.4.4.4"} {
+		r := httptest.NewRequest("POST", "/api/v1/users/signin", nil)
+		r.Header.Set("X-Forwarded-For", spoofed+", 203.0.113.7")
+		seen[ClientIP(r)] = true
+	}
+	if len(seen) != 1 || !seen["203.0.113.7"] {
+		t.Fatalf("ClientIP() gave %v, want only 203.0.113.7", seen)
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+)
+
+// SecurityProfile is the set of security headers sent on every response
+// empty values are left out, so a profile can skip a header (no HSTS over plain http)
+type SecurityProfile struct {
+	StrictTransportSecurity string
+	ContentTypeOptions      string
+	ReferrerPolicy          string
+	ContentSecurityPolicy   string
+	FrameOptions            string
+}
+
+// the API only answers JSON, nothing it returns should load scripts or be framed
+const apiContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'; base-uri 'none'"
+
+// SecurityProfiles are picked with SECURITY_PROFILE, development leaves out HSTS so
+// browsers don't pin http://localhost to https
+var SecurityProfiles = map[string]SecurityProfile{
+	"development": {
+		ContentTypeOptions:    "nosniff",
+		ReferrerPolicy:        "strict-origin-when-cross-origin",
+		ContentSecurityPolicy: apiContentSecurityPolicy,
+		FrameOptions:          "DENY",
+	},
+	"production": {
+		StrictTransportSecurity: "max-age=31536000; includeSubDomains",
+		ContentTypeOptions:      "nosniff",
+		ReferrerPolicy:          "no-referrer",
+		ContentSecurityPolicy:   apiContentSecurityPolicy,
+		FrameOptions:            "DENY",
+	},
+}
+
+var securityProfile = SecurityProfiles["development"]
+
+// InitSecurityHeaders picks the profile by name, contentSecurityPolicy overrides the profile's CSP when set
+func InitSecurityHeaders(profileName string, contentSecurityPolicy string) {
+	profile, ok := SecurityProfiles[profileName]
+	if !ok {
+		log.Printf("SECURITY_PROFILE: unknown profile %q, using development", profileName)
+		profile = SecurityProfiles["development"]
+	}
+	if contentSecurityPolicy != "" {
+		profile.ContentSecurityPolicy = contentSecurityPolicy
+	}
+
+	securityProfile = profile
+}
+
+// SecurityHeaders sets the headers of the configured profile, handlers can still override them
+func SecurityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		profile := securityProfile
+		headers := map[string]string{
+			"Strict-Transport-Security": profile.StrictTransportSecurity,
+			"X-Content-Type-Options":    profile.ContentTypeOptions,
+			"Referrer-Policy":           profile.ReferrerPolicy,
+			"Content-Security-Policy":   profile.ContentSecurityPolicy,
+			"X-Frame-Options":           profile.FrameOptions,
+		}
+		for name, value := range headers {
+			if value != "" {
+				w.Header().Set(name, value)
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		csp     string
+		want    map[string]string
+	}{
+		{
+			name:    "development",
+			profile: "development",
+			want: map[string]string{
+				"Strict-Transport-Security": "",
+				"X-Content-Type-Options":    "nosniff",
+				"Referrer-Policy":           "strict-origin-when-cross-origin",
+				"Content-Security-Policy":   apiContentSecurityPolicy,
+				"X-Frame-Options":           "DENY",
+			},
+		},
+		{
+			name:    "production",
+			profile: "production",
+			want: map[string]string{
+				"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+				"X-Content-Type-Options":    "nosniff",
+				"Referrer-Policy":           "no-referrer",
+				"Content-Security-Policy":   apiContentSecurityPolicy,
+				"X-Frame-Options":           "DENY",
+			},
+		},
+		{
+			name:    "unknown profile falls back to development",
+			profile: "staging",
+			want:    map[string]string{"Strict-Transport-Security": "", "Referrer-Policy": "strict-origin-when-cross-origin"},
+		},
+		{
+			name:    "csp override",
+			profile: "production",
+			csp:     "default-src 'self'",
+			want:    map[string]string{"Content-Security-Policy": "default-src 'self'", "X-Frame-Options": "DENY"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			InitSecurityHeaders(tt.profile, tt.csp)
+			t.Cleanup(func() { securityProfile = SecurityProfiles["development"] })
+
+			w := httptest.NewRecorder()
+			SecurityHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			for name, want := range tt.want {
+				if got := w.Header().Get(name); got != want {
+					t.Fatalf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSecurityHeadersHandlerOverrides(t *testing.T) {
+	InitSecurityHeaders("production", "")
+	t.Cleanup(func() { securityProfile = SecurityProfiles["development"] })
+
+	// a handler serving something framable sets its own X-Frame-Options
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	})
+	w := httptest.NewRecorder()
+	SecurityHeaders(next).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if got := w.Header().Get("X-Frame-Options"); got != "SAMEORIGIN" {
+		t.Fatalf("X-Frame-Options = %q, want the handler's SAMEORIGIN", got)
+	}
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// scopes a personal access token can be given, a JWT session implicitly has all of them
+const (
+	ScopeTodosRead       = "todos:read"
+	ScopeTodosWrite      = "todos:write"
+	ScopeGoalsRead       = "goals:read"
+	ScopeGoalsWrite      = "goals:write"
+	ScopeWorkspacesRead  = "workspaces:read"
+	ScopeWorkspacesAdmin = "workspaces:admin"
+)
+
+var AccessTokenScopes = []string{
+	ScopeTodosRead,
+	ScopeTodosWrite,
+	ScopeGoalsRead,
+	ScopeGoalsWrite,
+	ScopeWorkspacesRead,
+	ScopeWorkspacesAdmin,
+}
+
+// AccessToken is a personal access token used by scripts instead of the sign in tokens
+// only the sha256 of the token is stored, Prefix is kept so users can tell their tokens apart
+type AccessToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
+	UserId     primitive.ObjectID `bson:"userId" json:"userId"`
+	Email      string             `bson:"email" json:"-"`
+	Name       string             `bson:"name" json:"name"`
+	TokenHash  string             `bson:"tokenHash" json:"-"`
+	Prefix     string             `bson:"prefix" json:"prefix"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	ExpiresAt  time.Time          `bson:"expiresAt" json:"expiresAt"`
+	LastUsedAt *time.Time         `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+}
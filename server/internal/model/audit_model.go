@@ -0,0 +1,69 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// audit actions, "<target>.<what happened>"
+const (
+	AuditSignIn          = "auth.signin"
+	AuditSignInFailed    = "auth.signin_failed"
+	AuditTokenRefresh    = "auth.token_refresh"
+	AuditNameChange      = "user.name_change"
+	AuditWorkspaceCreate = "workspace.create"
+	AuditWorkspaceUpdate = "workspace.update"
+	AuditWorkspaceDelete = "workspace.delete"
+	AuditTodoCreate      = "todo.create"
+	AuditTodoUpdate      = "todo.update"
+	AuditTodoDelete      = "todo.delete"
+	AuditGoalCreate      = "goal.create"
+	AuditGoalUpdate      = "goal.update"
+	AuditGoalDelete      = "goal.delete"
+	AuditLabelCreate     = "label.create"
+	AuditLabelUpdate     = "label.update"
+	AuditLabelDelete     = "label.delete"
+)
+
+// AuditEvent is one entry of the append-only audit log ("audit_events")
+// UserId is the account the event belongs to, ActorId who caused it (the same user for now)
+// Before / After are small summaries of the document, never the full thing
+type AuditEvent struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	UserId     primitive.ObjectID `json:"userId,omitempty" bson:"userId,omitempty"`
+	ActorId    primitive.ObjectID `json:"actorId,omitempty" bson:"actorId,omitempty"`
+	Email      string             `json:"email,omitempty" bson:"email,omitempty"`
+	Action     string             `json:"action" bson:"action"`
+	TargetType string             `json:"targetType,omitempty" bson:"targetType,omitempty"`
+	TargetId   string             `json:"targetId,omitempty" bson:"targetId,omitempty"`
+	// Detail says how or why, e.g. the sign in method or why it failed
+	Detail    string         `json:"detail,omitempty" bson:"detail,omitempty"`
+	Before    map[string]any `json:"before,omitempty" bson:"before,omitempty"`
+	After     map[string]any `json:"after,omitempty" bson:"after,omitempty"`
+	IP        string         `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserAgent string         `json:"userAgent,omitempty" bson:"userAgent,omitempty"`
+	RequestId string         `json:"requestId,omitempty" bson:"requestId,omitempty"`
+	CreatedAt time.Time      `json:"createdAt" bson:"createdAt"`
+}
+
+// RequestInfo is what middleware.RequestMeta knows about the current request
+// it travels in the context so services can stamp audit events without seeing the http.Request
+type RequestInfo struct {
+	RequestId string
+	IP        string
+	UserAgent string
+}
+
+type requestInfoKey struct{}
+
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFrom is empty for work that didn't start with a request (background jobs)
+func RequestInfoFrom(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
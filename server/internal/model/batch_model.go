@@ -0,0 +1,80 @@
+package model
+
+import "encoding/json"
+
+// types of a BatchOperation, named after the single endpoints they replace
+const (
+	BatchTodoCreate      = "todo.create"
+	BatchTodoUpdate      = "todo.update"
+	BatchTodoDelete      = "todo.delete"
+	BatchTodoToggle      = "todo.toggle"
+	BatchTodoStatus      = "todo.status"
+	BatchTodoMove        = "todo.move"
+	BatchGoalCreate      = "goal.create"
+	BatchGoalUpdate      = "goal.update"
+	BatchGoalDelete      = "goal.delete"
+	BatchGoalIncrement   = "goal.increment"
+	BatchGoalDecrement   = "goal.decrement"
+	BatchWorkspaceCreate = "workspace.create"
+	BatchWorkspaceUpdate = "workspace.update"
+	BatchWorkspaceDelete = "workspace.delete"
+)
+
+// BatchScopes is the scope a personal access token needs for each operation type,
+// the same one its single endpoint asks for
+var BatchScopes = map[string]string{
+	BatchTodoCreate:      ScopeTodosWrite,
+	BatchTodoUpdate:      ScopeTodosWrite,
+	BatchTodoDelete:      ScopeTodosWrite,
+	BatchTodoToggle:      ScopeTodosWrite,
+	BatchTodoStatus:      ScopeTodosWrite,
+	BatchTodoMove:        ScopeTodosWrite,
+	BatchGoalCreate:      ScopeGoalsWrite,
+	BatchGoalUpdate:      ScopeGoalsWrite,
+	BatchGoalDelete:      ScopeGoalsWrite,
+	BatchGoalIncrement:   ScopeGoalsWrite,
+	BatchGoalDecrement:   ScopeGoalsWrite,
+	BatchWorkspaceCreate: ScopeWorkspacesAdmin,
+	BatchWorkspaceUpdate: ScopeWorkspacesAdmin,
+	BatchWorkspaceDelete: ScopeWorkspacesAdmin,
+}
+
+// BatchOperation is one step of a batch, Payload is the body the single endpoint takes
+// (todo ids go in "id", the workspace of a create in "workspaceId")
+type BatchOperation struct {
+	Type string `json:"type"`
+	// TempId is the id the client gave a created document while offline, later operations
+	// of the batch may use it in "id" / "workspaceId" / "parentId" / "before" / "after" and get the server id
+	TempId  string          `json:"tempId,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// status of a BatchResult
+const (
+	BatchOk         = "ok"
+	BatchFailed     = "failed"
+	BatchRolledBack = "rolled-back" // went through but the transaction was aborted by a later failure
+	BatchSkipped    = "skipped"     // never ran, an earlier operation failed
+)
+
+// BatchResult is the outcome of the operation at Index of the batch
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	// Id is the server id of what a create made
+	Id       string `json:"id,omitempty"`
+	Response any    `json:"response,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// BatchOutcome is the response of a batch, a batch stops at the first failed operation
+type BatchOutcome struct {
+	// Transaction is false when mongo can't run transactions (standalone server),
+	// the operations before a failure are kept then
+	Transaction bool          `json:"transaction"`
+	Succeeded   bool          `json:"succeeded"`
+	Results     []BatchResult `json:"results"`
+	// Ids maps every TempId of a create that went through to its server id
+	Ids map[string]string `json:"ids"`
+}
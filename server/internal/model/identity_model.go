@@ -0,0 +1,31 @@
+package model
+
+import "strings"
+
+// GoogleProvider is the Firebase (Google) provider, it predates the others and
+// keeps its link in the "googleUid" field of the user instead of "identities"
+const GoogleProvider = "google"
+
+// LinkedIdentity ties a user to the subject of an external provider, kept under "identities"
+type LinkedIdentity struct {
+	Provider string `json:"provider" bson:"provider"`
+	Subject  string `json:"subject" bson:"subject"`
+}
+
+// ExternalIdentity is what an identity provider vouches for once it verified an ID token
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// FullName falls back to the start of the email for providers that don't send a name
+func (i *ExternalIdentity) FullName() string {
+	if i.Name != "" {
+		return i.Name
+	}
+	name, _, _ := strings.Cut(i.Email, "@")
+	return name
+}
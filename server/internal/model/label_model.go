@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Label is one entry of the label catalog of a workspace, todos carry the names of their labels
+// (Todo.Labels) so renaming / deleting a label also updates the todos using it
+type Label struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	UserId      primitive.ObjectID `bson:"userId,omitempty" json:"userId,omitempty"`
+	WorkspaceId primitive.ObjectID `bson:"workspaceId,omitempty" json:"workspaceId,omitempty"`
+	// Name is unique within the workspace
+	Name string `bson:"name" json:"name"`
+	// Color is a hex color, "#3b82f6"
+	Color string `bson:"color" json:"color"`
+	// Order is where the label sits in the catalog, lowest first
+	Order     int       `bson:"order" json:"order"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time `bson:"updatedAt" json:"updatedAt"`
+}
+
+// LabelUpdate is what an update may change, nil leaves the field alone
+type LabelUpdate struct {
+	Name  *string
+	Color *string
+	Order *int
+}
+
+// how TodoFilter.Labels are matched
+const (
+	LabelMatchAny = "any"
+	LabelMatchAll = "all"
+)
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LockoutEvent is written every time sign in gets locked for an email or an ip
+// it is the trail users are notified from and admins unlock from
+type LockoutEvent struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"_id"`
+	Email       string             `bson:"email,omitempty" json:"email,omitempty"`
+	IP          string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	Scope       string             `bson:"scope" json:"scope"` // "email" or "ip"
+	Failures    int64              `bson:"failures" json:"failures"`
+	LockedUntil time.Time          `bson:"lockedUntil" json:"lockedUntil"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UnlockedAt  *time.Time         `bson:"unlockedAt,omitempty" json:"unlockedAt,omitempty"`
+	UnlockedBy  string             `bson:"unlockedBy,omitempty" json:"unlockedBy,omitempty"`
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Session is one signed in device, it lives in redis as long as its refresh token family
+type Session struct {
+	ID         string    `json:"_id"`
+	UserId     string    `json:"userId"`
+	Device     string    `json:"device"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	Current    bool      `json:"current"`
+}
+
+// ClientInfo is what the handler knows about the caller when a session is started
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
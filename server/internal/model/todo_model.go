@@ -17,9 +17,223 @@ type Todo struct {
 
 	Priority string `bson:"priority" json:"priority"`
 
+	// Status is one of the TodoStatus constants, Done is kept in line with it
+	// (Status == TodoStatusDone) for clients and queries that only know done
+	Status string `bson:"status" json:"status"`
 	// why not omitempty
 	// because if false then it wont show in json / bson response
 	Done      bool      `bson:"done" json:"done"`
 	CreatedAt time.Time `bson:"createdAt,omitempty" json:"createdAt,omitempty"`
 	UpdatedAt time.Time `bson:"updatedAt,omitempty" json:"updatedAt,omitempty"`
+
+	// both optional, StartAt is when work can begin and can't be after DueAt
+	DueAt   *time.Time `bson:"dueAt,omitempty" json:"dueAt,omitempty"`
+	StartAt *time.Time `bson:"startAt,omitempty" json:"startAt,omitempty"`
+
+	// stamped by status changes: StartedAt when work first began, CompletedAt when it was done / cancelled
+	StartedAt   *time.Time `bson:"startedAt,omitempty" json:"startedAt,omitempty"`
+	CompletedAt *time.Time `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+
+	// Recurrence is set on every instance of a repeating todo
+	Recurrence *Recurrence `bson:"recurrence,omitempty" json:"recurrence,omitempty"`
+
+	// Checklist items are kept in the order the user arranged them
+	Checklist []ChecklistItem `bson:"checklist,omitempty" json:"checklist,omitempty"`
+	// ParentId makes this todo a subtask, subtasks live in the workspace of their parent
+	// and can't have subtasks of their own
+	ParentId *primitive.ObjectID `bson:"parentId,omitempty" json:"parentId,omitempty"`
+	// AutoComplete keeps Done in line with the checklist and subtasks: done once all of them are
+	AutoComplete bool `bson:"autoComplete,omitempty" json:"autoComplete,omitempty"`
+	// Labels are names from the label catalog of the workspace
+	Labels []string `bson:"labels,omitempty" json:"labels,omitempty"`
+	// Rank orders the todo within its status column of the workspace (manual / drag ordering),
+	// ranks compare as plain strings, todos from before ranks have none and come first
+	Rank string `bson:"rank,omitempty" json:"rank,omitempty"`
+
+	// Progress is worked out when the todo is read, never stored
+	Progress *TodoProgress `bson:"-" json:"progress,omitempty"`
+}
+
+// workflow states of a todo, service.todoTransitions says which moves are allowed
+const (
+	TodoStatusNotStarted = "not-started"
+	TodoStatusInProgress = "in-progress"
+	TodoStatusBlocked    = "blocked"
+	TodoStatusDone       = "done"
+	TodoStatusCancelled  = "cancelled"
+)
+
+// StatusChange moves a todo to Status, the dates work like TodoUpdate: nil is left alone, zero removes
+type StatusChange struct {
+	Status      string
+	StartedAt   *time.Time
+	CompletedAt *time.Time
+	// Rank places the todo in the column of Status, empty keeps the rank it has
+	Rank string
+}
+
+// TodoTransfer is what moving / copying todos to another workspace did
+type TodoTransfer struct {
+	// Todos are the moved todos or the copies, subtasks that came along included
+	Todos []Todo `json:"todos"`
+	// FromWorkspaceIds are the workspaces the todos came from
+	FromWorkspaceIds []string `json:"fromWorkspaceIds"`
+	// Transaction is false when mongo can't run transactions (standalone server), a move that
+	// fails partway then keeps the todos moved so far and Todos lists only those
+	Transaction bool `json:"transaction"`
+}
+
+// RankChange moves the todo ID from rank From to To, a todo whose rank isn't From anymore is left alone
+type RankChange struct {
+	ID   primitive.ObjectID
+	From string
+	To   string
+}
+
+// ChecklistItem is one step of a todo, lighter than a subtask (no dates / priority)
+type ChecklistItem struct {
+	ID        primitive.ObjectID `bson:"_id" json:"_id"`
+	Text      string             `bson:"text" json:"text"`
+	Done      bool               `bson:"done" json:"done"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// TodoProgress counts the checklist items and subtasks of a todo, "3/5 done"
+type TodoProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Recurrence ties a todo to its series, only one open instance exists at a time:
+// completing it creates the next one from the series fields (see service.nextInstance)
+// clients send rrule (RFC 5545, "FREQ=WEEKLY;BYDAY=MO") and timeZone, the rest is kept by the server
+type Recurrence struct {
+	RRule string `bson:"rrule" json:"rrule"`
+	// TimeZone (IANA) the rule is expanded in so "every monday" stays monday, UTC when empty
+	TimeZone string `bson:"timeZone,omitempty" json:"timeZone,omitempty"`
+
+	// SeriesId is the id of the first todo of the series
+	SeriesId primitive.ObjectID `bson:"seriesId,omitempty" json:"seriesId,omitempty"`
+	// Start is DTSTART of the rule, moved when the series is rescheduled from an instance on
+	Start time.Time `bson:"start" json:"start"`
+	// Occurrence is the scheduled date of this instance, rescheduling just this one keeps it
+	Occurrence time.Time `bson:"occurrence" json:"occurrence"`
+	// Exceptions are occurrences that are skipped (EXDATE)
+	Exceptions []time.Time `bson:"exceptions,omitempty" json:"exceptions,omitempty"`
+
+	// the next instance takes task / priority from here, so "this one" edits don't carry over
+	SeriesTask     string `bson:"seriesTask" json:"seriesTask"`
+	SeriesPriority string `bson:"seriesPriority" json:"seriesPriority"`
+	// StartOffset is how long before the due date the instances start, 0 without startAt
+	StartOffset time.Duration `bson:"startOffset,omitempty" json:"-"`
+
+	// NextCreated is set once completing this instance created the next one
+	NextCreated bool `bson:"nextCreated,omitempty" json:"nextCreated,omitempty"`
+}
+
+// scopes of an update to a repeating todo
+const (
+	SeriesScopeThis   = "this"
+	SeriesScopeFuture = "future"
+)
+
+// TodoUpdate is what an update may change, a nil field is left alone
+// and a zero date removes it
+type TodoUpdate struct {
+	Task     *string
+	Priority *string
+	DueAt    *time.Time
+	StartAt  *time.Time
+	// RRule nil leaves the recurrence alone, "" stops repeating, it always applies from this instance on
+	RRule *string
+	// TimeZone of a new or changed rule
+	TimeZone string
+	// AutoComplete nil leaves it alone
+	AutoComplete *bool
+	// Labels nil leaves them alone, an empty list removes every label
+	Labels *[]string
+	// Scope of task / priority / date changes to a repeating todo, SeriesScopeThis by default
+	Scope string
+}
+
+// views of the todos of a workspace by due date, see service.todoViewFilter
+const (
+	TodoViewOverdue = "overdue"
+	TodoViewToday   = "today"
+	TodoViewWeek    = "week"
+	TodoViewNoDate  = "no-date"
+)
+
+// TodoFilter narrows down the todos of a workspace, the zero value matches every todo
+type TodoFilter struct {
+	// DueFrom is inclusive, DueBefore exclusive
+	DueFrom   *time.Time
+	DueBefore *time.Time
+	NoDueDate bool
+	// OpenOnly leaves out done todos
+	OpenOnly bool
+	// Labels keeps todos with any (LabelMatchAny, the default) or all (LabelMatchAll) of them
+	Labels     []string
+	LabelMatch string
+	// Statuses / Priorities keep todos with one of them
+	Statuses   []string
+	Priorities []string
+	// the From dates are inclusive, the Before dates exclusive
+	CreatedFrom   *time.Time
+	CreatedBefore *time.Time
+	UpdatedFrom   *time.Time
+	UpdatedBefore *time.Time
+	// TaskPrefix keeps todos whose task starts with it, ignoring case
+	TaskPrefix string
+
+	// Sort is one of the TodoSort constants, ties are broken by _id in the same direction
+	Sort string
+	Desc bool
+	// After continues a listing after the todo it describes, Limit 0 lists every todo
+	After *TodoCursor
+	Limit int64
+}
+
+// sort keys of a todo listing, they are the bson field names
+const (
+	TodoSortCreated = "createdAt"
+	TodoSortUpdated = "updatedAt"
+	TodoSortDue     = "dueAt"
+	TodoSortTask    = "task"
+	TodoSortRank    = "rank"
+)
+
+// TodoCursor is the position of a todo in a sorted listing, Value is the sort field
+// of that todo (nil for a todo without due date / rank) and ID breaks ties
+type TodoCursor struct {
+	Value any
+	ID    primitive.ObjectID
+}
+
+// TodoQuery is what a client asks the todo list for, the service turns it into a TodoFilter
+type TodoQuery struct {
+	// View is one of the TodoView constants, empty for every todo
+	View string
+	// Location "today" / "this week" are worked out in, UTC when nil
+	Location *time.Location
+	// the filters below work as in TodoFilter
+	Labels        []string
+	LabelMatch    string
+	Statuses      []string
+	Priorities    []string
+	CreatedFrom   *time.Time
+	CreatedBefore *time.Time
+	UpdatedFrom   *time.Time
+	UpdatedBefore *time.Time
+	TaskPrefix    string
+
+	// Sort is a TodoSort constant, "-" in front sorts descending, by default due date
+	// views are sorted by due date and everything else by creation
+	Sort string
+	// Cursor is the nextCursor of the previous page, Limit the page size
+	// (the default page size when 0, never more than the max page size)
+	Cursor string
+	Limit  int64
+	// All lists every todo in one go instead of a page, for clients from before paging
+	All bool
 }
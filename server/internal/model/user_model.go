@@ -13,3 +13,24 @@ type User struct {
 	UpdatedAt time.Time          `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
 	ImageLink string             `json:"imageLink,omitempty" bson:"imageLink,omitempty"`
 }
+
+// roles kept on the user document, users without one are RoleUser
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Roles is every role an admin can assign
+var Roles = []string{RoleUser, RoleAdmin}
+
+// TwoFactor is the TOTP state kept on the user document under "twoFactor"
+// pending fields hold an enrollment until the first code is confirmed
+// recovery codes are stored as sha256 hashes and removed once used
+type TwoFactor struct {
+	Enabled              bool      `json:"enabled" bson:"enabled"`
+	Secret               string    `json:"-" bson:"secret,omitempty"`
+	RecoveryCodes        []string  `json:"-" bson:"recoveryCodes,omitempty"`
+	PendingSecret        string    `json:"-" bson:"pendingSecret,omitempty"`
+	PendingRecoveryCodes []string  `json:"-" bson:"pendingRecoveryCodes,omitempty"`
+	EnabledAt            time.Time `json:"enabledAt,omitempty" bson:"enabledAt,omitempty"`
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AccessTokenRepository stores personal access tokens in mongo, they outlive any session
+// expired tokens are removed by the TTL index on expiresAt (see app.Run)
+type AccessTokenRepository interface {
+	CreateToken(ctx context.Context, token model.AccessToken) (*model.AccessToken, error)
+	ListTokens(ctx context.Context, userId string) ([]model.AccessToken, error)
+	RevokeToken(ctx context.Context, userId string, tokenId string) error
+	GetTokenByHash(ctx context.Context, tokenHash string) (*model.AccessToken, error)
+	TouchToken(ctx context.Context, tokenId primitive.ObjectID, minInterval time.Duration) error
+	DeleteUserTokens(ctx context.Context, userId string) error
+}
+
+type accessTokenRepository struct {
+	accessTokenCollection *mongo.Collection
+}
+
+func (r *accessTokenRepository) CreateToken(ctx context.Context, token model.AccessToken) (*model.AccessToken, error) {
+	if token.UserId.IsZero() || token.TokenHash == "" {
+		return nil, errors.New("UserId / TokenHash is Empty in Repo")
+	}
+
+	token.CreatedAt = time.Now()
+	inserted, err := r.accessTokenCollection.InsertOne(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	token.ID = inserted.InsertedID.(primitive.ObjectID)
+	return &token, nil
+}
+
+// ListTokens returns the tokens of a user, newest first
+func (r *accessTokenRepository) ListTokens(ctx context.Context, userId string) ([]model.AccessToken, error) {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := r.accessTokenCollection.Find(ctx, bson.M{"userId": userIdOid, "expiresAt": bson.M{"$gt": time.Now()}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tokens := []model.AccessToken{}
+	if err := cursor.All(ctx, &tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (r *accessTokenRepository) RevokeToken(ctx context.Context, userId string, tokenId string) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+	tokenIdOid, err := primitive.ObjectIDFromHex(tokenId)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := r.accessTokenCollection.DeleteOne(ctx, bson.M{"_id": tokenIdOid, "userId": userIdOid})
+	if err != nil {
+		return err
+	}
+	if deleted.DeletedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetTokenByHash returns ErrNotFound for unknown and expired tokens
+// the TTL monitor only runs once a minute so expiry is checked here as well
+func (r *accessTokenRepository) GetTokenByHash(ctx context.Context, tokenHash string) (*model.AccessToken, error) {
+	var token model.AccessToken
+	filter := bson.M{"tokenHash": tokenHash, "expiresAt": bson.M{"$gt": time.Now()}}
+	err := r.accessTokenCollection.FindOne(ctx, filter).Decode(&token)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// TouchToken sets lastUsedAt, at most once per minInterval so a busy script doesn't write on every call
+func (r *accessTokenRepository) TouchToken(ctx context.Context, tokenId primitive.ObjectID, minInterval time.Duration) error {
+	now := time.Now()
+	filter := bson.M{
+		"_id": tokenId,
+		"$or": bson.A{
+			bson.M{"lastUsedAt": bson.M{"$exists": false}},
+			bson.M{"lastUsedAt": bson.M{"$lt": now.Add(-minInterval)}},
+		},
+	}
+
+	_, err := r.accessTokenCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"lastUsedAt": now}})
+	return err
+}
+
+// DeleteUserTokens revokes every token of the user at once
+func (r *accessTokenRepository) DeleteUserTokens(ctx context.Context, userId string) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.accessTokenCollection.DeleteMany(ctx, bson.M{"userId": userIdOid})
+	return err
+}
+
+func NewAccessTokenRepository(accessTokenCollection *mongo.Collection) AccessTokenRepository {
+	return &accessTokenRepository{
+		accessTokenCollection: accessTokenCollection,
+	}
+}
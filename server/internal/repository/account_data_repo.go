@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AccountDataRepository covers everything a user owns outside the users collection
+// used by the account purge (every delete is by userId so running it twice is harmless)
+// and by the admin API for usage counts
+type AccountDataRepository interface {
+	DeleteUserData(ctx context.Context, userId string) error
+	CountUserData(ctx context.Context, userId string) (map[string]int64, error)
+}
+
+type accountDataRepository struct {
+	// every collection holding documents with a userId field
+	collections []*mongo.Collection
+}
+
+func (r *accountDataRepository) DeleteUserData(ctx context.Context, userId string) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	for _, collection := range r.collections {
+		if _, err := collection.DeleteMany(ctx, bson.M{"userId": userIdOid}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CountUserData returns the number of documents the user owns, keyed by collection name
+func (r *accountDataRepository) CountUserData(ctx context.Context, userId string) (map[string]int64, error) {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int64{}
+	for _, collection := range r.collections {
+		count, err := collection.CountDocuments(ctx, bson.M{"userId": userIdOid})
+		if err != nil {
+			return nil, err
+		}
+		counts[collection.Name()] = count
+	}
+
+	return counts, nil
+}
+
+// NewAccountDataRepository takes the todos, goals, workspaces (layouts live on them), access
+// tokens and labels collections, add new per user collections here so the purge keeps up
+func NewAccountDataRepository(collections ...*mongo.Collection) AccountDataRepository {
+	return &accountDataRepository{
+		collections: collections,
+	}
+}
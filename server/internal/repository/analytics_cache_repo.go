@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// AnalyticsCacheRepository manages the "analytics:<userId>:<workspaceId>:<year>" keys cached by the todo handler
+type AnalyticsCacheRepository interface {
+	DeleteUserAnalytics(ctx context.Context, userId string) error
+}
+
+type analyticsCacheRepository struct {
+	rdb *redis.Client
+}
+
+// DeleteUserAnalytics drops the cached analytics of every year of the user
+func (r *analyticsCacheRepository) DeleteUserAnalytics(ctx context.Context, userId string) error {
+	if userId == "" {
+		return errors.New("UserId is Empty in Repo")
+	}
+
+	// SCAN instead of KEYS so a big keyspace doesn't block redis
+	iter := r.rdb.Scan(ctx, 0, fmt.Sprintf("analytics:%s:*", userId), 100).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.rdb.Del(ctx, keys...).Err()
+}
+
+func NewAnalyticsCacheRepository(rdb *redis.Client) AnalyticsCacheRepository {
+	return &analyticsCacheRepository{
+		rdb: rdb,
+	}
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditRepository is append-only on purpose: events are kept for good and never deleted, the one
+// change after the fact is PseudonymiseUser when an account is purged
+type AuditRepository interface {
+	RecordEvent(ctx context.Context, event model.AuditEvent) error
+	ListEvents(ctx context.Context, filter AuditFilter, skip int64, limit int64) ([]model.AuditEvent, int64, error)
+	PseudonymiseUser(ctx context.Context, userId string, email string) error
+}
+
+// AuditFilter narrows ListEvents, zero fields don't filter
+type AuditFilter struct {
+	UserId   string
+	ActorId  string
+	Action   string
+	TargetId string
+	From     time.Time
+	To       time.Time
+}
+
+type auditRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *auditRepository) RecordEvent(ctx context.Context, event model.AuditEvent) error {
+	event.ID = primitive.NilObjectID
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	_, err := r.collection.InsertOne(ctx, event)
+	return err
+}
+
+// ListEvents returns the matching events newest first and how many match in total
+func (r *auditRepository) ListEvents(ctx context.Context, filter AuditFilter, skip int64, limit int64) ([]model.AuditEvent, int64, error) {
+	query := bson.M{}
+	for field, id := range map[string]string{"userId": filter.UserId, "actorId": filter.ActorId} {
+		if id == "" {
+			continue
+		}
+		oid, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, 0, err
+		}
+		query[field] = oid
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.TargetId != "" {
+		query["targetId"] = filter.TargetId
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		createdAt := bson.M{}
+		if !filter.From.IsZero() {
+			createdAt["$gte"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			createdAt["$lt"] = filter.To
+		}
+		query["createdAt"] = createdAt
+	}
+
+	total, err := r.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []model.AuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+// PseudonymiseUser strips what identifies a purged user from their events (email, ip, user agent and
+// the document summaries), the action, target and time stay so the trail still adds up
+// failed sign ins of the email are stripped too, they may have no userId
+func (r *auditRepository) PseudonymiseUser(ctx context.Context, userId string, email string) error {
+	oid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"$or": bson.A{bson.M{"userId": oid}, bson.M{"actorId": oid}, bson.M{"email": email}}}
+	unset := bson.M{"email": "", "ip": "", "userAgent": "", "before": "", "after": ""}
+	_, err = r.collection.UpdateMany(ctx, filter, bson.M{"$unset": unset})
+	return err
+}
+
+func NewAuditRepository(collection *mongo.Collection) AuditRepository {
+	return &auditRepository{
+		collection: collection,
+	}
+}
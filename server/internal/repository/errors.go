@@ -0,0 +1,13 @@
+package repository
+
+import "errors"
+
+// ErrNotFound is returned when a document doesn't exist or isn't owned by the caller
+// both cases look the same so one user can't probe for another user's ids
+var ErrNotFound = errors.New("document not found")
+
+// ErrInvalidCredentials is returned by sign in for an unknown email or a wrong password alike
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// ErrAccountDisabled is returned by sign in once an admin disabled the account
+var ErrAccountDisabled = errors.New("account disabled")
@@ -14,10 +14,11 @@ import (
 type GoalRepository interface {
 	GetUserGoals(ctx context.Context, userId string, workspaceId string) ([]model.Goals, error)
 	CreateUserGoal(ctx context.Context, userId string, workspaceId string, goalName string, targetDays int64, category string) (model.Goals, error)
-	UpdateUserGoal(ctx context.Context, goalId string, updatedGoalName string, updatedTargetDays int64, updatedCategory string) (bool, error)
-	DeleteUserGoal(ctx context.Context, goalId string) (bool, error)
-	IncreamentGoalProgress(ctx context.Context, goalId string, count int64) (bool, error)
-	DecreamentGoalProgress(ctx context.Context, goalId string, count int64) (bool, error)
+	UpdateUserGoal(ctx context.Context, userId string, goalId string, updatedGoalName string, updatedTargetDays int64, updatedCategory string) (bool, error)
+	DeleteUserGoal(ctx context.Context, userId string, goalId string) (bool, error)
+	IncreamentGoalProgress(ctx context.Context, userId string, goalId string, count int64) (bool, error)
+	DecreamentGoalProgress(ctx context.Context, userId string, goalId string, count int64) (bool, error)
+	GetUserGoal(ctx context.Context, userId string, goalId string) (model.Goals, error)
 }
 
 type goalRepository struct {
@@ -99,18 +100,49 @@ func (r *goalRepository) CreateUserGoal(ctx context.Context, userId string, work
 	return insert, nil
 }
 
-func (r *goalRepository) UpdateUserGoal(ctx context.Context, goalId string, updatedGoalName string, updatedTargetDays int64, updatedCategory string) (bool, error) {
-	if goalId == "" {
-		return false, errors.New("Goal ID is Empty in Repo")
+// ownedGoalFilter matches a goal by id only when it belongs to userId
+func ownedGoalFilter(userId string, goalId string) (bson.M, error) {
+	if userId == "" || goalId == "" {
+		return nil, errors.New("UserId / Goal Id is Empty in Repository")
 	}
 
-	// convert goalId string -> objectId
+	// convert string -> ObjectId
 	oid, err := primitive.ObjectIDFromHex(goalId)
+	if err != nil {
+		return nil, err
+	}
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return bson.M{"_id": oid, "userId": userOid}, nil
+}
+
+// GetUserGoal returns one goal of userId, ErrNotFound when it doesn't exist or isn't theirs
+func (r *goalRepository) GetUserGoal(ctx context.Context, userId string, goalId string) (model.Goals, error) {
+	filter, err := ownedGoalFilter(userId, goalId)
+	if err != nil {
+		return model.Goals{}, err
+	}
+
+	var goal model.Goals
+	if err := r.goalCollection.FindOne(ctx, filter).Decode(&goal); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Goals{}, ErrNotFound
+		}
+		return model.Goals{}, err
+	}
+
+	return goal, nil
+}
+
+func (r *goalRepository) UpdateUserGoal(ctx context.Context, userId string, goalId string, updatedGoalName string, updatedTargetDays int64, updatedCategory string) (bool, error) {
+	filter, err := ownedGoalFilter(userId, goalId)
 	if err != nil {
 		return false, err
 	}
 
-	filter := bson.M{"_id": oid}
 	update := bson.M{"$set": bson.M{"title": updatedGoalName, "targetDays": updatedTargetDays, "category": updatedCategory}}
 
 	updated, err := r.goalCollection.UpdateOne(ctx, filter, update)
@@ -119,48 +151,36 @@ func (r *goalRepository) UpdateUserGoal(ctx context.Context, goalId string, upda
 	}
 
 	if updated.MatchedCount == 0 {
-		return false, errors.New("GoalId Document Not Found")
+		return false, ErrNotFound
 	}
 
 	return true, nil
 }
 
-func (r *goalRepository) DeleteUserGoal(ctx context.Context, goalId string) (bool, error) {
-	if goalId == "" {
-		return false, errors.New("Goal Id is Empty in Repository")
-	}
-
-	// convert string -> ObjectId
-	oid, err := primitive.ObjectIDFromHex(goalId)
+func (r *goalRepository) DeleteUserGoal(ctx context.Context, userId string, goalId string) (bool, error) {
+	filter, err := ownedGoalFilter(userId, goalId)
 	if err != nil {
 		return false, err
 	}
 
-	filter := bson.M{"_id": oid}
 	deletedRes, err := r.goalCollection.DeleteOne(ctx, filter)
 	if err != nil {
 		return false, err
 	}
 
 	if deletedRes.DeletedCount == 0 {
-		return false, errors.New("Documents Not Found")
+		return false, ErrNotFound
 	}
 
 	return true, nil
 }
 
-func (r *goalRepository) IncreamentGoalProgress(ctx context.Context, goalId string, count int64) (bool, error) {
-	if goalId == "" {
-		return false, errors.New("Goal Id is Empty in Repository")
-	}
-
-	// convert string -> ObjectId
-	oid, err := primitive.ObjectIDFromHex(goalId)
+func (r *goalRepository) IncreamentGoalProgress(ctx context.Context, userId string, goalId string, count int64) (bool, error) {
+	filter, err := ownedGoalFilter(userId, goalId)
 	if err != nil {
 		return false, err
 	}
 
-	filter := bson.M{"_id": oid}
 	// Use atomic increment operation instead of find + update
 	update := bson.M{"$inc": bson.M{"currentTarget": count}}
 
@@ -172,24 +192,18 @@ func (r *goalRepository) IncreamentGoalProgress(ctx context.Context, goalId stri
 	}
 
 	if updatedRes.MatchedCount == 0 {
-		return false, errors.New("GoalId Document Not Found")
+		return false, ErrNotFound
 	}
 
 	return true, nil
 }
 
-func (r *goalRepository) DecreamentGoalProgress(ctx context.Context, goalId string, count int64) (bool, error) {
-	if goalId == "" {
-		return false, errors.New("Goal Id is Empty in Repository")
-	}
-
-	// convert string -> ObjectId
-	oid, err := primitive.ObjectIDFromHex(goalId)
+func (r *goalRepository) DecreamentGoalProgress(ctx context.Context, userId string, goalId string, count int64) (bool, error) {
+	filter, err := ownedGoalFilter(userId, goalId)
 	if err != nil {
 		return false, err
 	}
 
-	filter := bson.M{"_id": oid}
 	update := bson.M{"$inc": bson.M{"currentTarget": -count}}
 
 	updatedRes, err := r.goalCollection.UpdateOne(ctx, filter, update)
@@ -198,7 +212,7 @@ func (r *goalRepository) DecreamentGoalProgress(ctx context.Context, goalId stri
 	}
 
 	if updatedRes.MatchedCount == 0 {
-		return false, errors.New("GoalId Document Not Found")
+		return false, ErrNotFound
 	}
 
 	return true, nil
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrLabelExists is returned when a workspace already has a label with that name
+var ErrLabelExists = errors.New("A label with this name already exists in the workspace")
+
+// LabelRepository stores the label catalog of every workspace ("labels")
+type LabelRepository interface {
+	ListLabels(ctx context.Context, userId string, workspaceId string) ([]model.Label, error)
+	GetLabel(ctx context.Context, userId string, labelId string) (model.Label, error)
+	CreateLabel(ctx context.Context, label model.Label) (model.Label, error)
+	UpdateLabel(ctx context.Context, userId string, labelId string, update model.LabelUpdate) (model.Label, error)
+	DeleteLabel(ctx context.Context, userId string, labelId string) error
+	ReorderLabels(ctx context.Context, userId string, workspaceId string, labelIds []primitive.ObjectID) error
+}
+
+type labelRepository struct {
+	labelCollection *mongo.Collection
+}
+
+// ListLabels returns the catalog of a workspace in its order
+func (r *labelRepository) ListLabels(ctx context.Context, userId string, workspaceId string) ([]model.Label, error) {
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+	workspaceOid, err := primitive.ObjectIDFromHex(workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "order", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.labelCollection.Find(ctx, bson.M{"userId": userOid, "workspaceId": workspaceOid}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	labels := []model.Label{}
+	if err := cursor.All(ctx, &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// labelOwnerFilter matches labelId only when userId owns it
+func labelOwnerFilter(userId string, labelId string) (bson.M, error) {
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+	labelOid, err := primitive.ObjectIDFromHex(labelId)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"_id": labelOid, "userId": userOid}, nil
+}
+
+func (r *labelRepository) GetLabel(ctx context.Context, userId string, labelId string) (model.Label, error) {
+	filter, err := labelOwnerFilter(userId, labelId)
+	if err != nil {
+		return model.Label{}, err
+	}
+
+	var label model.Label
+	err = r.labelCollection.FindOne(ctx, filter).Decode(&label)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return model.Label{}, ErrNotFound
+	}
+	return label, err
+}
+
+// CreateLabel inserts label, UserId / WorkspaceId have to be set by the caller
+func (r *labelRepository) CreateLabel(ctx context.Context, label model.Label) (model.Label, error) {
+	label.CreatedAt = time.Now()
+	label.UpdatedAt = label.CreatedAt
+
+	inserted, err := r.labelCollection.InsertOne(ctx, label)
+	if err != nil {
+		// the unique { workspaceId, name } index
+		if mongo.IsDuplicateKeyError(err) {
+			return model.Label{}, ErrLabelExists
+		}
+		return model.Label{}, err
+	}
+
+	label.ID = inserted.InsertedID.(primitive.ObjectID)
+	return label, nil
+}
+
+// UpdateLabel changes the fields set in update and returns the label as it is now
+func (r *labelRepository) UpdateLabel(ctx context.Context, userId string, labelId string, update model.LabelUpdate) (model.Label, error) {
+	filter, err := labelOwnerFilter(userId, labelId)
+	if err != nil {
+		return model.Label{}, err
+	}
+
+	set := bson.M{"updatedAt": time.Now()}
+	if update.Name != nil {
+		set["name"] = *update.Name
+	}
+	if update.Color != nil {
+		set["color"] = *update.Color
+	}
+	if update.Order != nil {
+		set["order"] = *update.Order
+	}
+
+	var label model.Label
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.labelCollection.FindOneAndUpdate(ctx, filter, bson.M{"$set": set}, opts).Decode(&label)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return model.Label{}, ErrNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return model.Label{}, ErrLabelExists
+	}
+	return label, err
+}
+
+func (r *labelRepository) DeleteLabel(ctx context.Context, userId string, labelId string) error {
+	filter, err := labelOwnerFilter(userId, labelId)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := r.labelCollection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if deleted.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ReorderLabels gives every label of labelIds its index as order, in one bulk write
+func (r *labelRepository) ReorderLabels(ctx context.Context, userId string, workspaceId string, labelIds []primitive.ObjectID) error {
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+	workspaceOid, err := primitive.ObjectIDFromHex(workspaceId)
+	if err != nil {
+		return err
+	}
+	if len(labelIds) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	writes := make([]mongo.WriteModel, 0, len(labelIds))
+	for order, labelId := range labelIds {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": labelId, "userId": userOid, "workspaceId": workspaceOid}).
+			SetUpdate(bson.M{"$set": bson.M{"order": order, "updatedAt": now}}))
+	}
+
+	_, err = r.labelCollection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func NewLabelRepository(labelCollection *mongo.Collection) LabelRepository {
+	return &labelRepository{
+		labelCollection: labelCollection,
+	}
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// LockoutRepository records sign in lockouts, the locks themselves live in LoginAttemptRepository
+type LockoutRepository interface {
+	RecordLockout(ctx context.Context, event model.LockoutEvent) error
+	MarkUnlocked(ctx context.Context, email string, unlockedBy string) error
+	DeleteLockouts(ctx context.Context, email string) error
+	LockoutIPs(ctx context.Context, email string) ([]string, error)
+}
+
+type lockoutRepository struct {
+	lockoutCollection *mongo.Collection
+}
+
+func (r *lockoutRepository) RecordLockout(ctx context.Context, event model.LockoutEvent) error {
+	if event.Scope == "" {
+		return errors.New("Scope is Empty in Repo")
+	}
+
+	event.CreatedAt = time.Now()
+	_, err := r.lockoutCollection.InsertOne(ctx, event)
+	return err
+}
+
+// MarkUnlocked closes every still running lockout of email
+func (r *lockoutRepository) MarkUnlocked(ctx context.Context, email string, unlockedBy string) error {
+	if email == "" {
+		return errors.New("Email is Empty in Repo")
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"email":       email,
+		"unlockedAt":  bson.M{"$exists": false},
+		"lockedUntil": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"unlockedAt": now, "unlockedBy": unlockedBy}}
+
+	_, err := r.lockoutCollection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// DeleteLockouts removes the history of an email, used when its account is purged
+func (r *lockoutRepository) DeleteLockouts(ctx context.Context, email string) error {
+	if email == "" {
+		return errors.New("Email is Empty in Repo")
+	}
+
+	_, err := r.lockoutCollection.DeleteMany(ctx, bson.M{"email": email})
+	return err
+}
+
+// LockoutIPs are the ips the lockouts of an email came from
+func (r *lockoutRepository) LockoutIPs(ctx context.Context, email string) ([]string, error) {
+	if email == "" {
+		return nil, errors.New("Email is Empty in Repo")
+	}
+
+	values, err := r.lockoutCollection.Distinct(ctx, "ip", bson.M{"email": email})
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]string, 0, len(values))
+	for _, value := range values {
+		if ip, ok := value.(string); ok && ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+func NewLockoutRepository(lockoutCollection *mongo.Collection) LockoutRepository {
+	return &lockoutRepository{
+		lockoutCollection: lockoutCollection,
+	}
+}
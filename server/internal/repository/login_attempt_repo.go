@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LoginAttemptRepository keeps failed sign in counters and temporary locks in redis
+// keys are free form ("email:<email>", "ip:<ip>") so one store covers every dimension
+type LoginAttemptRepository interface {
+	RegisterFailure(ctx context.Context, key string, window time.Duration) (int64, error)
+	Lock(ctx context.Context, key string, duration time.Duration) error
+	LockRemaining(ctx context.Context, key string) (time.Duration, error)
+	Clear(ctx context.Context, key string) error
+}
+
+type loginAttemptRepository struct {
+	rdb *redis.Client
+}
+
+func loginFailuresKey(key string) string {
+	return "login:fail:" + key
+}
+
+func loginLockKey(key string) string {
+	return "login:lock:" + key
+}
+
+// failureScript increments the counter and pushes its expiry back on every failure
+// so someone who keeps guessing never gets a fresh start
+var failureScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return count
+`)
+
+// RegisterFailure returns the number of failures for key within the sliding window
+func (r *loginAttemptRepository) RegisterFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	if key == "" {
+		return 0, errors.New("Key is Empty in Repo")
+	}
+
+	return failureScript.Run(ctx, r.rdb, []string{loginFailuresKey(key)}, window.Milliseconds()).Int64()
+}
+
+func (r *loginAttemptRepository) Lock(ctx context.Context, key string, duration time.Duration) error {
+	if key == "" {
+		return errors.New("Key is Empty in Repo")
+	}
+
+	return r.rdb.Set(ctx, loginLockKey(key), time.Now().Add(duration).Unix(), duration).Err()
+}
+
+// LockRemaining is 0 when key isn't locked
+func (r *loginAttemptRepository) LockRemaining(ctx context.Context, key string) (time.Duration, error) {
+	if key == "" {
+		return 0, nil
+	}
+
+	ttl, err := r.rdb.PTTL(ctx, loginLockKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// -2 (no key) and -1 (no expiry, never set by Lock) both mean not locked
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Clear drops the failure counter and the lock of key
+func (r *loginAttemptRepository) Clear(ctx context.Context, key string) error {
+	if key == "" {
+		return errors.New("Key is Empty in Repo")
+	}
+
+	return r.rdb.Del(ctx, loginFailuresKey(key), loginLockKey(key)).Err()
+}
+
+func NewLoginAttemptRepository(rdb *redis.Client) LoginAttemptRepository {
+	return &loginAttemptRepository{
+		rdb: rdb,
+	}
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitRepository counts hits per key in a fixed window
+type RateLimitRepository interface {
+	Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, time.Duration, error)
+}
+
+// RateLimitError tells the handler how long the caller has to wait (Retry-After)
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("too many requests, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+type rateLimitRepository struct {
+	rdb *redis.Client
+}
+
+// hitScript increments the counter and starts the window on the first hit
+// returns the count and the remaining window in milliseconds
+var hitScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {count, redis.call('PTTL', KEYS[1])}
+`)
+
+// Allow records a hit and reports whether it is within limit
+// when it isn't, the returned duration is how long until the window resets
+func (r *rateLimitRepository) Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, time.Duration, error) {
+	res, err := hitScript.Run(ctx, r.rdb, []string{"ratelimit:" + key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	count, ttl := res[0], time.Duration(res[1])*time.Millisecond
+	if count > limit {
+		return false, ttl, nil
+	}
+
+	return true, 0, nil
+}
+
+func NewRateLimitRepository(rdb *redis.Client) RateLimitRepository {
+	return &rateLimitRepository{
+		rdb: rdb,
+	}
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// A session is one refresh token family: it starts at sign in and every refresh rotates
+// the current refresh token id (jti) inside it. Access tokens carry the session id so
+// revoking the session cuts them off immediately.
+// Each session hash also keeps the device, ip, created and last seen time for the sessions API.
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session model.Session, tokenId string, ttl time.Duration) error
+	RotateRefreshToken(ctx context.Context, userId string, sessionId string, oldTokenId string, newTokenId string, ttl time.Duration) error
+	RevokeSession(ctx context.Context, userId string, sessionId string) error
+	RevokeAllSessions(ctx context.Context, userId string) error
+	TouchSession(ctx context.Context, sessionId string) (bool, error)
+	ListSessions(ctx context.Context, userId string) ([]model.Session, error)
+}
+
+var (
+	// ErrSessionRevoked is returned when the session was logged out or has expired
+	ErrSessionRevoked = errors.New("session revoked or expired")
+	// ErrRefreshTokenReused is returned when an already rotated refresh token is presented again
+	// the whole session is killed because either the client or an attacker holds a stolen token
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+type sessionRepository struct {
+	rdb *redis.Client
+}
+
+func sessionKey(sessionId string) string {
+	return fmt.Sprintf("session:%s", sessionId)
+}
+
+func userSessionsKey(userId string) string {
+	return fmt.Sprintf("user:sessions:%s", userId)
+}
+
+// rotateScript swaps the current jti of a session in one round trip
+// returns 1 on success, 0 when the session is gone and -1 when the old jti isn't the current one
+var rotateScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'jti')
+if not current then
+	return 0
+end
+if current ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	redis.call('SREM', KEYS[2], ARGV[3])
+	return -1
+end
+redis.call('HSET', KEYS[1], 'jti', ARGV[2], 'lastSeenAt', ARGV[5])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return 1
+`)
+
+// touchScript bumps lastSeenAt only if the session still exists
+// a plain HSET would bring a revoked session back to life
+var touchScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'lastSeenAt', ARGV[1])
+return 1
+`)
+
+func (r *sessionRepository) CreateSession(ctx context.Context, session model.Session, tokenId string, ttl time.Duration) error {
+	if session.UserId == "" || session.ID == "" || tokenId == "" {
+		return errors.New("UserId / SessionId / TokenId is Empty in Repo")
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	pipe := r.rdb.TxPipeline()
+	pipe.HSet(ctx, sessionKey(session.ID),
+		"userId", session.UserId,
+		"jti", tokenId,
+		"device", session.Device,
+		"userAgent", session.UserAgent,
+		"ip", session.IP,
+		"createdAt", now,
+		"lastSeenAt", now,
+	)
+	pipe.Expire(ctx, sessionKey(session.ID), ttl)
+	pipe.SAdd(ctx, userSessionsKey(session.UserId), session.ID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *sessionRepository) RotateRefreshToken(ctx context.Context, userId string, sessionId string, oldTokenId string, newTokenId string, ttl time.Duration) error {
+	if userId == "" || sessionId == "" || oldTokenId == "" || newTokenId == "" {
+		return errors.New("UserId / SessionId / TokenId is Empty in Repo")
+	}
+
+	keys := []string{sessionKey(sessionId), userSessionsKey(userId)}
+	now := time.Now().Unix()
+	res, err := rotateScript.Run(ctx, r.rdb, keys, oldTokenId, newTokenId, sessionId, int64(ttl.Seconds()), now).Int()
+	if err != nil {
+		return err
+	}
+
+	switch res {
+	case 1:
+		return nil
+	case -1:
+		return ErrRefreshTokenReused
+	default:
+		return ErrSessionRevoked
+	}
+}
+
+func (r *sessionRepository) RevokeSession(ctx context.Context, userId string, sessionId string) error {
+	if userId == "" || sessionId == "" {
+		return errors.New("UserId / SessionId is Empty in Repo")
+	}
+
+	// only the owner can revoke, anything else looks like a missing session
+	owner, err := r.rdb.HGet(ctx, sessionKey(sessionId), "userId").Result()
+	if errors.Is(err, redis.Nil) || (err == nil && owner != userId) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	pipe := r.rdb.TxPipeline()
+	pipe.Del(ctx, sessionKey(sessionId))
+	pipe.SRem(ctx, userSessionsKey(userId), sessionId)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *sessionRepository) RevokeAllSessions(ctx context.Context, userId string) error {
+	if userId == "" {
+		return errors.New("UserId is Empty in Repo")
+	}
+
+	sessionIds, err := r.rdb.SMembers(ctx, userSessionsKey(userId)).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := r.rdb.TxPipeline()
+	for _, sessionId := range sessionIds {
+		pipe.Del(ctx, sessionKey(sessionId))
+	}
+	pipe.Del(ctx, userSessionsKey(userId))
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// TouchSession reports whether the session is still alive and records it as seen now
+func (r *sessionRepository) TouchSession(ctx context.Context, sessionId string) (bool, error) {
+	if sessionId == "" {
+		return false, nil
+	}
+
+	res, err := touchScript.Run(ctx, r.rdb, []string{sessionKey(sessionId)}, time.Now().Unix()).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return res == 1, nil
+}
+
+// ListSessions returns the live sessions of a user, most recently seen first
+// ids of sessions that expired on their own are dropped from the user's set on the way
+func (r *sessionRepository) ListSessions(ctx context.Context, userId string) ([]model.Session, error) {
+	if userId == "" {
+		return nil, errors.New("UserId is Empty in Repo")
+	}
+
+	sessionIds, err := r.rdb.SMembers(ctx, userSessionsKey(userId)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := r.rdb.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(sessionIds))
+	for i, sessionId := range sessionIds {
+		cmds[i] = pipe.HGetAll(ctx, sessionKey(sessionId))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	sessions := []model.Session{}
+	var expired []interface{}
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			expired = append(expired, sessionIds[i])
+			continue
+		}
+
+		sessions = append(sessions, model.Session{
+			ID:         sessionIds[i],
+			UserId:     fields["userId"],
+			Device:     fields["device"],
+			UserAgent:  fields["userAgent"],
+			IP:         fields["ip"],
+			CreatedAt:  unixField(fields["createdAt"]),
+			LastSeenAt: unixField(fields["lastSeenAt"]),
+		})
+	}
+
+	if len(expired) > 0 {
+		r.rdb.SRem(ctx, userSessionsKey(userId), expired...)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+
+	return sessions, nil
+}
+
+// unixField parses a unix seconds hash field, missing / broken values become the zero time
+func unixField(value string) time.Time {
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+func NewSessionRepository(rdb *redis.Client) SessionRepository {
+	return &sessionRepository{
+		rdb: rdb,
+	}
+}
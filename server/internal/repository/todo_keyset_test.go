@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// keysetDoc is a todo as mongo sees it, a field that is missing is nil
+type keysetDoc map[string]any
+
+// compareValues orders two values of a field the way mongo does, null (missing) first
+func compareValues(a any, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		switch {
+		case a < b.(string):
+			return -1
+		case a > b.(string):
+			return 1
+		}
+		return 0
+	case primitive.ObjectID:
+		return compareValues(a.Hex(), b.(primitive.ObjectID).Hex())
+	}
+	panic(fmt.Sprintf("can't compare %T", a))
+}
+
+// matchesFilter evaluates the subset of the query language todoKeyset builds
+func matchesFilter(doc keysetDoc, filter bson.M) bool {
+	for key, cond := range filter {
+		if key == "$or" {
+			any := false
+			for _, branch := range cond.(bson.A) {
+				if matchesFilter(doc, branch.(bson.M)) {
+					any = true
+				}
+			}
+			if !any {
+				return false
+			}
+			continue
+		}
+
+		ops, ok := cond.(bson.M)
+		if !ok {
+			// equality, nil matches a missing field
+			if compareValues(doc[key], cond) != 0 || (cond == nil) != (doc[key] == nil) {
+				return false
+			}
+			continue
+		}
+		for op, value := range ops {
+			// like mongo, $gt / $lt never match across null and a value
+			comparable := (doc[key] == nil) == (value == nil)
+			switch op {
+			case "$gt":
+				if !comparable || compareValues(doc[key], value) <= 0 {
+					return false
+				}
+			case "$lt":
+				if !comparable || compareValues(doc[key], value) >= 0 {
+					return false
+				}
+			case "$ne":
+				if (value == nil) == (doc[key] == nil) && compareValues(doc[key], value) == 0 {
+					return false
+				}
+			default:
+				panic("unsupported operator " + op)
+			}
+		}
+	}
+	return true
+}
+
+// keysetDocs has ties on every sort key and todos without due date / rank
+func keysetDocs() []keysetDoc {
+	day := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	values := []struct {
+		created, updated int
+		due              int // -1 for none
+		task, rank       string
+	}{
+		{0, 5, 2, "Buy milk", "i"},
+		{0, 5, -1, "Buy milk", ""},
+		{1, 3, 2, "Call mom", "r"},
+		{1, 3, -1, "Buy milk", "i"},
+		{2, 5, 1, "Write report", ""},
+		{3, 1, 2, "Call mom", "r"},
+		{3, 1, -1, "Ask for a raise", "a"},
+	}
+
+	docs := make([]keysetDoc, 0, len(values))
+	for _, v := range values {
+		doc := keysetDoc{
+			"_id":       primitive.NewObjectID(),
+			"createdAt": day.Add(time.Duration(v.created) * time.Hour),
+			"updatedAt": day.Add(time.Duration(v.updated) * time.Hour),
+			"task":      v.task,
+			"dueAt":     nil,
+			"rank":      nil,
+		}
+		if v.due >= 0 {
+			doc["dueAt"] = day.AddDate(0, 0, v.due)
+		}
+		if v.rank != "" {
+			doc["rank"] = v.rank
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+func TestTodoKeyset(t *testing.T) {
+	fields := []string{model.TodoSortCreated, model.TodoSortUpdated, model.TodoSortDue, model.TodoSortTask, model.TodoSortRank}
+
+	for _, field := range fields {
+		for _, desc := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s desc=%v", field, desc), func(t *testing.T) {
+				// the order of the listing, field then _id in the same direction
+				docs := keysetDocs()
+				sort.Slice(docs, func(i, j int) bool {
+					c := compareValues(docs[i][field], docs[j][field])
+					if c == 0 {
+						c = compareValues(docs[i]["_id"], docs[j]["_id"])
+					}
+					if desc {
+						return c > 0
+					}
+					return c < 0
+				})
+
+				// continuing after any todo lists exactly the ones after it, ties included
+				for i, last := range docs {
+					after := model.TodoCursor{Value: last[field], ID: last["_id"].(primitive.ObjectID)}
+					keyset := todoKeyset(field, desc, after)
+
+					var rest []any
+					for _, doc := range docs {
+						if matchesFilter(doc, keyset) {
+							rest = append(rest, doc["_id"])
+						}
+					}
+					var want []any
+					for _, doc := range docs[i+1:] {
+						want = append(want, doc["_id"])
+					}
+					if fmt.Sprint(rest) != fmt.Sprint(want) {
+						t.Fatalf("after %d (%v): got %v, want %v", i, last[field], rest, want)
+					}
+				}
+			})
+		}
+	}
+}
@@ -5,22 +5,46 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/ndk123-web/fast-todo/internal/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type TodoRepository interface {
-	GetAll(ctx context.Context) ([]model.Todo, error)
+	ListAll(ctx context.Context, skip int64, limit int64) ([]model.Todo, int64, error)
 	CreateTodo(ctx context.Context, todo model.Todo, workspaceId string, userId string) (model.Todo, error)
-	UpdateTodo(ctx context.Context, todoId string, updatedTask string, priority string) (model.Todo, error)
-	DeleteTodo(ctx context.Context, todoId string) (bool, error)
-	GetSpecificTodo(ctx context.Context, workspaceId string, userId string) ([]model.Todo, error)
-	ToggleTodo(ctx context.Context, todoId string, toggle string, userId string) (bool, error)
+	UpdateTodo(ctx context.Context, todoId string, update model.TodoUpdate, userId string) (model.Todo, error)
+	DeleteTodo(ctx context.Context, todoId string, userId string) (bool, error)
+	GetSpecificTodo(ctx context.Context, workspaceId string, userId string, filter model.TodoFilter) ([]model.Todo, error)
+	GetTodo(ctx context.Context, todoId string, userId string) (model.Todo, error)
+	SetStatus(ctx context.Context, todoId string, userId string, from string, change model.StatusChange) (model.Todo, error)
+	MigrateStatus(ctx context.Context) (int64, error)
 	AnalyticsOfTodos(ctx context.Context, year string, userId string, workspaceId string) (any, error)
+	SetRecurrence(ctx context.Context, todoId string, userId string, recurrence *model.Recurrence) error
+	ClaimNextInstance(ctx context.Context, todoId string, userId string) (bool, error)
+	UpdateLaterInstances(ctx context.Context, userId string, seriesId string, after time.Time, task string, priority string, template *model.Recurrence) error
+	AddSeriesException(ctx context.Context, userId string, seriesId string, occurrence time.Time) error
+	AddChecklistItem(ctx context.Context, todoId string, userId string, item model.ChecklistItem) (model.Todo, error)
+	UpdateChecklistItem(ctx context.Context, todoId string, userId string, itemId string, text *string, done *bool) (model.Todo, error)
+	ReorderChecklist(ctx context.Context, todoId string, userId string, items []model.ChecklistItem) (model.Todo, error)
+	DeleteChecklistItem(ctx context.Context, todoId string, userId string, itemId string) (model.Todo, error)
+	ListChildren(ctx context.Context, parentId string, userId string) ([]model.Todo, error)
+	DeleteChildren(ctx context.Context, parentId string, userId string) error
+	ChildProgress(ctx context.Context, userId string, parentIds []primitive.ObjectID) (map[primitive.ObjectID]model.TodoProgress, error)
+	RenameLabel(ctx context.Context, userId string, workspaceId string, oldName string, newName string) error
+	RemoveLabel(ctx context.Context, userId string, workspaceId string, name string) error
+	LastRank(ctx context.Context, userId string, workspaceId string, status string) (string, error)
+	NeighbourRank(ctx context.Context, userId string, workspaceId string, status string, rank string, below bool, skipId string) (string, error)
+	SetRank(ctx context.Context, todoId string, userId string, rank string) (model.Todo, error)
+	ListColumn(ctx context.Context, userId string, workspaceId string, status string) ([]model.Todo, error)
+	SetRanks(ctx context.Context, userId string, status string, changes []model.RankChange) error
+	SetWorkspace(ctx context.Context, todoId string, userId string, workspaceId string, rank string) (model.Todo, error)
+	InsertTodos(ctx context.Context, todos []model.Todo) error
 }
 
 // todoRepo implements TodoRepository with MongoDB as the data store
@@ -28,76 +52,100 @@ type todoRepo struct {
 	collection *mongo.Collection // MongoDB collection for todos
 }
 
-// GetAll retrieves all todo items from the database
-func (r *todoRepo) GetAll(ctx context.Context) ([]model.Todo, error) {
-	cursor, err := r.collection.Find(ctx, bson.D{})
+// ListAll pages through the todos of every user, newest first, with the total count (admin API)
+func (r *todoRepo) ListAll(ctx context.Context, skip int64, limit int64) ([]model.Todo, int64, error) {
+	total, err := r.collection.CountDocuments(ctx, bson.D{})
+	if err != nil {
+		return nil, 0, err
+	}
 
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := r.collection.Find(ctx, bson.D{}, opts)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	// in the end close the curson
 	defer cursor.Close(ctx)
 
 	// result will be here
-	var todos []model.Todo
+	todos := []model.Todo{}
 
 	// loop over the response
 	for cursor.Next(ctx) {
 		var todo model.Todo
 		if err := cursor.Decode(&todo); err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 
 		// append todos
 		todos = append(todos, todo)
 	}
 
-	return todos, nil
+	return todos, total, cursor.Err()
 }
 
-func (r *todoRepo) ToggleTodo(ctx context.Context, todoId, toggle, userId string) (bool, error) {
-	if todoId == "" || toggle == "" || userId == "" {
-		return false, errors.New("missing required fields: todoId/toggle/userId")
-	}
-
-	fmt.Println("TodoId: ", todoId)
-	fmt.Println("Toggle: ", toggle)
-	fmt.Println("UserId: ", userId)
+// ErrStatusChanged is returned when the status of a todo changed while it was being moved
+var ErrStatusChanged = errors.New("the status of this todo was changed meanwhile, reload it")
 
-	todoOid, err := primitive.ObjectIDFromHex(todoId)
-	if err != nil {
-		return false, err
-	}
-	userOid, err := primitive.ObjectIDFromHex(userId)
+// SetStatus moves a todo from status from to change.Status, Done follows the status
+// matching on from makes two clients moving the same todo at once fail instead of both winning
+func (r *todoRepo) SetStatus(ctx context.Context, todoId string, userId string, from string, change model.StatusChange) (model.Todo, error) {
+	oid, userOid, err := todoAndOwner(todoId, userId)
 	if err != nil {
-		return false, err
+		return model.Todo{}, err
 	}
+	filter := bson.M{"_id": oid, "userId": userOid}
 
-	// determine bool value from toggle
-	var doneValue bool
-	switch toggle {
-	case "completed":
-		doneValue = true
-	case "not-started":
-		doneValue = false
-	default:
-		return false, errors.New("invalid toggle value")
+	set := bson.M{"status": change.Status, "done": change.Status == model.TodoStatusDone, "updatedAt": time.Now()}
+	if change.Rank != "" {
+		set["rank"] = change.Rank
+	}
+	unset := bson.M{}
+	setDate(set, unset, "startedAt", change.StartedAt)
+	setDate(set, unset, "completedAt", change.CompletedAt)
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
 	}
 
-	filter := bson.M{"_id": todoOid, "userId": userOid}
-	update := bson.M{"$set": bson.M{"done": doneValue}}
-
-	updated, err := r.collection.UpdateOne(ctx, filter, update)
+	var updated model.Todo
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.collection.FindOneAndUpdate(ctx, bson.M{"_id": oid, "userId": userOid, "status": from}, update, opts).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// either gone / not ours or moved by someone else
+		count, err := r.collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return model.Todo{}, err
+		}
+		if count == 0 {
+			return model.Todo{}, ErrNotFound
+		}
+		return model.Todo{}, ErrStatusChanged
+	}
 	if err != nil {
-		return false, err
+		return model.Todo{}, err
 	}
+	return updated, nil
+}
 
-	if updated.ModifiedCount == 0 {
-		return false, errors.New("no todo found for update")
+// MigrateStatus gives todos from before the status workflow a status from their done flag,
+// done ones take updatedAt as completedAt, safe to run on every start
+func (r *todoRepo) MigrateStatus(ctx context.Context) (int64, error) {
+	done, err := r.collection.UpdateMany(ctx,
+		bson.M{"status": bson.M{"$exists": false}, "done": true},
+		bson.A{bson.M{"$set": bson.M{"status": model.TodoStatusDone, "completedAt": "$updatedAt"}}})
+	if err != nil {
+		return 0, err
 	}
 
-	return true, nil
+	open, err := r.collection.UpdateMany(ctx,
+		bson.M{"status": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"status": model.TodoStatusNotStarted, "done": false}})
+	if err != nil {
+		return done.ModifiedCount, err
+	}
+	return done.ModifiedCount + open.ModifiedCount, nil
 }
 
 // CreateTodo adds a new todo item to the database
@@ -118,6 +166,10 @@ func (r *todoRepo) CreateTodo(ctx context.Context, todo model.Todo, workspaceId
 
 	todo.WorkspaceId = workspaceOid
 	todo.UserId = userOid
+	if todo.Status == "" {
+		todo.Status = model.TodoStatusNotStarted
+	}
+	todo.Done = todo.Status == model.TodoStatusDone
 	todo.CreatedAt = time.Now()
 	todo.UpdatedAt = time.Now()
 
@@ -130,10 +182,11 @@ func (r *todoRepo) CreateTodo(ctx context.Context, todo model.Todo, workspaceId
 	return todo, nil
 }
 
-// UpdateTodo modifies an existing todo's task text
-func (r *todoRepo) UpdateTodo(ctx context.Context, todoId string, updatedTask string, priority string) (model.Todo, error) {
-	if todoId == "" {
-		return model.Todo{}, errors.New("Todo Id is Empty")
+// UpdateTodo modifies an existing todo's task text, priority and dates
+// only todos owned by userId are matched
+func (r *todoRepo) UpdateTodo(ctx context.Context, todoId string, todoUpdate model.TodoUpdate, userId string) (model.Todo, error) {
+	if todoId == "" || userId == "" {
+		return model.Todo{}, errors.New("Todo Id / UserId is Empty")
 	}
 
 	// convert the string id to object Id
@@ -142,50 +195,166 @@ func (r *todoRepo) UpdateTodo(ctx context.Context, todoId string, updatedTask st
 	if err2 != nil {
 		return model.Todo{}, err2
 	}
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
 
 	// always convert string -> object id
-	filter := bson.M{"_id": oid}
-	update := bson.M{"$set": bson.M{"task": updatedTask, "priority": priority}}
+	filter := bson.M{"_id": oid, "userId": userOid}
+	set := bson.M{"updatedAt": time.Now()}
+	unset := bson.M{}
+	if todoUpdate.Task != nil {
+		set["task"] = *todoUpdate.Task
+	}
+	if todoUpdate.Priority != nil {
+		set["priority"] = *todoUpdate.Priority
+	}
+	setDate(set, unset, "dueAt", todoUpdate.DueAt)
+	setDate(set, unset, "startAt", todoUpdate.StartAt)
+	if todoUpdate.AutoComplete != nil {
+		set["autoComplete"] = *todoUpdate.AutoComplete
+	}
+	if todoUpdate.Labels != nil {
+		if len(*todoUpdate.Labels) == 0 {
+			unset["labels"] = ""
+		} else {
+			set["labels"] = *todoUpdate.Labels
+		}
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
 
-	_, err := r.collection.UpdateOne(ctx, filter, update)
+	updated, err := r.collection.UpdateOne(ctx, filter, update)
 
 	if err != nil {
 		return model.Todo{}, err
 	}
 
+	if updated.MatchedCount == 0 {
+		return model.Todo{}, ErrNotFound
+	}
+
 	// find the todo
 	var updatedTodo model.Todo
 	err3 := r.collection.FindOne(ctx, filter).Decode(&updatedTodo)
 
 	if err3 != nil {
-		return model.Todo{}, err
+		return model.Todo{}, err3
 	}
 
 	return updatedTodo, nil
 }
 
+// dateRange adds from (inclusive) / before (exclusive) on field to filter, either may be nil
+func dateRange(filter bson.M, field string, from *time.Time, before *time.Time) {
+	bounds := bson.M{}
+	if from != nil {
+		bounds["$gte"] = *from
+	}
+	if before != nil {
+		bounds["$lt"] = *before
+	}
+	if len(bounds) > 0 {
+		filter[field] = bounds
+	}
+}
+
+// todoKeyset matches the todos sorted after the cursor: a later sort value or the same
+// one with a later _id, todos without due date (or rank) come before every dated one
+// ascending and after them descending
+func todoKeyset(field string, desc bool, after model.TodoCursor) bson.M {
+	cmp := "$gt"
+	if desc {
+		cmp = "$lt"
+	}
+
+	if after.Value == nil {
+		sameNull := bson.M{field: nil, "_id": bson.M{cmp: after.ID}}
+		if desc {
+			return sameNull
+		}
+		return bson.M{"$or": bson.A{sameNull, bson.M{field: bson.M{"$ne": nil}}}}
+	}
+
+	or := bson.A{
+		bson.M{field: bson.M{cmp: after.Value}},
+		bson.M{field: after.Value, "_id": bson.M{cmp: after.ID}},
+	}
+	if desc && (field == model.TodoSortDue || field == model.TodoSortRank) {
+		or = append(or, bson.M{field: nil})
+	}
+	return bson.M{"$or": or}
+}
+
+// setDate adds an optional date to an update, nil leaves it alone and a zero time removes it
+func setDate(set bson.M, unset bson.M, field string, value *time.Time) {
+	switch {
+	case value == nil:
+	case value.IsZero():
+		unset[field] = ""
+	default:
+		set[field] = *value
+	}
+}
+
 // DeleteTodo removes a todo item by its ID
-func (r *todoRepo) DeleteTodo(ctx context.Context, todoId string) (bool, error) {
+// only todos owned by userId are matched
+func (r *todoRepo) DeleteTodo(ctx context.Context, todoId string, userId string) (bool, error) {
 
 	// string -> ObjectId
 	oid, err := primitive.ObjectIDFromHex(todoId)
 	if err != nil {
 		return false, err
 	}
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false, err
+	}
 
-	// filter with Object ID
-	filter := bson.M{"_id": oid}
+	// filter with Object ID and owner
+	filter := bson.M{"_id": oid, "userId": userOid}
 
 	// filter and delete Document
-	_, err2 := r.collection.DeleteOne(ctx, filter)
+	deleted, err2 := r.collection.DeleteOne(ctx, filter)
 	if err2 != nil {
-		return false, err
+		return false, err2
+	}
+
+	if deleted.DeletedCount == 0 {
+		return false, ErrNotFound
 	}
 
 	return true, nil
 }
 
-func (r *todoRepo) GetSpecificTodo(ctx context.Context, workspaceId string, userId string) ([]model.Todo, error) {
+// GetTodo returns one todo of userId, ErrNotFound when it doesn't exist or isn't theirs
+func (r *todoRepo) GetTodo(ctx context.Context, todoId string, userId string) (model.Todo, error) {
+	oid, err := primitive.ObjectIDFromHex(todoId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	var todo model.Todo
+	if err := r.collection.FindOne(ctx, bson.M{"_id": oid, "userId": userOid}).Decode(&todo); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Todo{}, ErrNotFound
+		}
+		return model.Todo{}, err
+	}
+
+	return todo, nil
+}
+
+// GetSpecificTodo lists the todos of a workspace, a date filter sorts them by due date
+func (r *todoRepo) GetSpecificTodo(ctx context.Context, workspaceId string, userId string, todoFilter model.TodoFilter) ([]model.Todo, error) {
 	// convert workspaceId and UserId into object
 	workspaceOid, err := primitive.ObjectIDFromHex(workspaceId)
 	if err != nil {
@@ -198,17 +367,63 @@ func (r *todoRepo) GetSpecificTodo(ctx context.Context, workspaceId string, user
 
 	// filter the documents
 	filter := bson.M{"workspaceId": workspaceOid, "userId": userOid}
-	cursor, err := r.collection.Find(ctx, filter)
 
-	// otherwise cursor remains open and can cause memory leaks
-	defer cursor.Close(ctx)
+	// the { userId, workspaceId, <sort field>, _id } indexes serve the date filters and sorts
+	dateRange(filter, "dueAt", todoFilter.DueFrom, todoFilter.DueBefore)
+	dateRange(filter, "createdAt", todoFilter.CreatedFrom, todoFilter.CreatedBefore)
+	dateRange(filter, "updatedAt", todoFilter.UpdatedFrom, todoFilter.UpdatedBefore)
+	if todoFilter.NoDueDate {
+		// matches todos without the field too
+		filter["dueAt"] = nil
+	}
+	if todoFilter.OpenOnly {
+		filter["status"] = bson.M{"$nin": bson.A{model.TodoStatusDone, model.TodoStatusCancelled}}
+	}
+	if len(todoFilter.Labels) > 0 {
+		if todoFilter.LabelMatch == model.LabelMatchAll {
+			filter["labels"] = bson.M{"$all": todoFilter.Labels}
+		} else {
+			filter["labels"] = bson.M{"$in": todoFilter.Labels}
+		}
+	}
+	if len(todoFilter.Statuses) > 0 {
+		// an explicit status list wins over OpenOnly
+		filter["status"] = bson.M{"$in": todoFilter.Statuses}
+	}
+	if len(todoFilter.Priorities) > 0 {
+		filter["priority"] = bson.M{"$in": todoFilter.Priorities}
+	}
+	if todoFilter.TaskPrefix != "" {
+		filter["task"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(todoFilter.TaskPrefix), Options: "i"}
+	}
+
+	sortField := todoFilter.Sort
+	if sortField == "" {
+		sortField = model.TodoSortCreated
+	}
+	direction := 1
+	if todoFilter.Desc {
+		direction = -1
+	}
+	if todoFilter.After != nil {
+		filter["$and"] = bson.A{todoKeyset(sortField, todoFilter.Desc, *todoFilter.After)}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: sortField, Value: direction}, {Key: "_id", Value: direction}})
+	if todoFilter.Limit > 0 {
+		opts.SetLimit(todoFilter.Limit)
+	}
 
+	cursor, err := r.collection.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	// get into the todos
-	var todos []model.Todo
+	// otherwise cursor remains open and can cause memory leaks
+	defer cursor.Close(ctx)
+
+	// get into the todos, an empty workspace is an empty list and not null in the json
+	todos := []model.Todo{}
 	for cursor.Next(ctx) {
 		var todo model.Todo
 		if err := cursor.Decode(&todo); err != nil {
@@ -312,6 +527,451 @@ func (r *todoRepo) AnalyticsOfTodos(ctx context.Context, year string, userId str
 	return result, nil
 }
 
+// SetRecurrence replaces the recurrence of one todo, nil makes it a plain todo again
+func (r *todoRepo) SetRecurrence(ctx context.Context, todoId string, userId string, recurrence *model.Recurrence) error {
+	oid, err := primitive.ObjectIDFromHex(todoId)
+	if err != nil {
+		return err
+	}
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{"recurrence": recurrence, "updatedAt": time.Now()}}
+	if recurrence == nil {
+		update = bson.M{"$unset": bson.M{"recurrence": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+	}
+
+	updated, err := r.collection.UpdateOne(ctx, bson.M{"_id": oid, "userId": userOid}, update)
+	if err != nil {
+		return err
+	}
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ClaimNextInstance marks that the next instance of a repeating todo is being created,
+// only the first caller gets true so completing twice (or two tabs at once) creates one
+func (r *todoRepo) ClaimNextInstance(ctx context.Context, todoId string, userId string) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(todoId)
+	if err != nil {
+		return false, err
+	}
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"_id": oid, "userId": userOid, "recurrence": bson.M{"$exists": true}, "recurrence.nextCreated": bson.M{"$ne": true}}
+	updated, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"recurrence.nextCreated": true}})
+	if err != nil {
+		return false, err
+	}
+	return updated.ModifiedCount > 0, nil
+}
+
+// UpdateLaterInstances carries a "this and future" edit to the instances of the series scheduled
+// after after, a nil template stops them repeating, their own occurrence and dates are kept
+func (r *todoRepo) UpdateLaterInstances(ctx context.Context, userId string, seriesId string, after time.Time, task string, priority string, template *model.Recurrence) error {
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+	seriesOid, err := primitive.ObjectIDFromHex(seriesId)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"userId": userOid, "recurrence.seriesId": seriesOid, "recurrence.occurrence": bson.M{"$gt": after}}
+	set := bson.M{"task": task, "priority": priority, "updatedAt": time.Now()}
+	update := bson.M{"$set": set}
+	if template == nil {
+		update["$unset"] = bson.M{"recurrence": ""}
+	} else {
+		set["recurrence.rrule"] = template.RRule
+		set["recurrence.timeZone"] = template.TimeZone
+		set["recurrence.start"] = template.Start
+		set["recurrence.exceptions"] = template.Exceptions
+		set["recurrence.seriesTask"] = template.SeriesTask
+		set["recurrence.seriesPriority"] = template.SeriesPriority
+		set["recurrence.startOffset"] = template.StartOffset
+	}
+
+	_, err = r.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// AddSeriesException skips occurrence in every instance of the series, so whichever
+// instance creates the next one knows about it
+func (r *todoRepo) AddSeriesException(ctx context.Context, userId string, seriesId string, occurrence time.Time) error {
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+	seriesOid, err := primitive.ObjectIDFromHex(seriesId)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"userId": userOid, "recurrence.seriesId": seriesOid}
+	updated, err := r.collection.UpdateMany(ctx, filter, bson.M{"$addToSet": bson.M{"recurrence.exceptions": occurrence}})
+	if err != nil {
+		return err
+	}
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// todoAndOwner converts the ids every checklist / subtask query filters on
+func todoAndOwner(todoId string, userId string) (primitive.ObjectID, primitive.ObjectID, error) {
+	oid, err := primitive.ObjectIDFromHex(todoId)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return primitive.NilObjectID, primitive.NilObjectID, err
+	}
+	return oid, userOid, nil
+}
+
+// updateAndReturn applies update to the todo matched by filter and returns it as it is afterwards
+func (r *todoRepo) updateAndReturn(ctx context.Context, filter bson.M, update bson.M) (model.Todo, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var todo model.Todo
+	if err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&todo); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Todo{}, ErrNotFound
+		}
+		return model.Todo{}, err
+	}
+	return todo, nil
+}
+
+// AddChecklistItem appends item to the end of the checklist
+func (r *todoRepo) AddChecklistItem(ctx context.Context, todoId string, userId string, item model.ChecklistItem) (model.Todo, error) {
+	oid, userOid, err := todoAndOwner(todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	update := bson.M{"$push": bson.M{"checklist": item}, "$set": bson.M{"updatedAt": time.Now()}}
+	return r.updateAndReturn(ctx, bson.M{"_id": oid, "userId": userOid}, update)
+}
+
+// UpdateChecklistItem changes the text and / or done state of one item, nil leaves it alone
+func (r *todoRepo) UpdateChecklistItem(ctx context.Context, todoId string, userId string, itemId string, text *string, done *bool) (model.Todo, error) {
+	oid, userOid, err := todoAndOwner(todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	itemOid, err := primitive.ObjectIDFromHex(itemId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	set := bson.M{"updatedAt": time.Now()}
+	if text != nil {
+		set["checklist.$.text"] = *text
+	}
+	if done != nil {
+		set["checklist.$.done"] = *done
+	}
+
+	filter := bson.M{"_id": oid, "userId": userOid, "checklist._id": itemOid}
+	return r.updateAndReturn(ctx, filter, bson.M{"$set": set})
+}
+
+// ReorderChecklist stores items in their new order, it only matches while the checklist still
+// holds exactly these items so an item added meanwhile isn't lost (ErrNotFound then)
+func (r *todoRepo) ReorderChecklist(ctx context.Context, todoId string, userId string, items []model.ChecklistItem) (model.Todo, error) {
+	oid, userOid, err := todoAndOwner(todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	itemIds := make([]primitive.ObjectID, len(items))
+	for i, item := range items {
+		itemIds[i] = item.ID
+	}
+
+	filter := bson.M{"_id": oid, "userId": userOid, "checklist": bson.M{"$size": len(items)}, "checklist._id": bson.M{"$all": itemIds}}
+	update := bson.M{"$set": bson.M{"checklist": items, "updatedAt": time.Now()}}
+	return r.updateAndReturn(ctx, filter, update)
+}
+
+func (r *todoRepo) DeleteChecklistItem(ctx context.Context, todoId string, userId string, itemId string) (model.Todo, error) {
+	oid, userOid, err := todoAndOwner(todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	itemOid, err := primitive.ObjectIDFromHex(itemId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	filter := bson.M{"_id": oid, "userId": userOid, "checklist._id": itemOid}
+	update := bson.M{"$pull": bson.M{"checklist": bson.M{"_id": itemOid}}, "$set": bson.M{"updatedAt": time.Now()}}
+	return r.updateAndReturn(ctx, filter, update)
+}
+
+// ListChildren returns the subtasks of parentId, oldest first
+func (r *todoRepo) ListChildren(ctx context.Context, parentId string, userId string) ([]model.Todo, error) {
+	parentOid, userOid, err := todoAndOwner(parentId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"parentId": parentOid, "userId": userOid}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	children := []model.Todo{}
+	if err := cursor.All(ctx, &children); err != nil {
+		return nil, err
+	}
+	return children, nil
+}
+
+// DeleteChildren removes the subtasks of parentId, used when the parent is deleted
+func (r *todoRepo) DeleteChildren(ctx context.Context, parentId string, userId string) error {
+	parentOid, userOid, err := todoAndOwner(parentId, userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.DeleteMany(ctx, bson.M{"parentId": parentOid, "userId": userOid})
+	return err
+}
+
+// ChildProgress counts the subtasks (done / total) of each of parentIds in one aggregation,
+// parents without subtasks are left out of the map
+func (r *todoRepo) ChildProgress(ctx context.Context, userId string, parentIds []primitive.ObjectID) (map[primitive.ObjectID]model.TodoProgress, error) {
+	progress := map[primitive.ObjectID]model.TodoProgress{}
+	if len(parentIds) == 0 {
+		return progress, nil
+	}
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := mongo.Pipeline{
+		// a cancelled subtask doesn't count either way
+		{{Key: "$match", Value: bson.M{"userId": userOid, "parentId": bson.M{"$in": parentIds}, "status": bson.M{"$ne": model.TodoStatusCancelled}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$parentId",
+			"total": bson.M{"$sum": 1},
+			"done":  bson.M{"$sum": bson.M{"$cond": bson.A{"$done", 1, 0}}},
+		}}},
+	}
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var row struct {
+			ParentId primitive.ObjectID `bson:"_id"`
+			Total    int                `bson:"total"`
+			Done     int                `bson:"done"`
+		}
+		if err := cursor.Decode(&row); err != nil {
+			return nil, err
+		}
+		progress[row.ParentId] = model.TodoProgress{Done: row.Done, Total: row.Total}
+	}
+	return progress, cursor.Err()
+}
+
+// labelFilter matches the todos of a workspace carrying the label name
+func labelFilter(userId string, workspaceId string, name string) (bson.M, error) {
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+	workspaceOid, err := primitive.ObjectIDFromHex(workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"userId": userOid, "workspaceId": workspaceOid, "labels": name}, nil
+}
+
+// RenameLabel replaces oldName by newName on every todo of the workspace carrying it
+func (r *todoRepo) RenameLabel(ctx context.Context, userId string, workspaceId string, oldName string, newName string) error {
+	filter, err := labelFilter(userId, workspaceId, oldName)
+	if err != nil {
+		return err
+	}
+
+	// a todo holds a label once, so the positional operator hits the only match
+	_, err = r.collection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"labels.$": newName, "updatedAt": time.Now()}})
+	return err
+}
+
+// RemoveLabel takes the label name off every todo of the workspace
+func (r *todoRepo) RemoveLabel(ctx context.Context, userId string, workspaceId string, name string) error {
+	filter, err := labelFilter(userId, workspaceId, name)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.UpdateMany(ctx, filter, bson.M{"$pull": bson.M{"labels": name}, "$set": bson.M{"updatedAt": time.Now()}})
+	return err
+}
+
+// columnFilter matches the todos of one status column of a workspace
+func columnFilter(userId string, workspaceId string, status string) (bson.M, error) {
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+	workspaceOid, err := primitive.ObjectIDFromHex(workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"userId": userOid, "workspaceId": workspaceOid, "status": status}, nil
+}
+
+// findRank returns the rank of the first todo filter matches in sort order, "" when there is none
+func (r *todoRepo) findRank(ctx context.Context, filter bson.M, direction int) (string, error) {
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: "rank", Value: direction}, {Key: "_id", Value: direction}}).
+		SetProjection(bson.M{"rank": 1})
+
+	var todo model.Todo
+	err := r.collection.FindOne(ctx, filter, opts).Decode(&todo)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", nil
+	}
+	return todo.Rank, err
+}
+
+// LastRank is the highest rank of the column, "" for a column without ranked todos
+func (r *todoRepo) LastRank(ctx context.Context, userId string, workspaceId string, status string) (string, error) {
+	filter, err := columnFilter(userId, workspaceId, status)
+	if err != nil {
+		return "", err
+	}
+	return r.findRank(ctx, filter, -1)
+}
+
+// NeighbourRank is the rank right below rank in the column (or right above it), skipId is
+// the todo being moved, "" when rank is the last (first) of the column
+func (r *todoRepo) NeighbourRank(ctx context.Context, userId string, workspaceId string, status string, rank string, below bool, skipId string) (string, error) {
+	filter, err := columnFilter(userId, workspaceId, status)
+	if err != nil {
+		return "", err
+	}
+	skipOid, err := primitive.ObjectIDFromHex(skipId)
+	if err != nil {
+		return "", err
+	}
+	filter["_id"] = bson.M{"$ne": skipOid}
+
+	if below {
+		filter["rank"] = bson.M{"$gt": rank}
+		return r.findRank(ctx, filter, 1)
+	}
+	filter["rank"] = bson.M{"$lt": rank}
+	return r.findRank(ctx, filter, -1)
+}
+
+// SetRank moves one todo within its column, nothing else is written
+func (r *todoRepo) SetRank(ctx context.Context, todoId string, userId string, rank string) (model.Todo, error) {
+	oid, userOid, err := todoAndOwner(todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	return r.updateAndReturn(ctx, bson.M{"_id": oid, "userId": userOid}, bson.M{"$set": bson.M{"rank": rank}})
+}
+
+// ListColumn returns the ids / ranks of a column in its order, unranked todos first by _id
+func (r *todoRepo) ListColumn(ctx context.Context, userId string, workspaceId string, status string) ([]model.Todo, error) {
+	filter, err := columnFilter(userId, workspaceId, status)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "rank", Value: 1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"rank": 1})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	todos := []model.Todo{}
+	if err := cursor.All(ctx, &todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// SetRanks rewrites the ranks of a column in one go, a todo that was moved (or changed
+// status) since its rank was read keeps what it has now
+func (r *todoRepo) SetRanks(ctx context.Context, userId string, status string, changes []model.RankChange) error {
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(changes))
+	for _, change := range changes {
+		filter := bson.M{"_id": change.ID, "userId": userOid, "status": status, "rank": change.From}
+		if change.From == "" {
+			filter["rank"] = nil
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(bson.M{"$set": bson.M{"rank": change.To}}))
+	}
+
+	_, err = r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// SetWorkspace moves a todo to another workspace at rank, everything else (timestamps too) stays as it is
+func (r *todoRepo) SetWorkspace(ctx context.Context, todoId string, userId string, workspaceId string, rank string) (model.Todo, error) {
+	oid, userOid, err := todoAndOwner(todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	workspaceOid, err := primitive.ObjectIDFromHex(workspaceId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	return r.updateAndReturn(ctx, bson.M{"_id": oid, "userId": userOid}, bson.M{"$set": bson.M{"workspaceId": workspaceOid, "rank": rank}})
+}
+
+// InsertTodos stores todos as they are (ids, owner and timestamps set by the caller), used for copies
+func (r *todoRepo) InsertTodos(ctx context.Context, todos []model.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	docs := make([]any, 0, len(todos))
+	for _, todo := range todos {
+		docs = append(docs, todo)
+	}
+	_, err := r.collection.InsertMany(ctx, docs)
+	return err
+}
+
 // NewTodoRepository creates and returns a new instance of TodoRepository
 // It initializes the MongoDB collection for todo operations
 func NewTodoRepository(col *mongo.Collection) TodoRepository {
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// OneTimeTokenRepository keeps short lived single-use tokens (password reset...) in redis
+// only a sha256 of the token is stored so a redis dump can't be replayed
+type OneTimeTokenRepository interface {
+	SaveToken(ctx context.Context, purpose string, token string, userId string, ttl time.Duration) error
+	ConsumeToken(ctx context.Context, purpose string, token string) (string, error)
+}
+
+// ErrInvalidToken is returned when a one-time token is unknown, expired or already used
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+type oneTimeTokenRepository struct {
+	rdb *redis.Client
+}
+
+func oneTimeTokenKey(purpose string, token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("token:%s:%s", purpose, hex.EncodeToString(sum[:]))
+}
+
+func (r *oneTimeTokenRepository) SaveToken(ctx context.Context, purpose string, token string, userId string, ttl time.Duration) error {
+	if purpose == "" || token == "" || userId == "" {
+		return errors.New("Purpose / Token / UserId is Empty in Repo")
+	}
+
+	return r.rdb.Set(ctx, oneTimeTokenKey(purpose, token), userId, ttl).Err()
+}
+
+// ConsumeToken returns the userId the token was issued for and deletes it in the same call
+func (r *oneTimeTokenRepository) ConsumeToken(ctx context.Context, purpose string, token string) (string, error) {
+	if purpose == "" || token == "" {
+		return "", ErrInvalidToken
+	}
+
+	userId, err := r.rdb.GetDel(ctx, oneTimeTokenKey(purpose, token)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrInvalidToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return userId, nil
+}
+
+func NewOneTimeTokenRepository(rdb *redis.Client) OneTimeTokenRepository {
+	return &oneTimeTokenRepository{
+		rdb: rdb,
+	}
+}
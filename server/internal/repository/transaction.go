@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactor runs work in a mongo transaction, every repository call made with the
+// ctx handed to fn joins it
+type Transactor interface {
+	// Supported is false on a standalone server, transactions need a replica set or mongos
+	Supported() bool
+	// WithTransaction commits when fn returns nil and aborts otherwise, fn may run more
+	// than once when mongo asks for a retry (transient errors) so it must start clean
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transactor struct {
+	client    *mongo.Client
+	supported bool
+}
+
+func (t *transactor) Supported() bool {
+	return t.supported
+}
+
+func (t *transactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := t.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionCtx mongo.SessionContext) (any, error) {
+		return nil, fn(sessionCtx)
+	})
+	return err
+}
+
+// NewTransactor asks the server once whether it is part of a replica set / a mongos
+func NewTransactor(ctx context.Context, client *mongo.Client) Transactor {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		log.Println("mongo hello failed, running without transactions:", err)
+	}
+
+	return &transactor{
+		client:    client,
+		supported: hello.SetName != "" || hello.Msg == "isdbgrid",
+	}
+}
@@ -2,7 +2,11 @@ package repository
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/ndk123-web/fast-todo/internal/model"
@@ -10,6 +14,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -19,6 +24,16 @@ type UserStruct struct {
 	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
 	FullName  string    `json:"fullName,omitempty" bson:"fullName"`
+	// Verified is false until the email link is opened, Google sign ups are verified by Google
+	Verified bool `json:"verified" bson:"verified"`
+	// PasswordSet is false for Google sign ups, their stored password is random and unknown to the user
+	PasswordSet bool `json:"passwordSet" bson:"passwordSet"`
+	// GoogleUid is the Firebase uid of the linked Google identity
+	GoogleUid string `json:"googleUid,omitempty" bson:"googleUid,omitempty"`
+	// Identities are the linked identities of every other provider (OIDC), see identityFilter
+	Identities []model.LinkedIdentity `json:"identities,omitempty" bson:"identities,omitempty"`
+	// Role is one of model.Roles, admins are promoted through ADMIN_EMAILS or the admin API
+	Role string `json:"role" bson:"role"`
 }
 
 type UserRepository interface {
@@ -26,8 +41,28 @@ type UserRepository interface {
 	SignUpUser(ctx context.Context, email string, password string, fullName string) (*SignUpResponse, error)
 	SignInUser(ctx context.Context, email string, password string) (*SignUpResponse, error)
 	UpdateUserName(ctx context.Context, userId string, newName string) (bool, error)
-	SignInGoogleUser(ctx context.Context, email string, fullName string) (*SignUpResponse, error)
-	SignUpWithGoogle(ctx context.Context, email string, fullName string) (*SignUpResponse, error)
+	SignInWithIdentity(ctx context.Context, identity *model.ExternalIdentity) (*SignUpResponse, error)
+	SignUpWithIdentity(ctx context.Context, identity *model.ExternalIdentity) (*SignUpResponse, error)
+	GetUserByEmail(ctx context.Context, email string) (*SignInUserRequest, error)
+	UpdatePassword(ctx context.Context, userId string, hashedPassword string) error
+	GetUserById(ctx context.Context, userId string) (*SignInUserRequest, error)
+	MarkEmailVerified(ctx context.Context, userId string, email string) error
+	IsEmailVerified(ctx context.Context, userId string) (bool, error)
+	GetTwoFactor(ctx context.Context, userId string) (*model.TwoFactor, error)
+	SavePendingTwoFactor(ctx context.Context, userId string, secret string, recoveryCodeHashes []string) error
+	EnableTwoFactor(ctx context.Context, userId string, secret string, recoveryCodeHashes []string) error
+	DisableTwoFactor(ctx context.Context, userId string) error
+	UseRecoveryCode(ctx context.Context, userId string, recoveryCodeHash string) (bool, error)
+	LinkIdentity(ctx context.Context, userId string, provider string, subject string) error
+	UnlinkIdentity(ctx context.Context, userId string, provider string) error
+	ScheduleDeletion(ctx context.Context, userId string, purgeAt time.Time) error
+	CancelDeletion(ctx context.Context, userId string) (bool, error)
+	ListUsersDueForDeletion(ctx context.Context, now time.Time, limit int64) ([]string, error)
+	DeleteUser(ctx context.Context, userId string) error
+	GetUserRole(ctx context.Context, userId string) (string, error)
+	SetRole(ctx context.Context, userId string, role string) error
+	SetDisabled(ctx context.Context, userId string, disabled bool) error
+	ListUsers(ctx context.Context, search string, skip int64, limit int64) ([]UserSummary, int64, error)
 }
 
 type userRepo struct {
@@ -81,6 +116,13 @@ type SignUpResponse struct {
 	UserId       string `json:"userId"`
 	RefreshToken string `json:"_refreshToken"`
 	FullName     string `json:"fullName,omitempty"`
+	// EmailVerified lets the client show the "check your inbox" banner
+	EmailVerified bool `json:"emailVerified"`
+	// set instead of the tokens when the account has 2FA, the client then calls /2fa/verify
+	TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string `json:"challengeToken,omitempty"`
+	// true when this sign in cancelled a scheduled account deletion
+	DeletionCancelled bool `json:"deletionCancelled,omitempty"`
 }
 
 func (r *userRepo) SignUpUser(ctx context.Context, email string, password string, fullName string) (*SignUpResponse, error) {
@@ -98,7 +140,7 @@ func (r *userRepo) SignUpUser(ctx context.Context, email string, password string
 
 	// else safe
 	// we need to hash the password before storing inside DB
-	currentUser := UserStruct{Email: email, Password: password, CreatedAt: time.Now(), UpdatedAt: time.Now(), FullName: fullName}
+	currentUser := UserStruct{Email: email, Password: password, CreatedAt: time.Now(), UpdatedAt: time.Now(), FullName: fullName, Verified: false, PasswordSet: true, Role: model.RoleUser}
 	// we need to store the user in Mongodb
 	inserted, err := r.userColletion.InsertOne(ctx, currentUser)
 	if err != nil {
@@ -110,24 +152,37 @@ func (r *userRepo) SignUpUser(ctx context.Context, email string, password string
 	userId := oid.Hex()
 
 	return &SignUpResponse{
-		Email:    email,
-		UserId:   userId,
-		FullName: fullName,
+		Email:         email,
+		UserId:        userId,
+		FullName:      fullName,
+		EmailVerified: false,
 	}, nil
 }
 
-func (r *userRepo) SignUpWithGoogle(ctx context.Context, email string, fullName string) (*SignUpResponse, error) {
-	if email == "" || fullName == "" {
-		return nil, errors.New("Email/FullName is Missing in Repo")
+// identityFilter finds the user linked to subject of provider, Google links predate the
+// other providers and stay in "googleUid" so those accounts didn't need a migration
+func identityFilter(provider string, subject string) bson.M {
+	if provider == model.GoogleProvider {
+		return bson.M{"googleUid": subject}
+	}
+	return bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}
+}
+
+func (r *userRepo) SignUpWithIdentity(ctx context.Context, identity *model.ExternalIdentity) (*SignUpResponse, error) {
+	if identity.Subject == "" || identity.Email == "" || identity.FullName() == "" {
+		return nil, errors.New("Subject/Email/FullName is Missing in Repo")
 	}
 
 	// Try to find existing user
-	filter := bson.M{"email": email}
+	filter := bson.M{"email": identity.Email}
 
 	var existing SignInUserRequest
 	err := r.userColletion.FindOne(ctx, filter).Decode(&existing)
 	if err == nil {
-		// user exists -> error
+		// user exists -> error, a password account has to link the identity explicitly
+		if !existing.HasIdentity(identity.Provider) && existing.PasswordSet != nil {
+			return nil, ErrIdentityNotLinked
+		}
 		return nil, errors.New("User Already Exists")
 	}
 	if !errors.Is(err, mongo.ErrNoDocuments) {
@@ -135,19 +190,29 @@ func (r *userRepo) SignUpWithGoogle(ctx context.Context, email string, fullName
 		return nil, err
 	}
 	// no documents -> create new user
-	randPass := primitive.NewObjectID().Hex()
-	hashed, hErr := nbcrypt.BcryptForPassword(randPass)
+	// nobody knows this password (and HasPassword keeps it from being used), it is random so
+	// it can't be guessed either, an ObjectID would be next to the user's own _id
+	randBytes := make([]byte, 32)
+	if _, err := rand.Read(randBytes); err != nil {
+		return nil, err
+	}
+	hashed, hErr := nbcrypt.BcryptForPassword(hex.EncodeToString(randBytes))
 	if hErr != nil {
 		return nil, hErr
 	}
 	now := time.Now()
-	newUser := UserStruct{Email: email, Password: hashed, CreatedAt: now, UpdatedAt: now, FullName: fullName}
+	newUser := UserStruct{Email: identity.Email, Password: hashed, CreatedAt: now, UpdatedAt: now, FullName: identity.FullName(), Verified: true, PasswordSet: false, Role: model.RoleUser}
+	if identity.Provider == model.GoogleProvider {
+		newUser.GoogleUid = identity.Subject
+	} else {
+		newUser.Identities = []model.LinkedIdentity{{Provider: identity.Provider, Subject: identity.Subject}}
+	}
 	inserted, iErr := r.userColletion.InsertOne(ctx, newUser)
 	if iErr != nil {
 		return nil, iErr
 	}
 	oid := inserted.InsertedID.(primitive.ObjectID)
-	return &SignUpResponse{Email: email, UserId: oid.Hex(), FullName: fullName}, nil
+	return &SignUpResponse{Email: identity.Email, UserId: oid.Hex(), FullName: newUser.FullName, EmailVerified: true}, nil
 }
 
 type SignInUserRequest struct {
@@ -155,6 +220,63 @@ type SignInUserRequest struct {
 	ID             primitive.ObjectID `json:"_id" bson:"_id"`
 	HashedPassword string             `json:"password" bson:"password"`
 	FullName       string             `json:"fullName,omitempty" bson:"fullName,omitempty"`
+	// nil for accounts created before verification existed, they count as verified
+	Verified *bool `json:"verified,omitempty" bson:"verified,omitempty"`
+	// nil for accounts created before it was tracked, see HasPassword
+	PasswordSet *bool  `json:"passwordSet,omitempty" bson:"passwordSet,omitempty"`
+	GoogleUid   string `json:"googleUid,omitempty" bson:"googleUid,omitempty"`
+	// Identities of the providers other than Google
+	Identities []model.LinkedIdentity `json:"identities,omitempty" bson:"identities,omitempty"`
+	// DeletionScheduledAt is when the account gets purged, signing in before that cancels it
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty" bson:"deletionScheduledAt,omitempty"`
+	Role                string     `json:"role,omitempty" bson:"role,omitempty"`
+	// Disabled accounts can't sign in, set by an admin
+	Disabled  bool      `json:"disabled,omitempty" bson:"disabled,omitempty"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// GetRole treats accounts created before roles existed as RoleUser
+func (u *SignInUserRequest) GetRole() string {
+	if u.Role == "" {
+		return model.RoleUser
+	}
+	return u.Role
+}
+
+// HasIdentity tells if an identity of provider is linked to the user
+func (u *SignInUserRequest) HasIdentity(provider string) bool {
+	if provider == model.GoogleProvider {
+		return u.GoogleUid != ""
+	}
+	for _, identity := range u.Identities {
+		if identity.Provider == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// LinkedProviders lists the providers the user can sign in with besides the password
+func (u *SignInUserRequest) LinkedProviders() []string {
+	providers := []string{}
+	if u.GoogleUid != "" {
+		providers = append(providers, model.GoogleProvider)
+	}
+	for _, identity := range u.Identities {
+		providers = append(providers, identity.Provider)
+	}
+	return providers
+}
+
+// IsVerified treats accounts older than email verification as verified
+func (u *SignInUserRequest) IsVerified() bool {
+	return u.Verified == nil || *u.Verified
+}
+
+// HasPassword is false only for Google sign ups that never set a password
+// older accounts can't be told apart and count as having one (forgot password still works for them)
+func (u *SignInUserRequest) HasPassword() bool {
+	return u.PasswordSet == nil || *u.PasswordSet
 }
 
 func (r *userRepo) SignInUser(ctx context.Context, email string, password string) (*SignUpResponse, error) {
@@ -166,12 +288,27 @@ func (r *userRepo) SignInUser(ctx context.Context, email string, password string
 	res := r.userColletion.FindOne(ctx, filter)
 
 	var user SignInUserRequest
-	res.Decode(&user)
+	if err := res.Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	// identity sign ups never chose a password, only SetPassword / ResetPassword make one usable
+	if !user.HasPassword() {
+		return nil, ErrInvalidCredentials
+	}
 
 	//password checking
 	ok, err := nbcrypt.ValidatePassword(password, user.HashedPassword)
 	if !ok || err != nil {
-		return nil, err
+		return nil, ErrInvalidCredentials
+	}
+
+	// checked after the password so a disabled account isn't revealed to someone guessing
+	if user.Disabled {
+		return nil, ErrAccountDisabled
 	}
 
 	userId := user.ID.Hex()
@@ -182,39 +319,58 @@ func (r *userRepo) SignInUser(ctx context.Context, email string, password string
 	}
 
 	return &SignUpResponse{
-		Email:    email,
-		UserId:   userId,
-		FullName: user.FullName,
+		Email:         email,
+		UserId:        userId,
+		FullName:      user.FullName,
+		EmailVerified: user.IsVerified(),
 	}, nil
 }
 
-// SignInGoogleUser will upsert a user (create if not exists) without password auth
-// Used when Google ID token already verified upstream.
-func (r *userRepo) SignInGoogleUser(ctx context.Context, email string, fullName string) (*SignUpResponse, error) {
-	if email == "" {
-		return nil, errors.New("email empty")
+// ErrIdentityNotLinked is returned when an external identity matches the email of an account it isn't linked to
+var ErrIdentityNotLinked = errors.New("An account with this email already exists. Sign in with your password and link this sign in method from your settings")
+
+// SignInWithIdentity finds the user linked to the verified identity (does NOT create one)
+// Google accounts from before linking existed are matched by email and linked on the way,
+// any other account with that email has to link the identity explicitly
+func (r *userRepo) SignInWithIdentity(ctx context.Context, identity *model.ExternalIdentity) (*SignUpResponse, error) {
+	if identity.Subject == "" || identity.Email == "" {
+		return nil, errors.New("subject / email empty")
 	}
 
-	// Find existing user only; do NOT create here.
-	filter := bson.M{"email": email}
+	legacyLink := false
 	var existing SignInUserRequest
-	err := r.userColletion.FindOne(ctx, filter).Decode(&existing)
-	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, errors.New("User Not Found. Please sign up first")
+	err := r.userColletion.FindOne(ctx, identityFilter(identity.Provider, identity.Subject)).Decode(&existing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = r.userColletion.FindOne(ctx, bson.M{"email": identity.Email}).Decode(&existing)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil, errors.New("User Not Found. Please sign up first")
+			}
+			return nil, err
+		}
+		if identity.Provider != model.GoogleProvider || existing.GoogleUid != "" || existing.PasswordSet != nil {
+			return nil, ErrIdentityNotLinked
 		}
+		legacyLink = true
+	} else if err != nil {
 		return nil, err
 	}
+	if existing.Disabled {
+		return nil, ErrAccountDisabled
+	}
 
-	// Existing user: optionally update name and updatedAt
-	if fullName != "" && existing.FullName == "" {
-		_, _ = r.userColletion.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"fullName": fullName, "updatedAt": time.Now()}})
-		existing.FullName = fullName
-	} else {
-		_, _ = r.userColletion.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"updatedAt": time.Now()}})
+	// Existing user: link legacy accounts, optionally update name and updatedAt
+	set := bson.M{"updatedAt": time.Now()}
+	if legacyLink {
+		set["googleUid"] = identity.Subject
+	}
+	if name := identity.FullName(); name != "" && existing.FullName == "" {
+		set["fullName"] = name
+		existing.FullName = name
 	}
+	_, _ = r.userColletion.UpdateOne(ctx, bson.M{"_id": existing.ID}, bson.M{"$set": set})
 
-	return &SignUpResponse{Email: email, UserId: existing.ID.Hex(), FullName: existing.FullName}, nil
+	return &SignUpResponse{Email: existing.Email, UserId: existing.ID.Hex(), FullName: existing.FullName, EmailVerified: existing.IsVerified()}, nil
 }
 
 func ValidatePassword(password string, hashedPassword string) (bool, error) {
@@ -247,6 +403,493 @@ func (r *userRepo) UpdateUserName(ctx context.Context, userId string, newName st
 	return updated.ModifiedCount > 0, nil
 }
 
+// GetUserByEmail returns ErrNotFound when no account uses the email
+func (r *userRepo) GetUserByEmail(ctx context.Context, email string) (*SignInUserRequest, error) {
+	if email == "" {
+		return nil, errors.New("email empty")
+	}
+
+	var user SignInUserRequest
+	err := r.userColletion.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// UpdatePassword stores an already hashed password
+func (r *userRepo) UpdatePassword(ctx context.Context, userId string, hashedPassword string) error {
+	if userId == "" || hashedPassword == "" {
+		return errors.New("userId or password is empty")
+	}
+
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	// once the user picked a password it counts as set, even for Google sign ups
+	filter := bson.M{"_id": userIdOid}
+	update := bson.M{"$set": bson.M{"password": hashedPassword, "passwordSet": true, "updatedAt": time.Now()}}
+
+	updated, err := r.userColletion.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepo) GetUserById(ctx context.Context, userId string) (*SignInUserRequest, error) {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var user SignInUserRequest
+	err = r.userColletion.FindOne(ctx, bson.M{"_id": userIdOid}).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// MarkEmailVerified only matches while the account still has the email the link was sent to
+func (r *userRepo) MarkEmailVerified(ctx context.Context, userId string, email string) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": userIdOid, "email": email}
+	update := bson.M{"$set": bson.M{"verified": true, "verifiedAt": time.Now(), "updatedAt": time.Now()}}
+
+	updated, err := r.userColletion.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// IsEmailVerified is false only for accounts explicitly stored with verified: false
+func (r *userRepo) IsEmailVerified(ctx context.Context, userId string) (bool, error) {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := r.userColletion.CountDocuments(ctx, bson.M{"_id": userIdOid, "verified": false})
+	if err != nil {
+		return false, err
+	}
+
+	return count == 0, nil
+}
+
+// GetTwoFactor returns an empty (disabled) TwoFactor for users that never enrolled
+func (r *userRepo) GetTwoFactor(ctx context.Context, userId string) (*model.TwoFactor, error) {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var user struct {
+		TwoFactor *model.TwoFactor `bson:"twoFactor,omitempty"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"twoFactor": 1})
+	err = r.userColletion.FindOne(ctx, bson.M{"_id": userIdOid}, opts).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	if user.TwoFactor == nil {
+		return &model.TwoFactor{}, nil
+	}
+	return user.TwoFactor, nil
+}
+
+// SavePendingTwoFactor starts (or restarts) an enrollment, the active secret is untouched
+func (r *userRepo) SavePendingTwoFactor(ctx context.Context, userId string, secret string, recoveryCodeHashes []string) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{
+		"twoFactor.pendingSecret":        secret,
+		"twoFactor.pendingRecoveryCodes": recoveryCodeHashes,
+		"updatedAt":                      time.Now(),
+	}}
+
+	updated, err := r.userColletion.UpdateOne(ctx, bson.M{"_id": userIdOid}, update)
+	if err != nil {
+		return err
+	}
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// EnableTwoFactor promotes the pending enrollment, it only matches while secret is still the pending one
+func (r *userRepo) EnableTwoFactor(ctx context.Context, userId string, secret string, recoveryCodeHashes []string) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": userIdOid, "twoFactor.pendingSecret": secret}
+	update := bson.M{
+		"$set": bson.M{
+			"twoFactor.enabled":       true,
+			"twoFactor.secret":        secret,
+			"twoFactor.recoveryCodes": recoveryCodeHashes,
+			"twoFactor.enabledAt":     time.Now(),
+			"updatedAt":               time.Now(),
+		},
+		"$unset": bson.M{"twoFactor.pendingSecret": "", "twoFactor.pendingRecoveryCodes": ""},
+	}
+
+	updated, err := r.userColletion.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepo) DisableTwoFactor(ctx context.Context, userId string) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$unset": bson.M{"twoFactor": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+	updated, err := r.userColletion.UpdateOne(ctx, bson.M{"_id": userIdOid}, update)
+	if err != nil {
+		return err
+	}
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UseRecoveryCode removes the code in the same update that checks it, so each code works once
+func (r *userRepo) UseRecoveryCode(ctx context.Context, userId string, recoveryCodeHash string) (bool, error) {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"_id": userIdOid, "twoFactor.enabled": true, "twoFactor.recoveryCodes": recoveryCodeHash}
+	update := bson.M{"$pull": bson.M{"twoFactor.recoveryCodes": recoveryCodeHash}}
+
+	updated, err := r.userColletion.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return updated.MatchedCount > 0, nil
+}
+
+// ErrIdentityTaken is returned when the identity is already linked to another user
+var ErrIdentityTaken = errors.New("This account is already linked to another user")
+
+// LinkIdentity attaches an identity of provider to the user, replacing the one they had of it
+// one identity can only belong to one user
+func (r *userRepo) LinkIdentity(ctx context.Context, userId string, provider string, subject string) error {
+	if provider == "" || subject == "" {
+		return errors.New("provider / subject is empty")
+	}
+
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	var other SignInUserRequest
+	err = r.userColletion.FindOne(ctx, identityFilter(provider, subject)).Decode(&other)
+	if err == nil && other.ID != userIdOid {
+		return ErrIdentityTaken
+	}
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	now := time.Now()
+	var updated *mongo.UpdateResult
+	if provider == model.GoogleProvider {
+		update := bson.M{"$set": bson.M{"googleUid": subject, "updatedAt": now}}
+		updated, err = r.userColletion.UpdateOne(ctx, bson.M{"_id": userIdOid}, update)
+	} else {
+		// replace the subject if the user already has an identity of provider, push it otherwise
+		filter := bson.M{"_id": userIdOid, "identities.provider": provider}
+		update := bson.M{"$set": bson.M{"identities.$.subject": subject, "updatedAt": now}}
+		updated, err = r.userColletion.UpdateOne(ctx, filter, update)
+		if err == nil && updated.MatchedCount == 0 {
+			update = bson.M{
+				"$push": bson.M{"identities": model.LinkedIdentity{Provider: provider, Subject: subject}},
+				"$set":  bson.M{"updatedAt": now},
+			}
+			updated, err = r.userColletion.UpdateOne(ctx, bson.M{"_id": userIdOid}, update)
+		}
+	}
+	if err != nil {
+		// the unique indexes catch a concurrent link of the same identity
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrIdentityTaken
+		}
+		return err
+	}
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepo) UnlinkIdentity(ctx context.Context, userId string, provider string) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$unset": bson.M{"googleUid": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+	if provider != model.GoogleProvider {
+		update = bson.M{"$pull": bson.M{"identities": bson.M{"provider": provider}}, "$set": bson.M{"updatedAt": time.Now()}}
+	}
+	updated, err := r.userColletion.UpdateOne(ctx, bson.M{"_id": userIdOid}, update)
+	if err != nil {
+		return err
+	}
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// ScheduleDeletion marks the account for the background purge at purgeAt
+func (r *userRepo) ScheduleDeletion(ctx context.Context, userId string, purgeAt time.Time) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{"deletionScheduledAt": purgeAt, "updatedAt": time.Now()}}
+	updated, err := r.userColletion.UpdateOne(ctx, bson.M{"_id": userIdOid}, update)
+	if err != nil {
+		return err
+	}
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CancelDeletion reports whether a scheduled deletion was actually cancelled
+func (r *userRepo) CancelDeletion(ctx context.Context, userId string) (bool, error) {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"_id": userIdOid, "deletionScheduledAt": bson.M{"$exists": true}}
+	update := bson.M{"$unset": bson.M{"deletionScheduledAt": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+	updated, err := r.userColletion.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return updated.ModifiedCount > 0, nil
+}
+
+// ListUsersDueForDeletion returns the ids of accounts whose grace period is over
+func (r *userRepo) ListUsersDueForDeletion(ctx context.Context, now time.Time, limit int64) ([]string, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(limit)
+	cursor, err := r.userColletion.Find(ctx, bson.M{"deletionScheduledAt": bson.M{"$lte": now}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var userIds []string
+	for cursor.Next(ctx) {
+		var user struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&user); err != nil {
+			return nil, err
+		}
+		userIds = append(userIds, user.ID.Hex())
+	}
+
+	return userIds, cursor.Err()
+}
+
+// DeleteUser removes the user document itself, the purge deletes everything else first
+func (r *userRepo) DeleteUser(ctx context.Context, userId string) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.userColletion.DeleteOne(ctx, bson.M{"_id": userIdOid})
+	return err
+}
+
+// GetUserRole returns ErrNotFound for unknown users
+func (r *userRepo) GetUserRole(ctx context.Context, userId string) (string, error) {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return "", err
+	}
+
+	var user SignInUserRequest
+	opts := options.FindOne().SetProjection(bson.M{"role": 1})
+	if err := r.userColletion.FindOne(ctx, bson.M{"_id": userIdOid}, opts).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+
+	return user.GetRole(), nil
+}
+
+func (r *userRepo) SetRole(ctx context.Context, userId string, role string) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"$set": bson.M{"role": role, "updatedAt": time.Now()}}
+	updated, err := r.userColletion.UpdateOne(ctx, bson.M{"_id": userIdOid}, update)
+	if err != nil {
+		return err
+	}
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepo) SetDisabled(ctx context.Context, userId string, disabled bool) error {
+	userIdOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return err
+	}
+
+	var update bson.M
+	if disabled {
+		update = bson.M{"$set": bson.M{"disabled": true, "disabledAt": time.Now(), "updatedAt": time.Now()}}
+	} else {
+		update = bson.M{"$unset": bson.M{"disabled": "", "disabledAt": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+	}
+
+	updated, err := r.userColletion.UpdateOne(ctx, bson.M{"_id": userIdOid}, update)
+	if err != nil {
+		return err
+	}
+	if updated.MatchedCount == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// UserSummary is what the admin API shows of a user, never the password hash or 2FA secrets
+type UserSummary struct {
+	UserId              string     `json:"userId"`
+	Email               string     `json:"email"`
+	FullName            string     `json:"fullName,omitempty"`
+	Role                string     `json:"role"`
+	Verified            bool       `json:"verified"`
+	PasswordSet         bool       `json:"passwordSet"`
+	Providers           []string   `json:"providers"`
+	Disabled            bool       `json:"disabled"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty"`
+}
+
+// Summary strips a user down to what the admin API may show
+func (u *SignInUserRequest) Summary() UserSummary {
+	return UserSummary{
+		UserId:              u.ID.Hex(),
+		Email:               u.Email,
+		FullName:            u.FullName,
+		Role:                u.GetRole(),
+		Verified:            u.IsVerified(),
+		PasswordSet:         u.HasPassword(),
+		Providers:           u.LinkedProviders(),
+		Disabled:            u.Disabled,
+		CreatedAt:           u.CreatedAt,
+		DeletionScheduledAt: u.DeletionScheduledAt,
+	}
+}
+
+// ListUsers pages through users newest first, search matches a part of the email or the name
+// it also returns the total number of matches so the admin UI can show the page count
+func (r *userRepo) ListUsers(ctx context.Context, search string, skip int64, limit int64) ([]UserSummary, int64, error) {
+	filter := bson.M{}
+	if search = strings.TrimSpace(search); search != "" {
+		// quoted so the search text can't be used as a (slow) regex of its own
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+		filter["$or"] = bson.A{bson.M{"email": pattern}, bson.M{"fullName": pattern}}
+	}
+
+	total, err := r.userColletion.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := r.userColletion.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []UserSummary{}
+	for cursor.Next(ctx) {
+		var user SignInUserRequest
+		if err := cursor.Decode(&user); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user.Summary())
+	}
+
+	return users, total, cursor.Err()
+}
+
 func NewUserRepository(todoCol *mongo.Collection, userCol *mongo.Collection) UserRepository {
 	return &userRepo{
 		todoCollection: todoCol,
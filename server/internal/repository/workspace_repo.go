@@ -11,6 +11,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // WorkSpaceRepository interface
@@ -19,7 +20,9 @@ type WorkSpaceRepository interface {
 	CreateWorkspace(ctx context.Context, userId string, workspaceName string) (string, error)
 	UpdatedWorkspace(ctx context.Context, userId string, workspaceName string, updatedWorkspace string) error
 	DeleteWorkspace(ctx context.Context, userId string, workspaceName string) error
-	UpdateWorkspaceLayout(ctx context.Context, workspaceId string, nodes, edges []map[string]interface{}) (bool, error)
+	UpdateWorkspaceLayout(ctx context.Context, userId string, workspaceId string, nodes, edges []map[string]interface{}) (bool, error)
+	IsWorkspaceOwner(ctx context.Context, userId string, workspaceId string) (bool, error)
+	GetWorkspaceByName(ctx context.Context, userId string, workspaceName string) (model.Workspace, error)
 }
 
 // workspaceRepository struct
@@ -60,29 +63,29 @@ func (r *workspaceRepository) GetAllUserWorkspace(ctx context.Context, userId st
 
 	pipeline := mongo.Pipeline{
 		{
-			{"$match", bson.D{
-				{"userId", oid},
+			{Key: "$match", Value: bson.D{
+				{Key: "userId", Value: oid},
 			}},
 		},
 		{
-			{"$lookup", bson.D{
-				{"from", "todos"},
-				{"localField", "_id"},
-				{"foreignField", "workspaceId"},
-				{"as", "todos"},
+			{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "todos"},
+				{Key: "localField", Value: "_id"},
+				{Key: "foreignField", Value: "workspaceId"},
+				{Key: "as", Value: "todos"},
 			}},
 		},
 		{
-			{"$lookup", bson.D{
-				{"from", "goals"},
-				{"localField", "_id"},
-				{"foreignField", "workspaceId"},
-				{"as", "goals"},
+			{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "goals"},
+				{Key: "localField", Value: "_id"},
+				{Key: "foreignField", Value: "workspaceId"},
+				{Key: "as", Value: "goals"},
 			}},
 		},
 		{
-			{"$sort", bson.D{
-				{"createdAt", 1},
+			{Key: "$sort", Value: bson.D{
+				{Key: "createdAt", Value: 1},
 			}},
 		},
 	}
@@ -187,7 +190,7 @@ func (r *workspaceRepository) UpdatedWorkspace(ctx context.Context, userId strin
 	// check if any document was modified or not
 	// if no document matched the filter, it means workspace doesn't exist
 	if res.MatchedCount == 0 {
-		return errors.New("No workspace found for given userId and workspaceName")
+		return ErrNotFound
 	}
 
 	// debug
@@ -219,21 +222,26 @@ func (r *workspaceRepository) DeleteWorkspace(ctx context.Context, userId string
 
 	// check if any document was deleted
 	if res.DeletedCount == 0 {
-		return errors.New("No workspace found to delete for given userId and workspaceName")
+		return ErrNotFound
 	}
 
 	return nil
 }
 
 // UpdateWorkspaceLayout updates the initialNodes and initialEdges for a workspace
-func (r *workspaceRepository) UpdateWorkspaceLayout(ctx context.Context, workspaceId string, nodes, edges []map[string]interface{}) (bool, error) {
+// only workspaces owned by userId are matched
+func (r *workspaceRepository) UpdateWorkspaceLayout(ctx context.Context, userId string, workspaceId string, nodes, edges []map[string]interface{}) (bool, error) {
 	oid, err := primitive.ObjectIDFromHex(workspaceId)
 	if err != nil {
 		return false, err
 	}
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false, err
+	}
 
 	// Create filter and update
-	filter := bson.M{"_id": oid}
+	filter := bson.M{"_id": oid, "userId": userOid}
 	update := bson.M{
 		"$set": bson.M{
 			"initialNodes": nodes,
@@ -248,10 +256,59 @@ func (r *workspaceRepository) UpdateWorkspaceLayout(ctx context.Context, workspa
 		return false, err
 	}
 
+	if result.MatchedCount == 0 {
+		return false, ErrNotFound
+	}
+
 	// Return true if document was modified
 	return result.ModifiedCount > 0, nil
 }
 
+// IsWorkspaceOwner reports whether workspaceId exists and belongs to userId
+// todo and goal services call this before touching anything inside a workspace
+func (r *workspaceRepository) IsWorkspaceOwner(ctx context.Context, userId string, workspaceId string) (bool, error) {
+	if userId == "" || workspaceId == "" {
+		return false, errors.New("UserId / WorkspaceId is Empty in Repo")
+	}
+
+	oid, err := primitive.ObjectIDFromHex(workspaceId)
+	if err != nil {
+		return false, err
+	}
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := r.workspaceCollection.CountDocuments(ctx, bson.M{"_id": oid, "userId": userOid})
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// GetWorkspaceByName returns the workspace of userId with that name (names are unique per user)
+// the layout and embedded todos / goals are left out, callers only need the workspace itself
+func (r *workspaceRepository) GetWorkspaceByName(ctx context.Context, userId string, workspaceName string) (model.Workspace, error) {
+	userOid, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return model.Workspace{}, err
+	}
+
+	var workspace model.Workspace
+	opts := options.FindOne().SetProjection(bson.M{"initialNodes": 0, "initialEdges": 0, "todos": 0, "goals": 0})
+	err = r.workspaceCollection.FindOne(ctx, bson.M{"userId": userOid, "workspaceName": workspaceName}, opts).Decode(&workspace)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Workspace{}, ErrNotFound
+		}
+		return model.Workspace{}, err
+	}
+
+	return workspace, nil
+}
+
 func NewWorkspaceRepository(workspaceCollection *mongo.Collection) WorkSpaceRepository {
 	return &workspaceRepository{
 		workspaceCollection: workspaceCollection,
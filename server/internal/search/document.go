@@ -0,0 +1,87 @@
+package search
+
+import (
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+)
+
+// Field is a searchable field of a document, Weight mirrors the weights of the text indexes
+type Field struct {
+	Name   string
+	Text   string
+	Weight float64
+}
+
+// Document is what gets searched, the first field is the title of the result
+type Document struct {
+	Kind        string
+	ID          string
+	UserId      string
+	WorkspaceId string
+	Status      string
+	Priority    string
+	Fields      []Field
+	CreatedAt   time.Time
+}
+
+func TodoDocument(todo model.Todo) Document {
+	return Document{
+		Kind:        KindTodo,
+		ID:          todo.ID.Hex(),
+		UserId:      todo.UserId.Hex(),
+		WorkspaceId: todo.WorkspaceId.Hex(),
+		Status:      todo.Status,
+		Priority:    todo.Priority,
+		Fields:      []Field{{Name: "task", Text: todo.Task, Weight: 1}},
+		CreatedAt:   todo.CreatedAt,
+	}
+}
+
+// GoalDocument takes the creation time from the id, goals don't store one
+func GoalDocument(goal model.Goals) Document {
+	return Document{
+		Kind:        KindGoal,
+		ID:          goal.ID.Hex(),
+		UserId:      goal.UserId.Hex(),
+		WorkspaceId: goal.WorkspaceId.Hex(),
+		Fields: []Field{
+			{Name: "title", Text: goal.Title, Weight: 2},
+			{Name: "category", Text: goal.Category, Weight: 1},
+		},
+		CreatedAt: goal.ID.Timestamp(),
+	}
+}
+
+func WorkspaceDocument(workspace model.Workspace) Document {
+	return Document{
+		Kind:        KindWorkspace,
+		ID:          workspace.ID.Hex(),
+		UserId:      workspace.UserId.Hex(),
+		WorkspaceId: workspace.ID.Hex(),
+		Fields:      []Field{{Name: "workspaceName", Text: workspace.WorkspaceName, Weight: 1}},
+		CreatedAt:   workspace.CreatedAt,
+	}
+}
+
+// result turns a matched document into a Result with its highlights
+func (d Document) result(score float64, terms []string) Result {
+	r := Result{
+		Kind:        d.Kind,
+		ID:          d.ID,
+		WorkspaceId: d.WorkspaceId,
+		Status:      d.Status,
+		Priority:    d.Priority,
+		Score:       score,
+		CreatedAt:   d.CreatedAt,
+	}
+	if len(d.Fields) > 0 {
+		r.Title = d.Fields[0].Text
+	}
+	for _, field := range d.Fields {
+		if h, ok := highlight(field.Name, field.Text, terms); ok {
+			r.Highlights = append(r.Highlights, h)
+		}
+	}
+	return r
+}
@@ -0,0 +1,103 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// MemoryIndex is a SearchIndex over documents kept in memory, for tests and tools that run
+// without Mongo, matching is close to the text index but not the same (no stemming / stop words)
+type MemoryIndex struct {
+	mu   sync.RWMutex
+	docs map[string]Document
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{docs: map[string]Document{}}
+}
+
+func memoryKey(kind string, id string) string {
+	return kind + ":" + id
+}
+
+// Put adds doc or replaces the document of the same kind and id
+func (i *MemoryIndex) Put(doc Document) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.docs[memoryKey(doc.Kind, doc.ID)] = doc
+}
+
+func (i *MemoryIndex) Delete(kind string, id string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.docs, memoryKey(kind, id))
+}
+
+// filtered reports whether the filters of q (everything but the text) let doc through
+func (q Query) filtered(doc Document) bool {
+	if doc.UserId != q.UserId {
+		return false
+	}
+	if q.WorkspaceId != "" && doc.WorkspaceId != q.WorkspaceId {
+		return false
+	}
+	if q.Status != "" && doc.Status != q.Status {
+		return false
+	}
+	if q.Priority != "" && doc.Priority != q.Priority {
+		return false
+	}
+	if q.From != nil && doc.CreatedAt.Before(*q.From) {
+		return false
+	}
+	if q.To != nil && !doc.CreatedAt.Before(*q.To) {
+		return false
+	}
+	return true
+}
+
+// Search scores a document by the weight of every field word matching a term, a document
+// holding an excluded word doesn't match
+func (i *MemoryIndex) Search(ctx context.Context, q Query) ([]Result, error) {
+	terms := Terms(q.Text)
+	exclude := excluded(q.Text)
+	kinds := map[string]bool{}
+	for _, kind := range q.kinds() {
+		kinds[kind] = true
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var results []Result
+	for _, doc := range i.docs {
+		if !kinds[doc.Kind] || !q.filtered(doc) {
+			continue
+		}
+
+		score := 0.0
+		skip := false
+		for _, field := range doc.Fields {
+			for _, word := range strings.FieldsFunc(strings.ToLower(field.Text), isSeparator) {
+				for _, term := range exclude {
+					if word == term {
+						skip = true
+					}
+				}
+				for _, term := range terms {
+					if matchesTerm(word, term) {
+						score += field.Weight
+						break
+					}
+				}
+			}
+		}
+		if skip || score == 0 {
+			continue
+		}
+		results = append(results, doc.result(score, terms))
+	}
+
+	return rank(results, q.Limit), nil
+}
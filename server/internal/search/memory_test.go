@@ -0,0 +1,269 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var searchDay = time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+
+func todoDoc(id string, userId string, workspaceId string, task string, status string, priority string, createdAt time.Time) Document {
+	return Document{
+		Kind:        KindTodo,
+		ID:          id,
+		UserId:      userId,
+		WorkspaceId: workspaceId,
+		Status:      status,
+		Priority:    priority,
+		Fields:      []Field{{Name: "task", Text: task, Weight: 1}},
+		CreatedAt:   createdAt,
+	}
+}
+
+// newTestIndex holds the documents of two users, "bob" has the same words as "alice"
+// so a leak of his documents shows in every search
+func newTestIndex() *MemoryIndex {
+	index := NewMemoryIndex()
+	index.Put(todoDoc("t1", "alice", "w1", "Send invoice to client", "todo", "high", searchDay))
+	index.Put(todoDoc("t2", "alice", "w1", "Invoice reminder invoice", "done", "low", searchDay.Add(time.Hour)))
+	index.Put(todoDoc("t3", "alice", "w2", "Write report", "in-progress", "high", searchDay.Add(2*time.Hour)))
+	index.Put(todoDoc("t4", "alice", "w2", "Report invoicing numbers", "todo", "medium", searchDay.Add(3*time.Hour)))
+	index.Put(Document{
+		Kind:        KindGoal,
+		ID:          "g1",
+		UserId:      "alice",
+		WorkspaceId: "w1",
+		Fields:      []Field{{Name: "title", Text: "Invoice every client", Weight: 2}, {Name: "category", Text: "finance", Weight: 1}},
+		CreatedAt:   searchDay.Add(-time.Hour),
+	})
+	index.Put(Document{
+		Kind:        KindWorkspace,
+		ID:          "w1",
+		UserId:      "alice",
+		WorkspaceId: "w1",
+		Fields:      []Field{{Name: "workspaceName", Text: "Invoices", Weight: 1}},
+		CreatedAt:   searchDay.Add(-2 * time.Hour),
+	})
+
+	index.Put(todoDoc("b1", "bob", "w9", "Invoice invoice invoice report", "todo", "high", searchDay))
+	index.Put(Document{
+		Kind:        KindGoal,
+		ID:          "b2",
+		UserId:      "bob",
+		WorkspaceId: "w9",
+		Fields:      []Field{{Name: "title", Text: "Invoice report", Weight: 2}},
+		CreatedAt:   searchDay,
+	})
+	return index
+}
+
+func resultIds(results []Result) []string {
+	ids := []string{}
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	return ids
+}
+
+func TestMemoryIndexSearch(t *testing.T) {
+	from := searchDay.Add(30 * time.Minute)
+	to := searchDay.Add(2 * time.Hour)
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{
+			// g1 weighs 2 for its title, t2 says invoice twice, t1 / t4 / w1 once, newer first on a tie
+			name:  "ranked by score then newest",
+			query: Query{UserId: "alice", Text: "invoice"},
+			want:  []string{"t2", "g1", "t4", "t1", "w1"},
+		},
+		{
+			name:  "limit keeps the best",
+			query: Query{UserId: "alice", Text: "invoice", Limit: 2},
+			want:  []string{"t2", "g1"},
+		},
+		{
+			name:  "two words add up",
+			query: Query{UserId: "alice", Text: "invoice report"},
+			want:  []string{"t4", "t2", "g1", "t3", "t1", "w1"},
+		},
+		{
+			name:  "excluded word",
+			query: Query{UserId: "alice", Text: "invoice -reminder"},
+			want:  []string{"g1", "t4", "t1", "w1"},
+		},
+		{
+			name:  "one kind",
+			query: Query{UserId: "alice", Text: "invoice", Kinds: []string{KindGoal}},
+			want:  []string{"g1"},
+		},
+		{
+			name:  "workspace",
+			query: Query{UserId: "alice", Text: "invoice report", WorkspaceId: "w2"},
+			want:  []string{"t4", "t3"},
+		},
+		{
+			name:  "status leaves goals and workspaces out",
+			query: Query{UserId: "alice", Text: "invoice", Status: "todo"},
+			want:  []string{"t4", "t1"},
+		},
+		{
+			name:  "priority",
+			query: Query{UserId: "alice", Text: "invoice report", Priority: "high"},
+			want:  []string{"t3", "t1"},
+		},
+		{
+			name:  "status with a kind that has none",
+			query: Query{UserId: "alice", Text: "invoice", Status: "todo", Kinds: []string{KindGoal}},
+			want:  []string{},
+		},
+		{
+			name:  "created from / to, to is exclusive",
+			query: Query{UserId: "alice", Text: "invoice report", From: &from, To: &to},
+			want:  []string{"t2"},
+		},
+		{
+			name:  "no match",
+			query: Query{UserId: "alice", Text: "holiday"},
+			want:  []string{},
+		},
+		{
+			name:  "a user without documents",
+			query: Query{UserId: "carol", Text: "invoice"},
+			want:  []string{},
+		},
+	}
+
+	index := newTestIndex()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := index.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if got := resultIds(results); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Search() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryIndexOwnerScoping(t *testing.T) {
+	index := newTestIndex()
+	queries := []Query{
+		{UserId: "alice", Text: "invoice report"},
+		{UserId: "alice", Text: "invoice", WorkspaceId: "w9"},
+		{UserId: "alice", Text: "invoice", Status: "todo", Priority: "high"},
+		{UserId: "", Text: "invoice"},
+	}
+	for _, q := range queries {
+		results, err := index.Search(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Search(%+v) error = %v", q, err)
+		}
+		for _, result := range results {
+			if result.ID == "b1" || result.ID == "b2" {
+				t.Fatalf("Search(%+v) returned %s of another user", q, result.ID)
+			}
+		}
+	}
+
+	results, _ := index.Search(context.Background(), Query{UserId: "bob", Text: "invoice"})
+	if got, want := resultIds(results), []string{"b1", "b2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Search(bob) = %v, want %v", got, want)
+	}
+
+	// a deleted document is gone from the results
+	index.Delete(KindTodo, "b1")
+	results, _ = index.Search(context.Background(), Query{UserId: "bob", Text: "invoice"})
+	if got, want := resultIds(results), []string{"b2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Search(bob) after delete = %v, want %v", got, want)
+	}
+}
+
+func TestMemoryIndexHighlights(t *testing.T) {
+	index := newTestIndex()
+	results, err := index.Search(context.Background(), Query{UserId: "alice", Text: "invoice", Kinds: []string{KindGoal, KindTodo}, Limit: 3})
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	want := map[string][]Highlight{
+		"t2": {{Field: "task", Parts: []Part{{Text: "Invoice", Match: true}, {Text: " reminder "}, {Text: "invoice", Match: true}}}},
+		// only the title matched, the category is left out
+		"g1": {{Field: "title", Parts: []Part{{Text: "Invoice", Match: true}, {Text: " every client"}}}},
+		// "invoicing" shares the stem of "invoice"
+		"t4": {{Field: "task", Parts: []Part{{Text: "Report "}, {Text: "invoicing", Match: true}, {Text: " numbers"}}}},
+	}
+	for _, result := range results {
+		if !reflect.DeepEqual(result.Highlights, want[result.ID]) {
+			t.Fatalf("highlights of %s = %+v, want %+v", result.ID, result.Highlights, want[result.ID])
+		}
+	}
+	if len(results) != len(want) {
+		t.Fatalf("Search() = %v", resultIds(results))
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		terms   []string
+		want    []Part
+		matched bool
+	}{
+		{
+			name:    "phrase words and punctuation",
+			text:    "Call mom, then call dad!",
+			terms:   Terms(`"call dad"`),
+			want:    []Part{{Text: "Call", Match: true}, {Text: " mom, then "}, {Text: "call", Match: true}, {Text: " "}, {Text: "dad", Match: true}, {Text: "!"}},
+			matched: true,
+		},
+		{
+			name:    "prefix of a longer word",
+			text:    "running late",
+			terms:   []string{"run"},
+			want:    []Part{{Text: "running", Match: true}, {Text: " late"}},
+			matched: true,
+		},
+		{
+			// "gold" starts with "go" but two letters are too short for a stem
+			name:    "short words match whole only",
+			text:    "go gold",
+			terms:   []string{"go"},
+			want:    []Part{{Text: "go", Match: true}, {Text: " gold"}},
+			matched: true,
+		},
+		{
+			name:    "excluded words are not highlighted",
+			text:    "buy milk",
+			terms:   Terms("buy -milk"),
+			want:    []Part{{Text: "buy", Match: true}, {Text: " milk"}},
+			matched: true,
+		},
+		{
+			name:  "nothing matched",
+			text:  "buy milk",
+			terms: []string{"bread"},
+			want:  []Part{{Text: "buy milk"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, matched := highlight("task", tt.text, tt.terms)
+			if matched != tt.matched {
+				t.Fatalf("highlight() matched = %v, want %v", matched, tt.matched)
+			}
+			if !reflect.DeepEqual(h.Parts, tt.want) {
+				t.Fatalf("highlight() = %+v, want %+v", h.Parts, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package search
+
+import (
+	"context"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoIndex searches with the text indexes of the todos (task), goals (title, category)
+// and workspaces (workspaceName) collections, see app.go for the indexes
+type mongoIndex struct {
+	todos      *mongo.Collection
+	goals      *mongo.Collection
+	workspaces *mongo.Collection
+}
+
+// filter is the part of the query every collection shares: text, owner and creation time
+// (from the _id, goals have no createdAt), workspaceField is where the workspace id lives
+func (i *mongoIndex) filter(q Query, userOid primitive.ObjectID, workspaceField string) (bson.M, error) {
+	filter := bson.M{"$text": bson.M{"$search": q.Text}, "userId": userOid}
+
+	ids := bson.M{}
+	if q.From != nil {
+		ids["$gte"] = primitive.NewObjectIDFromTimestamp(*q.From)
+	}
+	if q.To != nil {
+		ids["$lt"] = primitive.NewObjectIDFromTimestamp(*q.To)
+	}
+
+	if q.WorkspaceId != "" {
+		workspaceOid, err := primitive.ObjectIDFromHex(q.WorkspaceId)
+		if err != nil {
+			return nil, err
+		}
+		if workspaceField == "_id" {
+			ids["$eq"] = workspaceOid
+		} else {
+			filter[workspaceField] = workspaceOid
+		}
+	}
+	if len(ids) > 0 {
+		filter["_id"] = ids
+	}
+	return filter, nil
+}
+
+// find runs filter on collection best match first, decoding into T next to the text score
+func find[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, limit int) ([]T, []float64, error) {
+	score := bson.M{"score": bson.M{"$meta": "textScore"}}
+	opts := options.Find().SetProjection(score).SetSort(score)
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []T
+	var scores []float64
+	for cursor.Next(ctx) {
+		var doc T
+		var meta struct {
+			Score float64 `bson:"score"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, nil, err
+		}
+		if err := cursor.Decode(&meta); err != nil {
+			return nil, nil, err
+		}
+		docs = append(docs, doc)
+		scores = append(scores, meta.Score)
+	}
+	return docs, scores, cursor.Err()
+}
+
+func (i *mongoIndex) Search(ctx context.Context, q Query) ([]Result, error) {
+	userOid, err := primitive.ObjectIDFromHex(q.UserId)
+	if err != nil {
+		return nil, err
+	}
+	terms := Terms(q.Text)
+
+	var results []Result
+	for _, kind := range q.kinds() {
+		switch kind {
+		case KindTodo:
+			filter, err := i.filter(q, userOid, "workspaceId")
+			if err != nil {
+				return nil, err
+			}
+			if q.Status != "" {
+				filter["status"] = q.Status
+			}
+			if q.Priority != "" {
+				filter["priority"] = q.Priority
+			}
+			todos, scores, err := find[model.Todo](ctx, i.todos, filter, q.Limit)
+			if err != nil {
+				return nil, err
+			}
+			for n, todo := range todos {
+				results = append(results, TodoDocument(todo).result(scores[n], terms))
+			}
+
+		case KindGoal:
+			filter, err := i.filter(q, userOid, "workspaceId")
+			if err != nil {
+				return nil, err
+			}
+			goals, scores, err := find[model.Goals](ctx, i.goals, filter, q.Limit)
+			if err != nil {
+				return nil, err
+			}
+			for n, goal := range goals {
+				results = append(results, GoalDocument(goal).result(scores[n], terms))
+			}
+
+		case KindWorkspace:
+			filter, err := i.filter(q, userOid, "_id")
+			if err != nil {
+				return nil, err
+			}
+			workspaces, scores, err := find[model.Workspace](ctx, i.workspaces, filter, q.Limit)
+			if err != nil {
+				return nil, err
+			}
+			for n, workspace := range workspaces {
+				results = append(results, WorkspaceDocument(workspace).result(scores[n], terms))
+			}
+		}
+	}
+
+	return rank(results, q.Limit), nil
+}
+
+// NewMongoIndex takes the todos, goals and workspaces collections
+func NewMongoIndex(todos *mongo.Collection, goals *mongo.Collection, workspaces *mongo.Collection) SearchIndex {
+	return &mongoIndex{todos: todos, goals: goals, workspaces: workspaces}
+}
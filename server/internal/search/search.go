@@ -0,0 +1,193 @@
+// Package search finds the todos, goals and workspaces of a user by text
+// SearchIndex hides the backend: Mongo text indexes in production, MemoryIndex for tests
+package search
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// kinds of documents a search can return
+const (
+	KindTodo      = "todo"
+	KindGoal      = "goal"
+	KindWorkspace = "workspace"
+)
+
+// Kinds are every kind, in the order results of equal score are listed
+var Kinds = []string{KindTodo, KindGoal, KindWorkspace}
+
+// Query is one search of a user, every filter is optional except UserId / Text
+type Query struct {
+	UserId string
+	// Text uses the Mongo $text syntax: words, "exact phrases" and -excluded words
+	Text string
+	// Kinds to search, empty for all of them
+	Kinds       []string
+	WorkspaceId string
+	// Status / Priority only exist on todos, setting one leaves goals and workspaces out
+	Status   string
+	Priority string
+	// From / To (exclusive) bound when the document was created
+	From *time.Time
+	To   *time.Time
+	// Limit of results over every kind
+	Limit int
+}
+
+// kinds returns the kinds q can match
+func (q Query) kinds() []string {
+	kinds := q.Kinds
+	if len(kinds) == 0 {
+		kinds = Kinds
+	}
+	if q.Status == "" && q.Priority == "" {
+		return kinds
+	}
+	for _, kind := range kinds {
+		if kind == KindTodo {
+			return []string{KindTodo}
+		}
+	}
+	return nil
+}
+
+// Part is a piece of a highlighted field, Match marks the pieces matching the query
+// clients render them as they like, no markup ends up in the text
+type Part struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+// Highlight is a field of a result that matched, split into parts
+type Highlight struct {
+	Field string `json:"field"`
+	Parts []Part `json:"parts"`
+}
+
+type Result struct {
+	Kind        string      `json:"kind"`
+	ID          string      `json:"id"`
+	WorkspaceId string      `json:"workspaceId,omitempty"`
+	Title       string      `json:"title"`
+	Status      string      `json:"status,omitempty"`
+	Priority    string      `json:"priority,omitempty"`
+	Score       float64     `json:"score"`
+	Highlights  []Highlight `json:"highlights,omitempty"`
+	CreatedAt   time.Time   `json:"createdAt"`
+}
+
+// SearchIndex runs a query, results are ranked best first and never hold documents of another user
+type SearchIndex interface {
+	Search(ctx context.Context, q Query) ([]Result, error)
+}
+
+// Terms are the words of a query that should be highlighted, lower case,
+// excluded words (-word) are left out and phrases count word by word
+func Terms(text string) []string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		for _, term := range strings.FieldsFunc(strings.ToLower(word), isSeparator) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// excluded are the -words of a query, lower case
+func excluded(text string) []string {
+	var words []string
+	for _, word := range strings.Fields(text) {
+		if strings.HasPrefix(word, "-") && len(word) > 1 {
+			words = append(words, strings.ToLower(strings.Trim(word[1:], `"`)))
+		}
+	}
+	return words
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// matchesTerm is a loose stand in for the stemming of the text index: a whole word as
+// prefix ("run" / "running") or a shared stem ("invoice" / "invoicing"), short words match whole
+func matchesTerm(word string, term string) bool {
+	if word == term {
+		return true
+	}
+	shorter := min(len(word), len(term))
+	if shorter < 3 {
+		return false
+	}
+
+	common := 0
+	for common < shorter && word[common] == term[common] {
+		common++
+	}
+	return common == shorter || (common >= 4 && common >= shorter-2)
+}
+
+// highlight splits text into matching and other parts, ok is false when nothing matched
+func highlight(field string, text string, terms []string) (Highlight, bool) {
+	h := Highlight{Field: field}
+	matched := false
+
+	start := 0
+	inWord := false
+	flush := func(end int, word bool) {
+		if end <= start {
+			return
+		}
+		part := Part{Text: text[start:end]}
+		if word {
+			for _, term := range terms {
+				if matchesTerm(strings.ToLower(part.Text), term) {
+					part.Match = true
+					matched = true
+					break
+				}
+			}
+		}
+		// neighbouring parts of the same kind are joined
+		if n := len(h.Parts); n > 0 && h.Parts[n-1].Match == part.Match {
+			h.Parts[n-1].Text += part.Text
+		} else {
+			h.Parts = append(h.Parts, part)
+		}
+		start = end
+	}
+
+	for i, r := range text {
+		word := !isSeparator(r)
+		if word != inWord {
+			flush(i, inWord)
+			inWord = word
+		}
+	}
+	flush(len(text), inWord)
+
+	return h, matched
+}
+
+// rank sorts results best first, newer first on a tie, and cuts them to limit
+func rank(results []Result, limit int) []Result {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	if results == nil {
+		results = []Result{}
+	}
+	return results
+}
@@ -5,21 +5,40 @@ import (
 
 	"github.com/ndk123-web/fast-todo/internal/handler"
 	"github.com/ndk123-web/fast-todo/internal/middleware"
+	"github.com/ndk123-web/fast-todo/internal/model"
 )
 
 type Server struct {
-	todoHandler      handler.TodoHandler
-	userHandler      handler.UserHandler
-	goalHandler      handler.GoalHandler
-	workspaceHandler handler.WorkspaceHandler
+	todoHandler        handler.TodoHandler
+	userHandler        handler.UserHandler
+	goalHandler        handler.GoalHandler
+	workspaceHandler   handler.WorkspaceHandler
+	sessionHandler     handler.SessionHandler
+	twoFactorHandler   handler.TwoFactorHandler
+	accessTokenHandler handler.AccessTokenHandler
+	accountHandler     handler.AccountHandler
+	adminHandler       handler.AdminHandler
+	auditHandler       handler.AuditHandler
+	labelHandler       handler.LabelHandler
+	searchHandler      handler.SearchHandler
+	batchHandler       handler.BatchHandler
 }
 
-func NewServer(todoHandler handler.TodoHandler, userHandler handler.UserHandler, goalHandler handler.GoalHandler, workspaceHandler handler.WorkspaceHandler) *Server {
+func NewServer(todoHandler handler.TodoHandler, userHandler handler.UserHandler, goalHandler handler.GoalHandler, workspaceHandler handler.WorkspaceHandler, sessionHandler handler.SessionHandler, twoFactorHandler handler.TwoFactorHandler, accessTokenHandler handler.AccessTokenHandler, accountHandler handler.AccountHandler, adminHandler handler.AdminHandler, auditHandler handler.AuditHandler, labelHandler handler.LabelHandler, searchHandler handler.SearchHandler, batchHandler handler.BatchHandler) *Server {
 	return &Server{
-		todoHandler:      todoHandler,
-		userHandler:      userHandler,
-		goalHandler:      goalHandler,
-		workspaceHandler: workspaceHandler,
+		todoHandler:        todoHandler,
+		userHandler:        userHandler,
+		goalHandler:        goalHandler,
+		workspaceHandler:   workspaceHandler,
+		sessionHandler:     sessionHandler,
+		twoFactorHandler:   twoFactorHandler,
+		accessTokenHandler: accessTokenHandler,
+		accountHandler:     accountHandler,
+		adminHandler:       adminHandler,
+		auditHandler:       auditHandler,
+		labelHandler:       labelHandler,
+		searchHandler:      searchHandler,
+		batchHandler:       batchHandler,
 	}
 }
 
@@ -29,43 +48,118 @@ func (s *Server) Start(port string) error {
 	// in short its custom router
 	mux := http.NewServeMux()
 
-	// For Admin Purpose
-	mux.Handle("GET /api/v1/todos/all-user-todos", middleware.AuthMiddleware(http.HandlerFunc((s.todoHandler.GetTodos))))
+	// Admin Routes (signed in session + admin role, personal access tokens can't reach them)
+	admin := func(h http.HandlerFunc) http.Handler {
+		return middleware.AuthMiddleware(middleware.RequireSession(middleware.RequireRole(model.RoleAdmin, h)))
+	}
+	mux.Handle("GET /api/v1/admin/users", admin(s.adminHandler.ListUsers))
+	mux.Handle("GET /api/v1/admin/users/{userId}", admin(s.adminHandler.GetUser))
+	mux.Handle("POST /api/v1/admin/users/{userId}/disable", admin(s.adminHandler.DisableUser))
+	mux.Handle("POST /api/v1/admin/users/{userId}/enable", admin(s.adminHandler.EnableUser))
+	mux.Handle("PUT /api/v1/admin/users/{userId}/role", admin(s.adminHandler.SetRole))
+	mux.Handle("POST /api/v1/admin/users/{userId}/force-password-reset", admin(s.adminHandler.ForcePasswordReset))
+	mux.Handle("POST /api/v1/admin/users/{userId}/unlock", admin(s.adminHandler.UnlockUser))
+	mux.Handle("GET /api/v1/admin/todos", admin(s.adminHandler.ListTodos))
+	mux.Handle("GET /api/v1/admin/audit", admin(s.auditHandler.QueryEvents))
 
 	// we need to add here JWT Middleware
-	mux.Handle("POST /api/v1/users/{userId}/create-todo/{workspaceId}", middleware.AuthMiddleware(http.HandlerFunc(s.todoHandler.CreateTodo))) // using workspaceId and UserId can add the todo
-	mux.Handle("PUT /api/v1/todos/update-todo", middleware.AuthMiddleware((http.HandlerFunc(s.todoHandler.UpdateTodo))))                       // using ID of todo we can directly can update the todo
-	mux.Handle("DELETE /api/v1/todos/delete-todo/{todoId}", middleware.AuthMiddleware(http.HandlerFunc(s.todoHandler.DeleteTodo)))             // using ID of todo we can directly can delte the todo
-	mux.Handle("GET /api/v1/users/{userId}/get-ws-todo/{workspaceID}", middleware.AuthMiddleware(http.HandlerFunc(s.todoHandler.GetSpecificTodo)))
-	mux.Handle("POST /api/v1/users/toggle-todo", middleware.AuthMiddleware(http.HandlerFunc(s.todoHandler.ToogleTodo)))
-	mux.Handle("POST /api/v1/analytics/{userId}/year/{year}", middleware.AuthMiddleware(http.HandlerFunc(s.todoHandler.AnalyticsOfTodos)))
+	// personal access tokens are accepted too, RequireScope says which scope each route needs
+	mux.Handle("POST /api/v1/users/{userId}/create-todo/{workspaceId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, middleware.RequireVerifiedEmail(http.HandlerFunc(s.todoHandler.CreateTodo))))) // using workspaceId and UserId can add the todo
+	mux.Handle("PUT /api/v1/todos/update-todo", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.UpdateTodo))))                                                          // using ID of todo we can directly can update the todo
+	mux.Handle("DELETE /api/v1/todos/delete-todo/{todoId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.DeleteTodo))))                                              // using ID of todo we can directly can delte the todo
+	mux.Handle("GET /api/v1/users/{userId}/get-ws-todo/{workspaceID}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosRead, http.HandlerFunc(s.todoHandler.GetSpecificTodo))))
+	mux.Handle("POST /api/v1/users/toggle-todo", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.ToogleTodo))))
+	mux.Handle("POST /api/v1/todos/{todoId}/status", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.TransitionTodo))))
+	mux.Handle("POST /api/v1/workspaces/{workspaceId}/todos/move", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.MoveTodos))))
+	mux.Handle("POST /api/v1/workspaces/{workspaceId}/todos/copy", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, middleware.RequireVerifiedEmail(http.HandlerFunc(s.todoHandler.CopyTodos)))))
+	mux.Handle("POST /api/v1/todos/{todoId}/move", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.MoveTodo))))
+	mux.Handle("POST /api/v1/todos/{todoId}/skip", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.SkipOccurrence))))
+	mux.Handle("POST /api/v1/todos/{todoId}/exceptions", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.AddException))))
+	mux.Handle("POST /api/v1/todos/{todoId}/checklist", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.AddChecklistItem))))
+	mux.Handle("PUT /api/v1/todos/{todoId}/checklist/order", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.ReorderChecklist))))
+	mux.Handle("PUT /api/v1/todos/{todoId}/checklist/{itemId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.UpdateChecklistItem))))
+	mux.Handle("DELETE /api/v1/todos/{todoId}/checklist/{itemId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosWrite, http.HandlerFunc(s.todoHandler.DeleteChecklistItem))))
+	mux.Handle("GET /api/v1/todos/{todoId}/subtasks", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosRead, http.HandlerFunc(s.todoHandler.ListSubtasks))))
+	mux.Handle("POST /api/v1/analytics/{userId}/year/{year}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeTodosRead, http.HandlerFunc(s.todoHandler.AnalyticsOfTodos))))
+
+	// public signing keys for services that verify our tokens
+	mux.HandleFunc("GET /.well-known/jwks.json", handler.JWKS)
 
 	// No Need Of Middleware (Signin and Signup)
 	mux.HandleFunc("POST /api/v1/users/signup", s.userHandler.SignUpUser)
 	mux.HandleFunc("POST /api/v1/users/signin", s.userHandler.SignInUser)
-	mux.Handle("PUT /api/v1/users/update-name/{userId}", middleware.AuthMiddleware(http.HandlerFunc(s.userHandler.UpdateUserName)))
-
-	// for the refresh token routes (Currently No Need)
+	mux.HandleFunc("GET /api/v1/auth/providers", s.userHandler.ListProviders)
+	mux.HandleFunc("POST /api/v1/users/forgot-password", s.userHandler.ForgotPassword)
+	mux.HandleFunc("POST /api/v1/users/reset-password", s.userHandler.ResetPassword)
+	mux.HandleFunc("POST /api/v1/users/verify-email", s.userHandler.VerifyEmail)
+	mux.Handle("POST /api/v1/users/resend-verification", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.userHandler.ResendVerificationEmail))))
+	mux.Handle("PUT /api/v1/users/update-name/{userId}", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.userHandler.UpdateUserName))))
+
+	// password and linked identities (google, oidc...) of the signed in account
+	mux.Handle("POST /api/v1/users/change-password", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.userHandler.ChangePassword))))
+	mux.Handle("POST /api/v1/users/set-password", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.userHandler.SetPassword))))
+	mux.Handle("POST /api/v1/users/link-google", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.userHandler.LinkGoogle))))
+	mux.Handle("DELETE /api/v1/users/link-google", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.userHandler.UnlinkGoogle))))
+	mux.Handle("POST /api/v1/users/identities/{provider}", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.userHandler.LinkIdentity))))
+	mux.Handle("DELETE /api/v1/users/identities/{provider}", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.userHandler.UnlinkIdentity))))
+
+	// for the refresh token routes (rotates the refresh token on every call)
 	mux.HandleFunc("POST /api/v1/user/refresh-token", s.userHandler.RefreshToken)
+	mux.Handle("POST /api/v1/users/logout", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.userHandler.Logout))))
+	mux.Handle("POST /api/v1/users/logout-all", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.userHandler.LogoutAll))))
+
+	// Session Routes (signed in devices of the caller)
+	mux.Handle("GET /api/v1/users/sessions", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.sessionHandler.ListSessions))))
+	mux.Handle("DELETE /api/v1/users/sessions/{sessionId}", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.sessionHandler.RevokeSession))))
+
+	// Two-Factor Routes (verify is the second sign in step, so it has no auth middleware)
+	mux.Handle("POST /api/v1/users/2fa/enroll", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.twoFactorHandler.Enroll))))
+	mux.Handle("POST /api/v1/users/2fa/confirm", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.twoFactorHandler.Confirm))))
+	mux.Handle("POST /api/v1/users/2fa/disable", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.twoFactorHandler.Disable))))
+	mux.HandleFunc("POST /api/v1/users/2fa/verify", s.twoFactorHandler.Verify)
+
+	// Personal Access Token Routes (only a signed in session can manage tokens)
+	mux.Handle("POST /api/v1/users/tokens", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.accessTokenHandler.CreateToken))))
+	mux.Handle("GET /api/v1/users/tokens", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.accessTokenHandler.ListTokens))))
+	mux.Handle("DELETE /api/v1/users/tokens/{tokenId}", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.accessTokenHandler.RevokeToken))))
+
+	// Audit Trail of the signed in user
+	mux.Handle("GET /api/v1/users/audit", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.auditHandler.ListMyEvents))))
+
+	// Account Deletion (signing in again within the grace period cancels it)
+	mux.Handle("POST /api/v1/users/delete-account", middleware.AuthMiddleware(middleware.RequireSession(http.HandlerFunc(s.accountHandler.DeleteAccount))))
 
 	// Goals Routes (Need Auth Middleware)
-	mux.Handle("GET /api/v1/goals/u/{userId}/get-gw/{workspaceId}", middleware.AuthMiddleware(http.HandlerFunc(s.goalHandler.GetUserGoals)))
-	mux.Handle("POST /api/v1/goals/u/{userId}/create-gw/{workspaceId}", middleware.AuthMiddleware(http.HandlerFunc(s.goalHandler.CreateUserGoal)))
-	mux.Handle("PUT /api/v1/goals/update-goal/{goalId}", middleware.AuthMiddleware(http.HandlerFunc(s.goalHandler.UpdateUserGoal)))
-	mux.Handle("DELETE /api/v1/goals/delete-goal/{goalId}", middleware.AuthMiddleware(http.HandlerFunc(s.goalHandler.DeleteUserGoal)))
-	mux.Handle("POST /api/v1/goals/increament/{goalId}", middleware.AuthMiddleware(http.HandlerFunc(s.goalHandler.IncreamentGoalProgress)))
-	mux.Handle("POST /api/v1/goals/decreament/{goalId}", middleware.AuthMiddleware(http.HandlerFunc(s.goalHandler.DecreamentGoalProgress)))
+	mux.Handle("GET /api/v1/goals/u/{userId}/get-gw/{workspaceId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeGoalsRead, http.HandlerFunc(s.goalHandler.GetUserGoals))))
+	mux.Handle("POST /api/v1/goals/u/{userId}/create-gw/{workspaceId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeGoalsWrite, http.HandlerFunc(s.goalHandler.CreateUserGoal))))
+	mux.Handle("PUT /api/v1/goals/update-goal/{goalId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeGoalsWrite, http.HandlerFunc(s.goalHandler.UpdateUserGoal))))
+	mux.Handle("DELETE /api/v1/goals/delete-goal/{goalId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeGoalsWrite, http.HandlerFunc(s.goalHandler.DeleteUserGoal))))
+	mux.Handle("POST /api/v1/goals/increament/{goalId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeGoalsWrite, http.HandlerFunc(s.goalHandler.IncreamentGoalProgress))))
+	mux.Handle("POST /api/v1/goals/decreament/{goalId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeGoalsWrite, http.HandlerFunc(s.goalHandler.DecreamentGoalProgress))))
 
 	// workspace Routes (Need Auth Middleware)
-	mux.Handle("GET /api/v1/workspaces/get-user-workspaces", middleware.AuthMiddleware(http.HandlerFunc(s.workspaceHandler.GetAllUserWorkspace)))
-	mux.Handle("POST /api/v1/workspaces/create-workspace", middleware.AuthMiddleware(http.HandlerFunc(s.workspaceHandler.CreateWorkspace)))
-	mux.Handle("PUT /api/v1/workspaces/update-workspace", middleware.AuthMiddleware(http.HandlerFunc(s.workspaceHandler.UpdateWorkspace)))
-	mux.Handle("DELETE /api/v1/workspaces/delete-workspace", middleware.AuthMiddleware(http.HandlerFunc(s.workspaceHandler.DeleteWorkspace)))
-	mux.Handle("PUT /api/v1/workspaces/{workspaceId}/layout", middleware.AuthMiddleware(http.HandlerFunc(s.workspaceHandler.UpdateWorkspaceLayout)))
-
-	// it means cors -> log -> actual handler(mux)
-	// global logging and cors middleware
-	wrappedMux := middleware.LoggingMiddleware(middleware.CorsMiddleware(mux))
+	mux.Handle("GET /api/v1/workspaces/get-user-workspaces", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeWorkspacesRead, http.HandlerFunc(s.workspaceHandler.GetAllUserWorkspace))))
+	mux.Handle("POST /api/v1/workspaces/create-workspace", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeWorkspacesAdmin, middleware.RequireVerifiedEmail(http.HandlerFunc(s.workspaceHandler.CreateWorkspace)))))
+	mux.Handle("PUT /api/v1/workspaces/update-workspace", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeWorkspacesAdmin, http.HandlerFunc(s.workspaceHandler.UpdateWorkspace))))
+	mux.Handle("DELETE /api/v1/workspaces/delete-workspace", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeWorkspacesAdmin, http.HandlerFunc(s.workspaceHandler.DeleteWorkspace))))
+	mux.Handle("PUT /api/v1/workspaces/{workspaceId}/layout", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeWorkspacesAdmin, http.HandlerFunc(s.workspaceHandler.UpdateWorkspaceLayout))))
+
+	// label catalog of a workspace (todos pick their labels from it)
+	mux.Handle("GET /api/v1/workspaces/{workspaceId}/labels", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeWorkspacesRead, http.HandlerFunc(s.labelHandler.ListLabels))))
+	mux.Handle("POST /api/v1/workspaces/{workspaceId}/labels", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeWorkspacesAdmin, http.HandlerFunc(s.labelHandler.CreateLabel))))
+	mux.Handle("PUT /api/v1/workspaces/{workspaceId}/labels/order", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeWorkspacesAdmin, http.HandlerFunc(s.labelHandler.ReorderLabels))))
+	mux.Handle("PUT /api/v1/labels/{labelId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeWorkspacesAdmin, http.HandlerFunc(s.labelHandler.UpdateLabel))))
+	mux.Handle("DELETE /api/v1/labels/{labelId}", middleware.AuthMiddleware(middleware.RequireScope(model.ScopeWorkspacesAdmin, http.HandlerFunc(s.labelHandler.DeleteLabel))))
+
+	// search over todos, goals and workspaces (the handler checks the read scope of each kind)
+	mux.Handle("GET /api/v1/search", middleware.AuthMiddleware(http.HandlerFunc(s.searchHandler.Search)))
+
+	// queued client operations in one call (the handler checks the scope of each operation)
+	mux.Handle("POST /api/v1/batch", middleware.AuthMiddleware(http.HandlerFunc(s.batchHandler.RunBatch)))
+
+	// it means request id -> log -> security headers -> cors -> actual handler(mux)
+	// global request meta, logging, security headers and cors middleware
+	wrappedMux := middleware.RequestMeta(middleware.LoggingMiddleware(middleware.SecurityHeaders(middleware.CorsMiddleware(mux))))
 	return http.ListenAndServe(port, wrappedMux)
 }
 
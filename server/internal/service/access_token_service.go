@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+)
+
+const (
+	// accessTokenPrefix makes personal access tokens easy to tell apart from JWTs (and to grep for in leaks)
+	accessTokenPrefix         = "ftpat_"
+	defaultAccessTokenDays    = 90
+	maxAccessTokenDays        = 365
+	accessTokenTouchInterval  = time.Minute
+	accessTokenMaxNameLength  = 100
+	accessTokenDisplayedChars = 12
+)
+
+// AccessTokenService manages personal access tokens and authenticates requests made with them
+type AccessTokenService interface {
+	CreateToken(ctx context.Context, userId string, name string, scopes []string, expiresInDays int) (string, *model.AccessToken, error)
+	ListTokens(ctx context.Context, userId string) ([]model.AccessToken, error)
+	RevokeToken(ctx context.Context, userId string, tokenId string) error
+	IsAccessToken(token string) bool
+	AuthenticateAccessToken(ctx context.Context, token string) (*model.AccessToken, error)
+}
+
+type accessTokenService struct {
+	repo     repository.AccessTokenRepository
+	userRepo repository.UserRepository
+}
+
+// CreateToken returns the plain token once, only its hash is stored
+func (s *accessTokenService) CreateToken(ctx context.Context, userId string, name string, scopes []string, expiresInDays int) (string, *model.AccessToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > accessTokenMaxNameLength {
+		return "", nil, fmt.Errorf("token name must be 1 to %d characters", accessTokenMaxNameLength)
+	}
+
+	scopes, err := validateScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if expiresInDays == 0 {
+		expiresInDays = defaultAccessTokenDays
+	}
+	if expiresInDays < 1 || expiresInDays > maxAccessTokenDays {
+		return "", nil, fmt.Errorf("expiresInDays must be between 1 and %d", maxAccessTokenDays)
+	}
+
+	user, err := s.userRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	secret, err := generateToken()
+	if err != nil {
+		return "", nil, err
+	}
+	plain := accessTokenPrefix + secret
+
+	created, err := s.repo.CreateToken(ctx, model.AccessToken{
+		UserId:    user.ID,
+		Email:     user.Email,
+		Name:      name,
+		TokenHash: hashAccessToken(plain),
+		Prefix:    plain[:accessTokenDisplayedChars],
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(time.Duration(expiresInDays) * 24 * time.Hour),
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return plain, created, nil
+}
+
+func (s *accessTokenService) ListTokens(ctx context.Context, userId string) ([]model.AccessToken, error) {
+	if userId == "" {
+		return nil, errors.New("UserId is Empty in Service")
+	}
+
+	return s.repo.ListTokens(ctx, userId)
+}
+
+func (s *accessTokenService) RevokeToken(ctx context.Context, userId string, tokenId string) error {
+	if userId == "" || tokenId == "" {
+		return errors.New("UserId / TokenId is Empty in Service")
+	}
+
+	return s.repo.RevokeToken(ctx, userId, tokenId)
+}
+
+// AuthenticateAccessToken is used by AuthMiddleware, unknown / expired / revoked tokens are ErrInvalidToken
+func (s *accessTokenService) AuthenticateAccessToken(ctx context.Context, token string) (*model.AccessToken, error) {
+	if !s.IsAccessToken(token) {
+		return nil, repository.ErrInvalidToken
+	}
+
+	found, err := s.repo.GetTokenByHash(ctx, hashAccessToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, repository.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// last used is informational, a failed write shouldn't fail the request
+	if err := s.repo.TouchToken(ctx, found.ID, accessTokenTouchInterval); err != nil {
+		fmt.Println("TouchToken error:", err)
+	}
+
+	return found, nil
+}
+
+// IsAccessToken tells a personal access token apart from a JWT by its prefix
+func (s *accessTokenService) IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, accessTokenPrefix)
+}
+
+// validateScopes rejects unknown scopes and drops duplicates
+func validateScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("at least one scope is required")
+	}
+
+	seen := map[string]bool{}
+	valid := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		known := false
+		for _, s := range model.AccessTokenScopes {
+			if s == scope {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			valid = append(valid, scope)
+		}
+	}
+
+	return valid, nil
+}
+
+func hashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func NewAccessTokenService(repo repository.AccessTokenRepository, userRepo repository.UserRepository) AccessTokenService {
+	return &accessTokenService{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/pkg/nbcrypt"
+	"github.com/ndk123-web/fast-todo/pkg/nmailer"
+)
+
+// accounts are purged in batches so one run can't hold the db for too long
+const accountPurgeBatch = 100
+
+// AccountService deletes accounts: a request only schedules the deletion, the purge
+// removes the data once the grace period is over unless the user signed in again
+type AccountService interface {
+	ScheduleDeletion(ctx context.Context, userId string, password string) (time.Time, error)
+	PurgeDueAccounts(ctx context.Context) (int, error)
+}
+
+type accountService struct {
+	userRepo        repository.UserRepository
+	sessionRepo     repository.SessionRepository
+	accessTokenRepo repository.AccessTokenRepository
+	lockoutRepo     repository.LockoutRepository
+	attemptRepo     repository.LoginAttemptRepository
+	auditRepo       repository.AuditRepository
+	accountDataRepo repository.AccountDataRepository
+	analyticsRepo   repository.AnalyticsCacheRepository
+	mailer          nmailer.Mailer
+	gracePeriod     time.Duration
+}
+
+// ScheduleDeletion needs the password of password accounts, then signs the user out everywhere
+func (s *accountService) ScheduleDeletion(ctx context.Context, userId string, password string) (time.Time, error) {
+	user, err := s.userRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if user.HasPassword() {
+		if ok, err := nbcrypt.ValidatePassword(password, user.HashedPassword); !ok || err != nil {
+			return time.Time{}, errors.New("Password is incorrect")
+		}
+	}
+
+	purgeAt := time.Now().Add(s.gracePeriod)
+	if err := s.userRepo.ScheduleDeletion(ctx, userId, purgeAt); err != nil {
+		return time.Time{}, err
+	}
+
+	// scripts and other devices stop working now, signing in again cancels the deletion
+	if err := s.accessTokenRepo.DeleteUserTokens(ctx, userId); err != nil {
+		return time.Time{}, err
+	}
+	if err := s.sessionRepo.RevokeAllSessions(ctx, userId); err != nil {
+		return time.Time{}, err
+	}
+
+	err = s.mailer.Send(ctx, nmailer.Message{
+		To:      user.Email,
+		Subject: "Your Fast-Todo account will be deleted",
+		Body: fmt.Sprintf("Your account and all its workspaces, todos and goals will be deleted on %s.\n\n", purgeAt.Format("January 2, 2006")) +
+			"Changed your mind? Just sign in before then and the deletion is cancelled.",
+	})
+	if err != nil {
+		fmt.Println("Deletion mail error:", err)
+	}
+
+	return purgeAt, nil
+}
+
+// PurgeDueAccounts deletes every account past its grace period and returns how many were purged
+// the user document goes last, so an account that fails half way is retried on the next run
+func (s *accountService) PurgeDueAccounts(ctx context.Context) (int, error) {
+	now := time.Now()
+	userIds, err := s.userRepo.ListUsersDueForDeletion(ctx, now, accountPurgeBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, userId := range userIds {
+		if err := s.purgeAccount(ctx, userId, now); err != nil {
+			log.Printf("Account purge failed for %s: %v", userId, err)
+			continue
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+func (s *accountService) purgeAccount(ctx context.Context, userId string, now time.Time) error {
+	// re-read right before deleting, the user may have signed in since the list was made
+	user, err := s.userRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.DeletionScheduledAt == nil || user.DeletionScheduledAt.After(now) {
+		return nil
+	}
+
+	if err := s.sessionRepo.RevokeAllSessions(ctx, userId); err != nil {
+		return err
+	}
+	if err := s.analyticsRepo.DeleteUserAnalytics(ctx, userId); err != nil {
+		return err
+	}
+	if err := s.accountDataRepo.DeleteUserData(ctx, userId); err != nil {
+		return err
+	}
+	if err := s.forgetSignIns(ctx, userId, user.Email); err != nil {
+		return err
+	}
+
+	return s.userRepo.DeleteUser(ctx, userId)
+}
+
+// forgetSignIns drops the sign in counters and lockouts of the email, of the ips those lockouts
+// came from, and pseudonymises the audit trail of the user (kept, but without email / ip)
+func (s *accountService) forgetSignIns(ctx context.Context, userId string, email string) error {
+	email = strings.ToLower(strings.TrimSpace(email))
+	ips, err := s.lockoutRepo.LockoutIPs(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	if err := s.attemptRepo.Clear(ctx, emailAttemptKey(email)); err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		if err := s.attemptRepo.Clear(ctx, ipAttemptKey(ip)); err != nil {
+			return err
+		}
+	}
+	if err := s.auditRepo.PseudonymiseUser(ctx, userId, email); err != nil {
+		return err
+	}
+	return s.lockoutRepo.DeleteLockouts(ctx, email)
+}
+
+// StartAccountPurge runs PurgeDueAccounts every interval until ctx is done
+func StartAccountPurge(ctx context.Context, accounts AccountService, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purged, err := accounts.PurgeDueAccounts(ctx)
+				if err != nil {
+					log.Printf("Account purge error: %v", err)
+				} else if purged > 0 {
+					log.Printf("Account purge: %d account(s) deleted", purged)
+				}
+			}
+		}
+	}()
+}
+
+// cancelScheduledDeletion is called on every sign in, coming back is how a user cancels a deletion
+func cancelScheduledDeletion(ctx context.Context, userRepo repository.UserRepository, response *repository.SignUpResponse) error {
+	cancelled, err := userRepo.CancelDeletion(ctx, response.UserId)
+	if err != nil {
+		return err
+	}
+
+	response.DeletionCancelled = cancelled
+	return nil
+}
+
+func NewAccountService(userRepo repository.UserRepository, sessionRepo repository.SessionRepository, accessTokenRepo repository.AccessTokenRepository, lockoutRepo repository.LockoutRepository, attemptRepo repository.LoginAttemptRepository, auditRepo repository.AuditRepository, accountDataRepo repository.AccountDataRepository, analyticsRepo repository.AnalyticsCacheRepository, mailer nmailer.Mailer, gracePeriod time.Duration) AccountService {
+	return &accountService{
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		accessTokenRepo: accessTokenRepo,
+		lockoutRepo:     lockoutRepo,
+		attemptRepo:     attemptRepo,
+		auditRepo:       auditRepo,
+		accountDataRepo: accountDataRepo,
+		analyticsRepo:   analyticsRepo,
+		mailer:          mailer,
+		gracePeriod:     gracePeriod,
+	}
+}
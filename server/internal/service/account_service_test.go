@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPurgeForgetsSignIns(t *testing.T) {
+	due := time.Now().Add(-time.Hour)
+	user := repository.SignInUserRequest{ID: primitive.NewObjectID(), Email: "Someone@Example.com", DeletionScheduledAt: &due}
+	other := primitive.NewObjectID()
+
+	attempts := &fakeAttemptRepo{keys: map[string]bool{
+		"login:fail:email:someone@example.com": true,
+		"login:lock:email:someone@example.com": true,
+		"login:fail:ip:10.0.0.1":               true,
+		"login:fail:email:other@example.com":   true,
+		"login:fail:ip:10.0.0.9":               true,
+	}}
+	lockouts := &fakeLockoutRepo{events: []model.LockoutEvent{
+		{Email: "someone@example.com", IP: "10.0.0.1", Scope: "email"},
+		{Email: "other@example.com", IP: "10.0.0.9", Scope: "email"},
+	}}
+	audit := &fakeAuditRepo{events: []model.AuditEvent{
+		{UserId: user.ID, Email: "someone@example.com", Action: model.AuditSignIn, IP: "10.0.0.1", UserAgent: "curl"},
+		{UserId: user.ID, Action: model.AuditTodoUpdate, Before: map[string]any{"task": "Call mom"}, IP: "10.0.0.1"},
+		// a failed sign in of the email before the account was known
+		{Email: "someone@example.com", Action: model.AuditSignInFailed, IP: "10.0.0.2"},
+		{UserId: other, Email: "other@example.com", Action: model.AuditSignIn, IP: "10.0.0.9"},
+	}}
+	users := newFakeUserRepo(user)
+
+	accounts := NewAccountService(users, &fakeSessionRepo{}, nil, lockouts, attempts, audit, &fakeAccountData{}, &fakeAnalyticsCache{}, nil, time.Hour)
+	purged, err := accounts.PurgeDueAccounts(context.Background())
+	if err != nil || purged != 1 {
+		t.Fatalf("PurgeDueAccounts() = %d, %v, want 1", purged, err)
+	}
+
+	// the counters of the email and of the ips tied to it are gone, the others stay
+	want := map[string]bool{"login:fail:email:other@example.com": true, "login:fail:ip:10.0.0.9": true}
+	if len(attempts.keys) != len(want) {
+		t.Fatalf("login keys left = %v, want %v", attempts.keys, want)
+	}
+	for key := range want {
+		if !attempts.keys[key] {
+			t.Fatalf("login key %s was dropped", key)
+		}
+	}
+	if len(lockouts.events) != 1 || lockouts.events[0].Email != "other@example.com" {
+		t.Fatalf("lockouts left = %+v", lockouts.events)
+	}
+
+	// the audit trail is kept without anything that identifies the user
+	if len(audit.events) != 4 {
+		t.Fatalf("%d audit events left, want 4", len(audit.events))
+	}
+	for _, event := range audit.events[:3] {
+		if event.Email != "" || event.IP != "" || event.UserAgent != "" || event.Before != nil || event.After != nil {
+			t.Fatalf("audit event not pseudonymised: %+v", event)
+		}
+		if event.Action == "" {
+			t.Fatalf("audit event lost its action: %+v", event)
+		}
+	}
+	if kept := audit.events[3]; kept.Email != "other@example.com" || kept.IP != "10.0.0.9" {
+		t.Fatalf("audit event of another user changed: %+v", kept)
+	}
+
+	if _, err := users.GetUserById(context.Background(), user.ID.Hex()); err == nil {
+		t.Fatalf("the user is still there")
+	}
+}
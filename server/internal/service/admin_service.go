@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+)
+
+// ErrSelfAdminAction keeps an admin from disabling or demoting their own account
+// so the last admin can't lock everyone out by accident
+var ErrSelfAdminAction = errors.New("admins can't disable or demote their own account")
+
+// AdminUser is one user as seen by the admin API, with what they store and how many sessions they have
+type AdminUser struct {
+	repository.UserSummary
+	Usage          map[string]int64 `json:"usage"`
+	ActiveSessions int              `json:"activeSessions"`
+}
+
+// AdminService backs the /api/v1/admin routes, callers are checked by RequireRole before getting here
+type AdminService interface {
+	ListUsers(ctx context.Context, search string, page int64, limit int64) (*Page[repository.UserSummary], error)
+	GetUser(ctx context.Context, userId string) (*AdminUser, error)
+	SetDisabled(ctx context.Context, adminId string, userId string, disabled bool) error
+	SetRole(ctx context.Context, adminId string, userId string, role string) error
+	ForcePasswordReset(ctx context.Context, userId string) error
+	UnlockUser(ctx context.Context, adminId string, userId string) error
+	ListTodos(ctx context.Context, page int64, limit int64) (*Page[model.Todo], error)
+	EnsureAdmins(ctx context.Context, emails []string)
+}
+
+type adminService struct {
+	userRepo        repository.UserRepository
+	sessionRepo     repository.SessionRepository
+	accessTokenRepo repository.AccessTokenRepository
+	accountDataRepo repository.AccountDataRepository
+	todoRepo        repository.TodoRepository
+	userService     UserService
+	loginGuard      LoginGuard
+}
+
+func (s *adminService) ListUsers(ctx context.Context, search string, page int64, limit int64) (*Page[repository.UserSummary], error) {
+	page, limit, skip := pageBounds(page, limit)
+	users, total, err := s.userRepo.ListUsers(ctx, search, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page[repository.UserSummary]{Items: users, Total: total, Page: page, Limit: limit}, nil
+}
+
+func (s *adminService) GetUser(ctx context.Context, userId string) (*AdminUser, error) {
+	user, err := s.userRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := s.accountDataRepo.CountUserData(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.sessionRepo.ListSessions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AdminUser{UserSummary: user.Summary(), Usage: usage, ActiveSessions: len(sessions)}, nil
+}
+
+// SetDisabled blocks or restores sign in, disabling also signs the user out and
+// deletes their personal access tokens so nothing they hold keeps working
+func (s *adminService) SetDisabled(ctx context.Context, adminId string, userId string, disabled bool) error {
+	if disabled && adminId == userId {
+		return ErrSelfAdminAction
+	}
+
+	if err := s.userRepo.SetDisabled(ctx, userId, disabled); err != nil {
+		return err
+	}
+	if !disabled {
+		return nil
+	}
+
+	if err := s.sessionRepo.RevokeAllSessions(ctx, userId); err != nil {
+		return err
+	}
+	return s.accessTokenRepo.DeleteUserTokens(ctx, userId)
+}
+
+func (s *adminService) SetRole(ctx context.Context, adminId string, userId string, role string) error {
+	if !slices.Contains(model.Roles, role) {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	if adminId == userId && role != model.RoleAdmin {
+		return ErrSelfAdminAction
+	}
+
+	return s.userRepo.SetRole(ctx, userId, role)
+}
+
+func (s *adminService) ForcePasswordReset(ctx context.Context, userId string) error {
+	return s.userService.ForcePasswordReset(ctx, userId)
+}
+
+// UnlockUser lifts a sign in lockout of the user's email, the lockout event records which admin did it
+func (s *adminService) UnlockUser(ctx context.Context, adminId string, userId string) error {
+	user, err := s.userRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return s.loginGuard.Unlock(ctx, user.Email, adminId)
+}
+
+// ListTodos pages through the todos of every user, it replaces the old all-user-todos dump
+func (s *adminService) ListTodos(ctx context.Context, page int64, limit int64) (*Page[model.Todo], error) {
+	page, limit, skip := pageBounds(page, limit)
+	todos, total, err := s.todoRepo.ListAll(ctx, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page[model.Todo]{Items: todos, Total: total, Page: page, Limit: limit}, nil
+}
+
+// EnsureAdmins promotes the ADMIN_EMAILS accounts on start, emails without an account are skipped
+func (s *adminService) EnsureAdmins(ctx context.Context, emails []string) {
+	for _, email := range emails {
+		user, err := s.userRepo.GetUserByEmail(ctx, email)
+		if errors.Is(err, repository.ErrNotFound) {
+			log.Printf("ADMIN_EMAILS: no account for %s yet", email)
+			continue
+		}
+		if err != nil {
+			log.Printf("ADMIN_EMAILS: lookup of %s failed: %v", email, err)
+			continue
+		}
+		if user.GetRole() == model.RoleAdmin {
+			continue
+		}
+
+		if err := s.userRepo.SetRole(ctx, user.ID.Hex(), model.RoleAdmin); err != nil {
+			log.Printf("ADMIN_EMAILS: promoting %s failed: %v", email, err)
+			continue
+		}
+		log.Printf("ADMIN_EMAILS: %s promoted to admin", email)
+	}
+}
+
+func NewAdminService(userRepo repository.UserRepository, sessionRepo repository.SessionRepository, accessTokenRepo repository.AccessTokenRepository, accountDataRepo repository.AccountDataRepository, todoRepo repository.TodoRepository, userService UserService, loginGuard LoginGuard) AdminService {
+	return &adminService{
+		userRepo:        userRepo,
+		sessionRepo:     sessionRepo,
+		accessTokenRepo: accessTokenRepo,
+		accountDataRepo: accountDataRepo,
+		todoRepo:        todoRepo,
+		userService:     userService,
+		loginGuard:      loginGuard,
+	}
+}
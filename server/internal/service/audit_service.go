@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditService writes and reads the audit log, the other services call Record after a change went through
+type AuditService interface {
+	Record(ctx context.Context, event model.AuditEvent)
+	ListUserEvents(ctx context.Context, userId string, action string, page int64, limit int64) (*Page[model.AuditEvent], error)
+	QueryEvents(ctx context.Context, filter repository.AuditFilter, page int64, limit int64) (*Page[model.AuditEvent], error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+// Record stamps the event with the request id, ip and user agent of ctx
+// a failed write is logged, it never fails the action that was audited
+func (s *auditService) Record(ctx context.Context, event model.AuditEvent) {
+	info := model.RequestInfoFrom(ctx)
+	event.RequestId = info.RequestId
+	event.IP = info.IP
+	event.UserAgent = info.UserAgent
+
+	if err := s.repo.RecordEvent(ctx, event); err != nil {
+		log.Printf("Audit write failed (%s %s): %v", event.Action, event.TargetId, err)
+	}
+}
+
+// ListUserEvents is the caller's own trail, optionally only one action
+func (s *auditService) ListUserEvents(ctx context.Context, userId string, action string, page int64, limit int64) (*Page[model.AuditEvent], error) {
+	return s.QueryEvents(ctx, repository.AuditFilter{UserId: userId, Action: action}, page, limit)
+}
+
+func (s *auditService) QueryEvents(ctx context.Context, filter repository.AuditFilter, page int64, limit int64) (*Page[model.AuditEvent], error) {
+	page, limit, skip := pageBounds(page, limit)
+	events, total, err := s.repo.ListEvents(ctx, filter, skip, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page[model.AuditEvent]{Items: events, Total: total, Page: page, Limit: limit}, nil
+}
+
+// auditEvent builds an event done by userId to their own data
+func auditEvent(action string, userId string, targetType string, targetId string, before map[string]any, after map[string]any) model.AuditEvent {
+	userOid, _ := primitive.ObjectIDFromHex(userId)
+	return model.AuditEvent{
+		UserId:     userOid,
+		ActorId:    userOid,
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Before:     before,
+		After:      after,
+	}
+}
+
+// signInEvent records a successful sign in, method is "password", "two-factor" or the identity provider ("google", an OIDC provider)
+func signInEvent(userId string, email string, method string) model.AuditEvent {
+	event := auditEvent(model.AuditSignIn, userId, "user", userId, nil, nil)
+	event.Email = email
+	event.Detail = method
+	return event
+}
+
+// signInFailedEvent has no actor, whoever tried isn't known, userId is set when the email has an account
+func signInFailedEvent(userId string, email string, reason string) model.AuditEvent {
+	event := auditEvent(model.AuditSignInFailed, userId, "user", userId, nil, nil)
+	event.ActorId = primitive.NilObjectID
+	event.Email = email
+	event.Detail = reason
+	return event
+}
+
+// the summaries keep the fields worth answering "what changed" with, not whole documents
+
+func todoSummary(todo model.Todo) map[string]any {
+	return map[string]any{
+		"task":         todo.Task,
+		"priority":     todo.Priority,
+		"status":       todo.Status,
+		"done":         todo.Done,
+		"startedAt":    todo.StartedAt,
+		"completedAt":  todo.CompletedAt,
+		"workspaceId":  todo.WorkspaceId.Hex(),
+		"dueAt":        todo.DueAt,
+		"startAt":      todo.StartAt,
+		"recurrence":   todo.Recurrence,
+		"checklist":    todo.Checklist,
+		"parentId":     todo.ParentId,
+		"autoComplete": todo.AutoComplete,
+		"labels":       todo.Labels,
+	}
+}
+
+func goalSummary(goal model.Goals) map[string]any {
+	return map[string]any{
+		"title":         goal.Title,
+		"category":      goal.Category,
+		"targetDays":    goal.TargetDays,
+		"currentTarget": goal.CurrentTarget,
+		"done":          goal.Done,
+		"workspaceId":   goal.WorkspaceId.Hex(),
+	}
+}
+
+func labelSummary(label model.Label) map[string]any {
+	return map[string]any{
+		"name":        label.Name,
+		"color":       label.Color,
+		"order":       label.Order,
+		"workspaceId": label.WorkspaceId.Hex(),
+	}
+}
+
+func workspaceSummary(workspace model.Workspace) map[string]any {
+	return map[string]any{
+		"workspaceName": workspace.WorkspaceName,
+	}
+}
+
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	return &auditService{
+		repo: repo,
+	}
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+)
+
+const maxBatchOperations = 100
+
+// ErrInvalidBatch wraps every problem with the batch itself (not with one of its operations)
+var ErrInvalidBatch = errors.New("invalid batch")
+
+// errBatchFailed aborts the transaction once an operation failed
+var errBatchFailed = errors.New("batch operation failed")
+
+// batchIdFields are the payload fields that may hold a temp id of an earlier create
+var batchIdFields = []string{"id", "workspaceId", "parentId", "before", "after"}
+
+// batchCreates are the operation types that make a document, only they take a TempId
+var batchCreates = map[string]bool{
+	model.BatchTodoCreate:      true,
+	model.BatchGoalCreate:      true,
+	model.BatchWorkspaceCreate: true,
+}
+
+// BatchService replays a list of operations (the client queues them while offline)
+// through the todo, goal and workspace services, in one transaction when mongo can
+type BatchService interface {
+	RunBatch(ctx context.Context, userId string, ops []model.BatchOperation) (model.BatchOutcome, error)
+}
+
+type batchService struct {
+	todos      TodoService
+	goals      GoalService
+	workspaces WorkspaceService
+	tx         repository.Transactor
+}
+
+func invalidBatch(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidBatch, reason)
+}
+
+// the payloads, field names follow the bodies of the single endpoints
+type batchTodoCreate struct {
+	model.Todo
+	WorkspaceId string `json:"workspaceId"`
+}
+
+type batchTodoUpdate struct {
+	ID           string    `json:"id"`
+	Task         *string   `json:"task"`
+	Priority     *string   `json:"priority"`
+	DueAt        *string   `json:"dueAt"`
+	StartAt      *string   `json:"startAt"`
+	RRule        *string   `json:"rrule"`
+	TimeZone     string    `json:"timeZone"`
+	Scope        string    `json:"scope"`
+	AutoComplete *bool     `json:"autoComplete"`
+	Labels       *[]string `json:"labels"`
+}
+
+type batchTodoRef struct {
+	ID     string `json:"id"`
+	Toggle string `json:"toggle"`
+	Status string `json:"status"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+type batchGoal struct {
+	ID                string      `json:"id"`
+	WorkspaceId       string      `json:"workspaceId"`
+	GoalName          string      `json:"goalName"`
+	TargetDays        json.Number `json:"targetDays"`
+	Category          string      `json:"category"`
+	UpdatedGoalName   string      `json:"updatedGoalName"`
+	UpdatedTargetDays json.Number `json:"updatedTargetDays"`
+	UpdatedCategory   string      `json:"updatedCategory"`
+	Count             json.Number `json:"count"`
+}
+
+type batchWorkspace struct {
+	WorkspaceName        string `json:"workspaceName"`
+	UpdatedWorkspaceName string `json:"updatedWorkspaceName"`
+}
+
+// RunBatch runs ops in order and stops at the first failure, with a transaction the
+// operations before it are rolled back, without one they stay
+// a returned error means the batch didn't run (or its commit failed), failed operations
+// are reported in the outcome
+func (s *batchService) RunBatch(ctx context.Context, userId string, ops []model.BatchOperation) (model.BatchOutcome, error) {
+	if userId == "" {
+		return model.BatchOutcome{}, errors.New("UserId is Empty in Service")
+	}
+	if err := checkBatch(ops); err != nil {
+		return model.BatchOutcome{}, err
+	}
+
+	if !s.tx.Supported() {
+		return s.runAll(ctx, userId, ops), nil
+	}
+
+	var outcome model.BatchOutcome
+	err := s.tx.WithTransaction(ctx, func(ctx context.Context) error {
+		outcome = s.runAll(ctx, userId, ops)
+		if !outcome.Succeeded {
+			return errBatchFailed
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchFailed) {
+		return model.BatchOutcome{}, err
+	}
+
+	outcome.Transaction = true
+	if !outcome.Succeeded {
+		for i := range outcome.Results {
+			if outcome.Results[i].Status == model.BatchOk {
+				outcome.Results[i].Status = model.BatchRolledBack
+				outcome.Results[i].Id = ""
+				outcome.Results[i].Response = nil
+			}
+		}
+		outcome.Ids = map[string]string{}
+	}
+	return outcome, nil
+}
+
+// checkBatch rejects the whole batch before anything ran
+func checkBatch(ops []model.BatchOperation) error {
+	if len(ops) == 0 {
+		return invalidBatch("operations is empty")
+	}
+	if len(ops) > maxBatchOperations {
+		return invalidBatch(fmt.Sprintf("at most %d operations per batch", maxBatchOperations))
+	}
+
+	tempIds := map[string]bool{}
+	for i, op := range ops {
+		if _, ok := model.BatchScopes[op.Type]; !ok {
+			return invalidBatch(fmt.Sprintf("operation %d has unknown type %q", i, op.Type))
+		}
+		if op.TempId == "" {
+			continue
+		}
+		if !batchCreates[op.Type] {
+			return invalidBatch(fmt.Sprintf("operation %d: only creates take a tempId", i))
+		}
+		if tempIds[op.TempId] {
+			return invalidBatch(fmt.Sprintf("operation %d: tempId %q is used twice", i, op.TempId))
+		}
+		tempIds[op.TempId] = true
+	}
+	return nil
+}
+
+// runAll starts from scratch every time, the transaction may retry it
+func (s *batchService) runAll(ctx context.Context, userId string, ops []model.BatchOperation) model.BatchOutcome {
+	outcome := model.BatchOutcome{Succeeded: true, Results: make([]model.BatchResult, len(ops)), Ids: map[string]string{}}
+
+	for i, op := range ops {
+		result := model.BatchResult{Index: i, Type: op.Type, Status: model.BatchSkipped}
+		if outcome.Succeeded {
+			id, response, err := s.runOne(ctx, userId, op, outcome.Ids)
+			if err != nil {
+				result.Status = model.BatchFailed
+				result.Error = err.Error()
+				outcome.Succeeded = false
+			} else {
+				result.Status = model.BatchOk
+				result.Id = id
+				result.Response = response
+				if op.TempId != "" {
+					outcome.Ids[op.TempId] = id
+				}
+			}
+		}
+		outcome.Results[i] = result
+	}
+
+	return outcome
+}
+
+// resolveTempIds swaps the temp ids in the id fields of payload for the server ids
+func resolveTempIds(payload json.RawMessage, ids map[string]string) (json.RawMessage, error) {
+	if len(payload) == 0 || string(payload) == "null" {
+		return json.RawMessage("{}"), nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return nil, errors.New("payload must be an object")
+	}
+	for _, name := range batchIdFields {
+		var value string
+		if json.Unmarshal(fields[name], &value) != nil {
+			continue
+		}
+		if serverId, ok := ids[value]; ok {
+			fields[name], _ = json.Marshal(serverId)
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// batchDate reads a date of a todo.update payload, nil keeps the date and "" removes it
+func batchDate(name string, value *string) (*time.Time, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if *value == "" {
+		return &time.Time{}, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC3339 date", name)
+	}
+	return &parsed, nil
+}
+
+// batchCount reads targetDays / count, the single endpoints take them as strings so both are accepted
+func batchCount(name string, value json.Number) (int64, error) {
+	count, err := value.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a whole number", name)
+	}
+	return count, nil
+}
+
+// runOne returns the server id of a created document and what the single endpoint responds with
+func (s *batchService) runOne(ctx context.Context, userId string, op model.BatchOperation, ids map[string]string) (string, any, error) {
+	payload, err := resolveTempIds(op.Payload, ids)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch op.Type {
+	case model.BatchTodoCreate:
+		var body batchTodoCreate
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return "", nil, err
+		}
+		todo, err := s.todos.CreateTodo(ctx, body.Todo, body.WorkspaceId, userId)
+		if err != nil {
+			return "", nil, err
+		}
+		return todo.ID.Hex(), todo, nil
+
+	case model.BatchTodoUpdate:
+		var body batchTodoUpdate
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return "", nil, err
+		}
+		dueAt, err := batchDate("dueAt", body.DueAt)
+		if err != nil {
+			return "", nil, err
+		}
+		startAt, err := batchDate("startAt", body.StartAt)
+		if err != nil {
+			return "", nil, err
+		}
+		todo, err := s.todos.UpdateTodo(ctx, body.ID, model.TodoUpdate{
+			Task:     body.Task,
+			Priority: body.Priority,
+			DueAt:    dueAt,
+			StartAt:  startAt,
+			RRule:    body.RRule,
+			TimeZone: body.TimeZone,
+			Scope:    body.Scope,
+
+			AutoComplete: body.AutoComplete,
+			Labels:       body.Labels,
+		}, userId)
+		return "", todo, err
+
+	case model.BatchTodoDelete, model.BatchTodoToggle, model.BatchTodoStatus, model.BatchTodoMove:
+		var body batchTodoRef
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return "", nil, err
+		}
+		switch op.Type {
+		case model.BatchTodoDelete:
+			return "", nil, batchOk(s.todos.DeleteTodo(ctx, body.ID, userId))
+		case model.BatchTodoToggle:
+			_, err := s.todos.ToggleTodo(ctx, body.ID, body.Toggle, userId)
+			return "", nil, err
+		case model.BatchTodoMove:
+			todo, err := s.todos.MoveTodo(ctx, body.ID, userId, body.Before, body.After)
+			return "", todo, err
+		default:
+			todo, err := s.todos.TransitionTodo(ctx, body.ID, body.Status, userId)
+			return "", todo, err
+		}
+
+	case model.BatchGoalCreate, model.BatchGoalUpdate, model.BatchGoalDelete, model.BatchGoalIncrement, model.BatchGoalDecrement:
+		var body batchGoal
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return "", nil, err
+		}
+		return s.runGoal(ctx, userId, op.Type, body)
+
+	default:
+		var body batchWorkspace
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return "", nil, err
+		}
+		switch op.Type {
+		case model.BatchWorkspaceCreate:
+			id, err := s.workspaces.CreateWorkspace(ctx, userId, body.WorkspaceName)
+			return id, id, err
+		case model.BatchWorkspaceUpdate:
+			return "", nil, s.workspaces.UpdatedWorkspace(ctx, userId, body.WorkspaceName, body.UpdatedWorkspaceName)
+		default:
+			return "", nil, s.workspaces.DeleteWorkspace(ctx, userId, body.WorkspaceName)
+		}
+	}
+}
+
+func (s *batchService) runGoal(ctx context.Context, userId string, opType string, body batchGoal) (string, any, error) {
+	switch opType {
+	case model.BatchGoalCreate:
+		targetDays, err := batchCount("targetDays", body.TargetDays)
+		if err != nil {
+			return "", nil, err
+		}
+		goal, err := s.goals.CreateUserGoal(ctx, userId, body.WorkspaceId, body.GoalName, targetDays, body.Category)
+		if err != nil {
+			return "", nil, err
+		}
+		return goal.ID.Hex(), goal, nil
+
+	case model.BatchGoalUpdate:
+		targetDays, err := batchCount("updatedTargetDays", body.UpdatedTargetDays)
+		if err != nil {
+			return "", nil, err
+		}
+		return "", nil, batchOk(s.goals.UpdateUserGoal(ctx, userId, body.ID, body.UpdatedGoalName, targetDays, body.UpdatedCategory))
+
+	case model.BatchGoalDelete:
+		return "", nil, batchOk(s.goals.DeleteUserGoal(ctx, userId, body.ID))
+	}
+
+	count, err := batchCount("count", body.Count)
+	if err != nil {
+		return "", nil, err
+	}
+	if opType == model.BatchGoalIncrement {
+		return "", nil, batchOk(s.goals.IncreamentGoalProgress(ctx, userId, body.ID, count))
+	}
+	return "", nil, batchOk(s.goals.DecreamentGoalProgress(ctx, userId, body.ID, count))
+}
+
+// batchOk turns the (ok, err) of the older service methods into one error
+func batchOk(ok bool, err error) error {
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("operation had no effect")
+	}
+	return nil
+}
+
+func NewBatchService(todos TodoService, goals GoalService, workspaces WorkspaceService, tx repository.Transactor) BatchService {
+	return &batchService{
+		todos:      todos,
+		goals:      goals,
+		workspaces: workspaces,
+		tx:         tx,
+	}
+}
@@ -0,0 +1,663 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// the fakes embed the repository interfaces, a method a test doesn't expect panics on the nil interface
+
+// fakeTodoRepo keeps todos in memory and mirrors what the mongo queries of todoRepo do
+type fakeTodoRepo struct {
+	repository.TodoRepository
+
+	mu    sync.Mutex
+	todos map[primitive.ObjectID]model.Todo
+	// setRanks counts SetRanks calls, one per rebalance
+	setRanks int
+	// failSetWorkspace makes SetWorkspace fail for that todo
+	failSetWorkspace primitive.ObjectID
+}
+
+func newFakeTodoRepo(todos ...model.Todo) *fakeTodoRepo {
+	repo := &fakeTodoRepo{todos: map[primitive.ObjectID]model.Todo{}}
+	for _, todo := range todos {
+		repo.todos[todo.ID] = todo
+	}
+	return repo
+}
+
+func (r *fakeTodoRepo) get(id primitive.ObjectID) model.Todo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.todos[id]
+}
+
+func (r *fakeTodoRepo) find(todoId string, userId string) (model.Todo, error) {
+	oid, err := primitive.ObjectIDFromHex(todoId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	todo, ok := r.todos[oid]
+	if !ok || todo.UserId.Hex() != userId {
+		return model.Todo{}, repository.ErrNotFound
+	}
+	return todo, nil
+}
+
+func (r *fakeTodoRepo) GetTodo(ctx context.Context, todoId string, userId string) (model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.find(todoId, userId)
+}
+
+func (r *fakeTodoRepo) UpdateTodo(ctx context.Context, todoId string, update model.TodoUpdate, userId string) (model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	todo, err := r.find(todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	if update.Task != nil {
+		todo.Task = *update.Task
+	}
+	if update.Priority != nil {
+		todo.Priority = *update.Priority
+	}
+	setFakeDate := func(field **time.Time, value *time.Time) {
+		switch {
+		case value == nil:
+		case value.IsZero():
+			*field = nil
+		default:
+			date := *value
+			*field = &date
+		}
+	}
+	setFakeDate(&todo.DueAt, update.DueAt)
+	setFakeDate(&todo.StartAt, update.StartAt)
+	if update.AutoComplete != nil {
+		todo.AutoComplete = *update.AutoComplete
+	}
+	if update.Labels != nil {
+		todo.Labels = *update.Labels
+	}
+	todo.UpdatedAt = time.Now()
+
+	r.todos[todo.ID] = todo
+	return todo, nil
+}
+
+func (r *fakeTodoRepo) SetRecurrence(ctx context.Context, todoId string, userId string, recurrence *model.Recurrence) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	todo, err := r.find(todoId, userId)
+	if err != nil {
+		return err
+	}
+	if recurrence != nil {
+		copied := *recurrence
+		recurrence = &copied
+	}
+	todo.Recurrence = recurrence
+	r.todos[todo.ID] = todo
+	return nil
+}
+
+func (r *fakeTodoRepo) UpdateLaterInstances(ctx context.Context, userId string, seriesId string, after time.Time, task string, priority string, template *model.Recurrence) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, todo := range r.todos {
+		if todo.UserId.Hex() != userId || todo.Recurrence == nil || todo.Recurrence.SeriesId.Hex() != seriesId || !todo.Recurrence.Occurrence.After(after) {
+			continue
+		}
+		todo.Task = task
+		todo.Priority = priority
+		if template == nil {
+			todo.Recurrence = nil
+		} else {
+			recurrence := *todo.Recurrence
+			recurrence.RRule = template.RRule
+			recurrence.TimeZone = template.TimeZone
+			recurrence.Start = template.Start
+			recurrence.Exceptions = template.Exceptions
+			recurrence.SeriesTask = template.SeriesTask
+			recurrence.SeriesPriority = template.SeriesPriority
+			recurrence.StartOffset = template.StartOffset
+			todo.Recurrence = &recurrence
+		}
+		r.todos[id] = todo
+	}
+	return nil
+}
+
+func (r *fakeTodoRepo) AddSeriesException(ctx context.Context, userId string, seriesId string, occurrence time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	matched := false
+	for id, todo := range r.todos {
+		if todo.UserId.Hex() != userId || todo.Recurrence == nil || todo.Recurrence.SeriesId.Hex() != seriesId {
+			continue
+		}
+		matched = true
+		recurrence := *todo.Recurrence
+		recurrence.Exceptions = append(append([]time.Time(nil), recurrence.Exceptions...), occurrence)
+		todo.Recurrence = &recurrence
+		r.todos[id] = todo
+	}
+	if !matched {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r *fakeTodoRepo) ListChildren(ctx context.Context, parentId string, userId string) ([]model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	children := []model.Todo{}
+	for _, todo := range r.todos {
+		if todo.ParentId != nil && todo.ParentId.Hex() == parentId && todo.UserId.Hex() == userId {
+			children = append(children, todo)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].ID.Hex() < children[j].ID.Hex() })
+	return children, nil
+}
+
+func (r *fakeTodoRepo) ChildProgress(ctx context.Context, userId string, parentIds []primitive.ObjectID) (map[primitive.ObjectID]model.TodoProgress, error) {
+	return map[primitive.ObjectID]model.TodoProgress{}, nil
+}
+
+// column is a status column in rank order, unranked todos first, like ListColumn sorts it
+func (r *fakeTodoRepo) column(userId string, workspaceId string, status string) []model.Todo {
+	var todos []model.Todo
+	for _, todo := range r.todos {
+		if todo.UserId.Hex() == userId && todo.WorkspaceId.Hex() == workspaceId && todo.Status == status {
+			todos = append(todos, todo)
+		}
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		if todos[i].Rank != todos[j].Rank {
+			return todos[i].Rank < todos[j].Rank
+		}
+		return todos[i].ID.Hex() < todos[j].ID.Hex()
+	})
+	return todos
+}
+
+func (r *fakeTodoRepo) LastRank(ctx context.Context, userId string, workspaceId string, status string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	column := r.column(userId, workspaceId, status)
+	if len(column) == 0 {
+		return "", nil
+	}
+	return column[len(column)-1].Rank, nil
+}
+
+func (r *fakeTodoRepo) NeighbourRank(ctx context.Context, userId string, workspaceId string, status string, rank string, below bool, skipId string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	column := r.column(userId, workspaceId, status)
+	if below {
+		for _, todo := range column {
+			if todo.ID.Hex() != skipId && todo.Rank > rank {
+				return todo.Rank, nil
+			}
+		}
+		return "", nil
+	}
+	for i := len(column) - 1; i >= 0; i-- {
+		if column[i].ID.Hex() != skipId && column[i].Rank < rank {
+			return column[i].Rank, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *fakeTodoRepo) SetRank(ctx context.Context, todoId string, userId string, rank string) (model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	todo, err := r.find(todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	todo.Rank = rank
+	r.todos[todo.ID] = todo
+	return todo, nil
+}
+
+func (r *fakeTodoRepo) ListColumn(ctx context.Context, userId string, workspaceId string, status string) ([]model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.column(userId, workspaceId, status), nil
+}
+
+func (r *fakeTodoRepo) SetRanks(ctx context.Context, userId string, status string, changes []model.RankChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.setRanks++
+	for _, change := range changes {
+		todo, ok := r.todos[change.ID]
+		if ok && todo.UserId.Hex() == userId && todo.Status == status && todo.Rank == change.From {
+			todo.Rank = change.To
+			r.todos[todo.ID] = todo
+		}
+	}
+	return nil
+}
+
+func (r *fakeTodoRepo) rebalances() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.setRanks
+}
+
+func (r *fakeTodoRepo) SetWorkspace(ctx context.Context, todoId string, userId string, workspaceId string, rank string) (model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	todo, err := r.find(todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	if todo.ID == r.failSetWorkspace {
+		return model.Todo{}, errFakeWrite
+	}
+	workspaceOid, err := primitive.ObjectIDFromHex(workspaceId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	todo.WorkspaceId = workspaceOid
+	todo.Rank = rank
+	r.todos[todo.ID] = todo
+	return todo, nil
+}
+
+// todoSortValue is the value of the sort key of todo, nil where mongo has no field
+func todoSortValue(todo model.Todo, field string) any {
+	switch field {
+	case model.TodoSortUpdated:
+		return todo.UpdatedAt
+	case model.TodoSortDue:
+		if todo.DueAt == nil {
+			return nil
+		}
+		return *todo.DueAt
+	case model.TodoSortTask:
+		return todo.Task
+	case model.TodoSortRank:
+		if todo.Rank == "" {
+			return nil
+		}
+		return todo.Rank
+	default:
+		return todo.CreatedAt
+	}
+}
+
+// compareSortValues orders like mongo, a missing value first
+func compareSortValues(a any, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if at, ok := a.(time.Time); ok {
+		return at.Compare(b.(time.Time))
+	}
+	return strings.Compare(a.(string), b.(string))
+}
+
+// GetSpecificTodo sorts and pages like the mongo query, the other filters aren't needed by the tests
+func (r *fakeTodoRepo) GetSpecificTodo(ctx context.Context, workspaceId string, userId string, filter model.TodoFilter) ([]model.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// position compares todo with the cursor in the direction of the listing, > 0 comes after it
+	position := func(todo model.Todo, value any, id primitive.ObjectID) int {
+		c := compareSortValues(todoSortValue(todo, filter.Sort), value)
+		if c == 0 {
+			c = strings.Compare(todo.ID.Hex(), id.Hex())
+		}
+		if filter.Desc {
+			return -c
+		}
+		return c
+	}
+
+	todos := []model.Todo{}
+	for _, todo := range r.todos {
+		if todo.UserId.Hex() != userId || todo.WorkspaceId.Hex() != workspaceId {
+			continue
+		}
+		if filter.After != nil && position(todo, filter.After.Value, filter.After.ID) <= 0 {
+			continue
+		}
+		todos = append(todos, todo)
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		return position(todos[i], todoSortValue(todos[j], filter.Sort), todos[j].ID) < 0
+	})
+	if filter.Limit > 0 && int64(len(todos)) > filter.Limit {
+		todos = todos[:filter.Limit]
+	}
+	return todos, nil
+}
+
+// fakeWorkspaceRepo knows which user owns which workspace
+type fakeWorkspaceRepo struct {
+	repository.WorkSpaceRepository
+	owners map[string]string
+}
+
+func (r *fakeWorkspaceRepo) IsWorkspaceOwner(ctx context.Context, userId string, workspaceId string) (bool, error) {
+	return r.owners[workspaceId] == userId, nil
+}
+
+// fakeLabelRepo has empty catalogs
+type fakeLabelRepo struct {
+	repository.LabelRepository
+}
+
+func (r *fakeLabelRepo) ListLabels(ctx context.Context, userId string, workspaceId string) ([]model.Label, error) {
+	return nil, nil
+}
+
+// errFakeWrite is a write the fakes were told to fail
+var errFakeWrite = errors.New("fake write failed")
+
+// fakeTransactor rolls the todos of repo back when fn fails, like a mongo transaction would
+type fakeTransactor struct {
+	repo      *fakeTodoRepo
+	supported bool
+}
+
+func (t *fakeTransactor) Supported() bool {
+	return t.supported
+}
+
+func (t *fakeTransactor) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.repo.mu.Lock()
+	snapshot := maps.Clone(t.repo.todos)
+	t.repo.mu.Unlock()
+
+	if err := fn(ctx); err != nil {
+		t.repo.mu.Lock()
+		t.repo.todos = snapshot
+		t.repo.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// fakeAudit drops every event
+type fakeAudit struct {
+	AuditService
+}
+
+func (a *fakeAudit) Record(ctx context.Context, event model.AuditEvent) {}
+
+// fakeUserRepo holds users by id, only what the user flows under test read and write
+type fakeUserRepo struct {
+	repository.UserRepository
+
+	mu    sync.Mutex
+	users map[string]*repository.SignInUserRequest
+	// twoFactor by user id, users without an entry never enrolled
+	twoFactor map[string]model.TwoFactor
+}
+
+func newFakeUserRepo(users ...repository.SignInUserRequest) *fakeUserRepo {
+	repo := &fakeUserRepo{users: map[string]*repository.SignInUserRequest{}}
+	for i := range users {
+		repo.users[users[i].ID.Hex()] = &users[i]
+	}
+	return repo
+}
+
+func (r *fakeUserRepo) GetUserByEmail(ctx context.Context, email string) (*repository.SignInUserRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+// SignInWithIdentity finds the user the identity is linked to, the legacy email match isn't needed by the tests
+func (r *fakeUserRepo) SignInWithIdentity(ctx context.Context, identity *model.ExternalIdentity) (*repository.SignUpResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if user.Email != identity.Email || !user.HasIdentity(identity.Provider) {
+			continue
+		}
+		if user.Disabled {
+			return nil, repository.ErrAccountDisabled
+		}
+		return &repository.SignUpResponse{Email: user.Email, UserId: user.ID.Hex(), FullName: user.FullName, EmailVerified: user.IsVerified()}, nil
+	}
+	return nil, repository.ErrIdentityNotLinked
+}
+
+func (r *fakeUserRepo) GetTwoFactor(ctx context.Context, userId string) (*model.TwoFactor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	twoFactor := r.twoFactor[userId]
+	return &twoFactor, nil
+}
+
+func (r *fakeUserRepo) CancelDeletion(ctx context.Context, userId string) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeUserRepo) GetUserById(ctx context.Context, userId string) (*repository.SignInUserRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userId]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) ListUsersDueForDeletion(ctx context.Context, now time.Time, limit int64) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []string
+	for id, user := range r.users {
+		if user.DeletionScheduledAt != nil && !user.DeletionScheduledAt.After(now) {
+			due = append(due, id)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeUserRepo) DeleteUser(ctx context.Context, userId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.users, userId)
+	return nil
+}
+
+func (r *fakeUserRepo) UpdatePassword(ctx context.Context, userId string, hashedPassword string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userId]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	passwordSet := true
+	user.HashedPassword = hashedPassword
+	user.PasswordSet = &passwordSet
+	return nil
+}
+
+// fakeSessionRepo only remembers the sessions created and which users were signed out everywhere
+type fakeSessionRepo struct {
+	repository.SessionRepository
+
+	mu         sync.Mutex
+	created    []model.Session
+	revokedAll []string
+}
+
+func (r *fakeSessionRepo) CreateSession(ctx context.Context, session model.Session, tokenId string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.created = append(r.created, session)
+	return nil
+}
+
+func (r *fakeSessionRepo) RevokeAllSessions(ctx context.Context, userId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revokedAll = append(r.revokedAll, userId)
+	return nil
+}
+
+// fakeTokenRepo expires tokens on its own clock like redis expires the keys, now is moved by the test
+type fakeTokenRepo struct {
+	mu     sync.Mutex
+	now    time.Time
+	tokens map[string]fakeToken
+}
+
+type fakeToken struct {
+	userId  string
+	expires time.Time
+}
+
+func newFakeTokenRepo() *fakeTokenRepo {
+	return &fakeTokenRepo{now: time.Now(), tokens: map[string]fakeToken{}}
+}
+
+func (r *fakeTokenRepo) advance(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.now = r.now.Add(d)
+}
+
+func (r *fakeTokenRepo) SaveToken(ctx context.Context, purpose string, token string, userId string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[purpose+":"+token] = fakeToken{userId: userId, expires: r.now.Add(ttl)}
+	return nil
+}
+
+func (r *fakeTokenRepo) ConsumeToken(ctx context.Context, purpose string, token string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	saved, ok := r.tokens[purpose+":"+token]
+	delete(r.tokens, purpose+":"+token)
+	if !ok || !r.now.Before(saved.expires) {
+		return "", repository.ErrInvalidToken
+	}
+	return saved.userId, nil
+}
+
+// fakeAttemptRepo holds the redis keys of the login guard, "login:fail:<key>" / "login:lock:<key>"
+type fakeAttemptRepo struct {
+	repository.LoginAttemptRepository
+	keys map[string]bool
+}
+
+func (r *fakeAttemptRepo) Clear(ctx context.Context, key string) error {
+	delete(r.keys, "login:fail:"+key)
+	delete(r.keys, "login:lock:"+key)
+	return nil
+}
+
+// fakeLockoutRepo keeps the lockout history in a slice
+type fakeLockoutRepo struct {
+	repository.LockoutRepository
+	events []model.LockoutEvent
+}
+
+func (r *fakeLockoutRepo) LockoutIPs(ctx context.Context, email string) ([]string, error) {
+	var ips []string
+	for _, event := range r.events {
+		if event.Email == email && event.IP != "" {
+			ips = append(ips, event.IP)
+		}
+	}
+	return ips, nil
+}
+
+func (r *fakeLockoutRepo) DeleteLockouts(ctx context.Context, email string) error {
+	kept := r.events[:0]
+	for _, event := range r.events {
+		if event.Email != email {
+			kept = append(kept, event)
+		}
+	}
+	r.events = kept
+	return nil
+}
+
+// fakeAuditRepo keeps the audit log in a slice
+type fakeAuditRepo struct {
+	repository.AuditRepository
+	events []model.AuditEvent
+}
+
+func (r *fakeAuditRepo) PseudonymiseUser(ctx context.Context, userId string, email string) error {
+	for i, event := range r.events {
+		if event.UserId.Hex() == userId || event.ActorId.Hex() == userId || event.Email == email {
+			r.events[i].Email, r.events[i].IP, r.events[i].UserAgent = "", "", ""
+			r.events[i].Before, r.events[i].After = nil, nil
+		}
+	}
+	return nil
+}
+
+// fakeAccountData and fakeAnalyticsCache have nothing to delete, the purge only needs them to succeed
+type fakeAccountData struct {
+	repository.AccountDataRepository
+}
+
+func (r *fakeAccountData) DeleteUserData(ctx context.Context, userId string) error {
+	return nil
+}
+
+type fakeAnalyticsCache struct {
+	repository.AnalyticsCacheRepository
+}
+
+func (r *fakeAnalyticsCache) DeleteUserAnalytics(ctx context.Context, userId string) error {
+	return nil
+}
+
+// fakeRateLimit counts every key for good, windows don't run out during a test
+type fakeRateLimit struct {
+	repository.RateLimitRepository
+	counts map[string]int64
+}
+
+func (r *fakeRateLimit) Allow(ctx context.Context, key string, limit int64, window time.Duration) (bool, time.Duration, error) {
+	if r.counts == nil {
+		r.counts = map[string]int64{}
+	}
+	r.counts[key]++
+	if r.counts[key] > limit {
+		return false, window, nil
+	}
+	return true, 0, nil
+}
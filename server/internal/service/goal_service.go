@@ -11,14 +11,28 @@ import (
 type GoalService interface {
 	GetUserGoals(ctx context.Context, userId string, workspaceId string) ([]model.Goals, error)
 	CreateUserGoal(ctx context.Context, userId string, workspaceId string, goalName string, targetDays int64, category string) (model.Goals, error)
-	UpdateUserGoal(ctx context.Context, goalId string, updatedGoalName string, updatedTargetDays int64, updatedCategory string) (bool, error)
-	DeleteUserGoal(ctx context.Context, goalId string) (bool, error)
-	IncreamentGoalProgress(ctx context.Context, goalId string, count int64) (bool, error)
-	DecreamentGoalProgress(ctx context.Context, goalId string, count int64) (bool, error)
+	UpdateUserGoal(ctx context.Context, userId string, goalId string, updatedGoalName string, updatedTargetDays int64, updatedCategory string) (bool, error)
+	DeleteUserGoal(ctx context.Context, userId string, goalId string) (bool, error)
+	IncreamentGoalProgress(ctx context.Context, userId string, goalId string, count int64) (bool, error)
+	DecreamentGoalProgress(ctx context.Context, userId string, goalId string, count int64) (bool, error)
 }
 
 type goalService struct {
-	repo repository.GoalRepository
+	repo          repository.GoalRepository
+	workspaceRepo repository.WorkSpaceRepository
+	audit         AuditService
+}
+
+// ensureWorkspaceOwner returns ErrNotFound unless workspaceId belongs to userId
+func (s *goalService) ensureWorkspaceOwner(ctx context.Context, userId string, workspaceId string) error {
+	ok, err := s.workspaceRepo.IsWorkspaceOwner(ctx, userId, workspaceId)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return repository.ErrNotFound
+	}
+	return nil
 }
 
 func (s *goalService) GetUserGoals(ctx context.Context, userId string, workspaceId string) ([]model.Goals, error) {
@@ -26,6 +40,10 @@ func (s *goalService) GetUserGoals(ctx context.Context, userId string, workspace
 		return nil, errors.New("UserId / WorkspaceID is Empty in Service")
 	}
 
+	if err := s.ensureWorkspaceOwner(ctx, userId, workspaceId); err != nil {
+		return nil, err
+	}
+
 	return s.repo.GetUserGoals(ctx, userId, workspaceId)
 }
 
@@ -34,43 +52,93 @@ func (s *goalService) CreateUserGoal(ctx context.Context, userId string, workspa
 		return model.Goals{}, errors.New("UserId / WorkspaceId in Empty in Service")
 	}
 
-	return s.repo.CreateUserGoal(ctx, userId, workspaceId, goalName, targetDays, category)
+	if err := s.ensureWorkspaceOwner(ctx, userId, workspaceId); err != nil {
+		return model.Goals{}, err
+	}
+
+	created, err := s.repo.CreateUserGoal(ctx, userId, workspaceId, goalName, targetDays, category)
+	if err != nil {
+		return model.Goals{}, err
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditGoalCreate, userId, "goal", created.ID.Hex(), nil, goalSummary(created)))
+	return created, nil
 }
 
-func (s *goalService) UpdateUserGoal(ctx context.Context, goalId string, updatedGoalName string, updatedTargetDays int64, updatedCategory string) (bool, error) {
+func (s *goalService) UpdateUserGoal(ctx context.Context, userId string, goalId string, updatedGoalName string, updatedTargetDays int64, updatedCategory string) (bool, error) {
 	if goalId == "" {
 		return false, errors.New("Goal Id Empty")
 	}
 
-	return s.repo.UpdateUserGoal(ctx, goalId, updatedGoalName, updatedTargetDays, updatedCategory)
+	return s.auditedUpdate(ctx, userId, goalId, func() (bool, error) {
+		return s.repo.UpdateUserGoal(ctx, userId, goalId, updatedGoalName, updatedTargetDays, updatedCategory)
+	})
 }
 
-func (s *goalService) DeleteUserGoal(ctx context.Context, goalId string) (bool, error) {
+func (s *goalService) DeleteUserGoal(ctx context.Context, userId string, goalId string) (bool, error) {
 	if goalId == "" {
 		return false, errors.New("Goal Id is Empty in Service")
 	}
 
-	return s.repo.DeleteUserGoal(ctx, goalId)
+	before, err := s.repo.GetUserGoal(ctx, userId, goalId)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := s.repo.DeleteUserGoal(ctx, userId, goalId)
+	if err != nil {
+		return false, err
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditGoalDelete, userId, "goal", goalId, goalSummary(before), nil))
+	return deleted, nil
 }
 
-func (s *goalService) IncreamentGoalProgress(ctx context.Context, goalId string, count int64) (bool, error) {
+func (s *goalService) IncreamentGoalProgress(ctx context.Context, userId string, goalId string, count int64) (bool, error) {
 	if goalId == "" {
 		return false, errors.New("Goal Id is Empty in Service")
 	}
 
-	return s.repo.IncreamentGoalProgress(ctx, goalId, count)
+	return s.auditedUpdate(ctx, userId, goalId, func() (bool, error) {
+		return s.repo.IncreamentGoalProgress(ctx, userId, goalId, count)
+	})
 }
 
-func (s *goalService) DecreamentGoalProgress(ctx context.Context, goalId string, count int64) (bool, error) {
+func (s *goalService) DecreamentGoalProgress(ctx context.Context, userId string, goalId string, count int64) (bool, error) {
 	if goalId == "" {
 		return false, errors.New("Goal Id is Empty in Service")
 	}
 
-	return s.repo.DecreamentGoalProgress(ctx, goalId, count)
+	return s.auditedUpdate(ctx, userId, goalId, func() (bool, error) {
+		return s.repo.DecreamentGoalProgress(ctx, userId, goalId, count)
+	})
+}
+
+// auditedUpdate runs update and records the goal as it was before and after
+func (s *goalService) auditedUpdate(ctx context.Context, userId string, goalId string, update func() (bool, error)) (bool, error) {
+	before, err := s.repo.GetUserGoal(ctx, userId, goalId)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := update()
+	if err != nil {
+		return false, err
+	}
+
+	after, err := s.repo.GetUserGoal(ctx, userId, goalId)
+	if err != nil {
+		return false, err
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditGoalUpdate, userId, "goal", goalId, goalSummary(before), goalSummary(after)))
+	return ok, nil
 }
 
-func NewGoalService(repo repository.GoalRepository) GoalService {
+func NewGoalService(repo repository.GoalRepository, workspaceRepo repository.WorkSpaceRepository, audit AuditService) GoalService {
 	return &goalService{
-		repo: repo,
+		repo:          repo,
+		workspaceRepo: workspaceRepo,
+		audit:         audit,
 	}
 }
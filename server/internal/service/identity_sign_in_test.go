@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/pkg/njwt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func useTestKeys(t *testing.T) {
+	t.Helper()
+	keys, err := njwt.NewEphemeralKeyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	njwt.SetKeyManager(keys)
+}
+
+func TestSignInWithIdentityTwoFactor(t *testing.T) {
+	useTestKeys(t)
+	identity := &model.ExternalIdentity{Provider: "acme", Subject: "sub-1", Email: "someone@example.com", EmailVerified: true}
+
+	tests := []struct {
+		name      string
+		twoFactor bool
+	}{
+		{name: "2FA off signs in"},
+		{name: "2FA on only gets a challenge", twoFactor: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := repository.SignInUserRequest{ID: primitive.NewObjectID(), Email: identity.Email, Identities: []model.LinkedIdentity{{Provider: "acme", Subject: "sub-1"}}}
+			users := newFakeUserRepo(user)
+			users.twoFactor = map[string]model.TwoFactor{user.ID.Hex(): {Enabled: tt.twoFactor}}
+			sessions := &fakeSessionRepo{}
+			service := NewUserService(users, sessions, nil, nil, nil, nil, &fakeAudit{})
+
+			resp, err := service.SignInWithIdentity(context.Background(), identity, model.ClientInfo{})
+			if err != nil {
+				t.Fatalf("SignInWithIdentity() error = %v", err)
+			}
+
+			if !tt.twoFactor {
+				if resp.TwoFactorRequired || resp.AccessToken == "" || len(sessions.created) != 1 {
+					t.Fatalf("SignInWithIdentity() = %+v with %d sessions, want a signed in session", resp, len(sessions.created))
+				}
+				return
+			}
+
+			// no session and no tokens until the TOTP code is checked
+			if !resp.TwoFactorRequired || resp.AccessToken != "" || resp.RefreshToken != "" || len(sessions.created) != 0 {
+				t.Fatalf("SignInWithIdentity() = %+v with %d sessions, want only a challenge", resp, len(sessions.created))
+			}
+			claims, err := njwt.ParseToken(resp.ChallengeToken, "2fa-challenge")
+			if err != nil {
+				t.Fatalf("challenge token: %v", err)
+			}
+			if claims["userId"] != user.ID.Hex() {
+				t.Fatalf("challenge for %v, want %s", claims["userId"], user.ID.Hex())
+			}
+		})
+	}
+}
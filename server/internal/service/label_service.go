@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxLabelsPerWorkspace = 100
+	maxLabelNameLength    = 50
+	defaultLabelColor     = "#9ca3af"
+)
+
+var labelColorPattern = regexp.MustCompile(`^#([0-9a-f]{3}|[0-9a-f]{6})$`)
+
+// ErrUnknownLabel is returned when a todo is given a label its workspace doesn't have
+var ErrUnknownLabel = errors.New("unknown label, create it in the workspace first")
+
+type LabelService interface {
+	ListLabels(ctx context.Context, userId string, workspaceId string) ([]model.Label, error)
+	CreateLabel(ctx context.Context, userId string, workspaceId string, name string, color string) (model.Label, error)
+	UpdateLabel(ctx context.Context, userId string, labelId string, update model.LabelUpdate) (model.Label, error)
+	DeleteLabel(ctx context.Context, userId string, labelId string) error
+	ReorderLabels(ctx context.Context, userId string, workspaceId string, labelIds []string) ([]model.Label, error)
+}
+
+type labelService struct {
+	repo          repository.LabelRepository
+	todoRepo      repository.TodoRepository // renames / deletes reach the todos using the label
+	workspaceRepo repository.WorkSpaceRepository
+	audit         AuditService
+}
+
+// ensureWorkspaceOwner returns ErrNotFound unless workspaceId belongs to userId
+func (s *labelService) ensureWorkspaceOwner(ctx context.Context, userId string, workspaceId string) error {
+	ok, err := s.workspaceRepo.IsWorkspaceOwner(ctx, userId, workspaceId)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// normalizeLabelName trims name, commas are refused because the list filter is comma separated
+func normalizeLabelName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("label name is empty")
+	}
+	if len(name) > maxLabelNameLength {
+		return "", errors.New("label name is too long")
+	}
+	if strings.Contains(name, ",") {
+		return "", errors.New("label name can't contain a comma")
+	}
+	return name, nil
+}
+
+// normalizeLabelColor accepts "#rgb" / "#rrggbb" in any case and stores it lower case
+func normalizeLabelColor(color string) (string, error) {
+	color = strings.ToLower(strings.TrimSpace(color))
+	if !labelColorPattern.MatchString(color) {
+		return "", errors.New("color must be a hex color like #3b82f6")
+	}
+	return color, nil
+}
+
+func (s *labelService) ListLabels(ctx context.Context, userId string, workspaceId string) ([]model.Label, error) {
+	if err := s.ensureWorkspaceOwner(ctx, userId, workspaceId); err != nil {
+		return nil, err
+	}
+	return s.repo.ListLabels(ctx, userId, workspaceId)
+}
+
+// CreateLabel adds a label at the end of the catalog, grey when no color is given
+func (s *labelService) CreateLabel(ctx context.Context, userId string, workspaceId string, name string, color string) (model.Label, error) {
+	if err := s.ensureWorkspaceOwner(ctx, userId, workspaceId); err != nil {
+		return model.Label{}, err
+	}
+
+	name, err := normalizeLabelName(name)
+	if err != nil {
+		return model.Label{}, err
+	}
+	if color == "" {
+		color = defaultLabelColor
+	}
+	if color, err = normalizeLabelColor(color); err != nil {
+		return model.Label{}, err
+	}
+
+	existing, err := s.repo.ListLabels(ctx, userId, workspaceId)
+	if err != nil {
+		return model.Label{}, err
+	}
+	if len(existing) >= maxLabelsPerWorkspace {
+		return model.Label{}, errors.New("a workspace holds at most 100 labels")
+	}
+	order := 0
+	if len(existing) > 0 {
+		order = existing[len(existing)-1].Order + 1
+	}
+
+	userOid, _ := primitive.ObjectIDFromHex(userId)
+	workspaceOid, _ := primitive.ObjectIDFromHex(workspaceId)
+	created, err := s.repo.CreateLabel(ctx, model.Label{UserId: userOid, WorkspaceId: workspaceOid, Name: name, Color: color, Order: order})
+	if err != nil {
+		return model.Label{}, err
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditLabelCreate, userId, "label", created.ID.Hex(), nil, labelSummary(created)))
+	return created, nil
+}
+
+// UpdateLabel renames / recolors / moves a label, a new name is written to every todo carrying the old one
+func (s *labelService) UpdateLabel(ctx context.Context, userId string, labelId string, update model.LabelUpdate) (model.Label, error) {
+	before, err := s.repo.GetLabel(ctx, userId, labelId)
+	if err != nil {
+		return model.Label{}, err
+	}
+
+	if update.Name != nil {
+		name, err := normalizeLabelName(*update.Name)
+		if err != nil {
+			return model.Label{}, err
+		}
+		update.Name = &name
+	}
+	if update.Color != nil {
+		color, err := normalizeLabelColor(*update.Color)
+		if err != nil {
+			return model.Label{}, err
+		}
+		update.Color = &color
+	}
+
+	// the catalog first, its unique index refuses a name already taken
+	updated, err := s.repo.UpdateLabel(ctx, userId, labelId, update)
+	if err != nil {
+		return model.Label{}, err
+	}
+	if updated.Name != before.Name {
+		if err := s.todoRepo.RenameLabel(ctx, userId, before.WorkspaceId.Hex(), before.Name, updated.Name); err != nil {
+			return model.Label{}, err
+		}
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditLabelUpdate, userId, "label", labelId, labelSummary(before), labelSummary(updated)))
+	return updated, nil
+}
+
+// DeleteLabel removes a label from the catalog and from every todo carrying it
+func (s *labelService) DeleteLabel(ctx context.Context, userId string, labelId string) error {
+	before, err := s.repo.GetLabel(ctx, userId, labelId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteLabel(ctx, userId, labelId); err != nil {
+		return err
+	}
+	if err := s.todoRepo.RemoveLabel(ctx, userId, before.WorkspaceId.Hex(), before.Name); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditLabelDelete, userId, "label", labelId, labelSummary(before), nil))
+	return nil
+}
+
+// ReorderLabels puts the catalog in the order of labelIds, which must list every label once
+func (s *labelService) ReorderLabels(ctx context.Context, userId string, workspaceId string, labelIds []string) ([]model.Label, error) {
+	labels, err := s.ListLabels(ctx, userId, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, label := range labels {
+		known[label.ID.Hex()] = true
+	}
+	if len(labelIds) != len(known) {
+		return nil, errors.New("labelIds must list every label of the workspace once")
+	}
+
+	ordered := make([]primitive.ObjectID, 0, len(labelIds))
+	for _, labelId := range labelIds {
+		if !known[labelId] {
+			return nil, errors.New("labelIds must list every label of the workspace once")
+		}
+		delete(known, labelId)
+		oid, _ := primitive.ObjectIDFromHex(labelId)
+		ordered = append(ordered, oid)
+	}
+
+	if err := s.repo.ReorderLabels(ctx, userId, workspaceId, ordered); err != nil {
+		return nil, err
+	}
+	return s.repo.ListLabels(ctx, userId, workspaceId)
+}
+
+// checkTodoLabels dedupes the labels given to a todo, each one has to be in the catalog of the workspace
+func checkTodoLabels(ctx context.Context, repo repository.LabelRepository, userId string, workspaceId string, names []string) ([]string, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	catalog, err := repo.ListLabels(ctx, userId, workspaceId)
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, label := range catalog {
+		known[label.Name] = true
+	}
+
+	seen := map[string]bool{}
+	var labels []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if !known[name] {
+			return nil, ErrUnknownLabel
+		}
+		if !seen[name] {
+			seen[name] = true
+			labels = append(labels, name)
+		}
+	}
+	return labels, nil
+}
+
+func NewLabelService(repo repository.LabelRepository, todoRepo repository.TodoRepository, workspaceRepo repository.WorkSpaceRepository, audit AuditService) LabelService {
+	return &labelService{repo: repo, todoRepo: todoRepo, workspaceRepo: workspaceRepo, audit: audit}
+}
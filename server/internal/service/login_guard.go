@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/pkg/nmailer"
+)
+
+const (
+	// an email is locked after 5 failures, an ip (shared by offices / NAT) after 20
+	emailFailureThreshold = 5
+	ipFailureThreshold    = 20
+	// failures are forgotten 15 minutes after the last one
+	loginFailureWindow = 15 * time.Minute
+	// every failure past the threshold doubles the lock: 30s, 1m, 2m... up to 15m
+	baseLockout = 30 * time.Second
+	maxLockout  = 15 * time.Minute
+)
+
+// LoginGuard throttles password sign in per email and per client ip
+type LoginGuard interface {
+	Check(ctx context.Context, email string, ip string) error
+	RecordFailure(ctx context.Context, email string, ip string) error
+	RecordSuccess(ctx context.Context, email string) error
+	Unlock(ctx context.Context, email string, unlockedBy string) error
+}
+
+type loginGuard struct {
+	attemptRepo repository.LoginAttemptRepository
+	lockoutRepo repository.LockoutRepository
+	userRepo    repository.UserRepository
+	mailer      nmailer.Mailer
+}
+
+func emailAttemptKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return "ip:" + ip
+}
+
+// Check returns a *repository.RateLimitError while the email or the ip is locked
+func (g *loginGuard) Check(ctx context.Context, email string, ip string) error {
+	emailLock, err := g.attemptRepo.LockRemaining(ctx, emailAttemptKey(email))
+	if err != nil {
+		return err
+	}
+	ipLock, err := g.attemptRepo.LockRemaining(ctx, ipAttemptKey(ip))
+	if err != nil {
+		return err
+	}
+
+	if wait := max(emailLock, ipLock); wait > 0 {
+		return &repository.RateLimitError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// RecordFailure counts a wrong password, once a threshold is crossed it locks and
+// returns the *repository.RateLimitError right away so the client knows to back off
+func (g *loginGuard) RecordFailure(ctx context.Context, email string, ip string) error {
+	var wait time.Duration
+
+	emailKey := emailAttemptKey(email)
+	failures, err := g.attemptRepo.RegisterFailure(ctx, emailKey, loginFailureWindow)
+	if err != nil {
+		return err
+	}
+	if lock := lockoutFor(failures, emailFailureThreshold); lock > 0 {
+		if err := g.lock(ctx, emailKey, model.LockoutEvent{Email: strings.ToLower(strings.TrimSpace(email)), IP: ip, Scope: "email", Failures: failures}, lock); err != nil {
+			return err
+		}
+		// one mail per lockout series, not one per extra guess
+		if failures == emailFailureThreshold {
+			g.notifyLockout(ctx, email, lock)
+		}
+		wait = lock
+	}
+
+	if ipKey := ipAttemptKey(ip); ipKey != "" {
+		failures, err := g.attemptRepo.RegisterFailure(ctx, ipKey, loginFailureWindow)
+		if err != nil {
+			return err
+		}
+		if lock := lockoutFor(failures, ipFailureThreshold); lock > 0 {
+			if err := g.lock(ctx, ipKey, model.LockoutEvent{IP: ip, Scope: "ip", Failures: failures}, lock); err != nil {
+				return err
+			}
+			wait = max(wait, lock)
+		}
+	}
+
+	if wait > 0 {
+		return &repository.RateLimitError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// RecordSuccess forgets the failures of the email, the ip counter is left alone
+// so one valid account can't be used to reset an ip that is guessing others
+func (g *loginGuard) RecordSuccess(ctx context.Context, email string) error {
+	return g.attemptRepo.Clear(ctx, emailAttemptKey(email))
+}
+
+// Unlock lifts the lock of an email before it runs out (admin action)
+func (g *loginGuard) Unlock(ctx context.Context, email string, unlockedBy string) error {
+	if err := g.attemptRepo.Clear(ctx, emailAttemptKey(email)); err != nil {
+		return err
+	}
+	return g.lockoutRepo.MarkUnlocked(ctx, strings.ToLower(strings.TrimSpace(email)), unlockedBy)
+}
+
+func (g *loginGuard) lock(ctx context.Context, key string, event model.LockoutEvent, duration time.Duration) error {
+	if err := g.attemptRepo.Lock(ctx, key, duration); err != nil {
+		return err
+	}
+
+	event.LockedUntil = time.Now().Add(duration)
+	if err := g.lockoutRepo.RecordLockout(ctx, event); err != nil {
+		// the lock is what matters, a missing audit row shouldn't let the attempt through
+		fmt.Println("RecordLockout error:", err)
+	}
+	return nil
+}
+
+// notifyLockout mails the account owner, unknown emails are skipped silently
+func (g *loginGuard) notifyLockout(ctx context.Context, email string, duration time.Duration) {
+	user, err := g.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+
+	err = g.mailer.Send(ctx, nmailer.Message{
+		To:      user.Email,
+		Subject: "Sign in to your Fast-Todo account was locked",
+		Body: fmt.Sprintf("We saw %d failed sign in attempts on your account, so sign in is locked for %s.\n\n", emailFailureThreshold, duration) +
+			"If this wasn't you, consider resetting your password:\n" + clientURL() + "/forgot-password",
+	})
+	if err != nil {
+		fmt.Println("Lockout mail error:", err)
+	}
+}
+
+// lockoutFor is 0 below threshold, then baseLockout doubled for every extra failure, capped at maxLockout
+func lockoutFor(failures int64, threshold int64) time.Duration {
+	if failures < threshold {
+		return 0
+	}
+
+	lock := baseLockout
+	for i := threshold; i < failures && lock < maxLockout; i++ {
+		lock *= 2
+	}
+	return min(lock, maxLockout)
+}
+
+func NewLoginGuard(attemptRepo repository.LoginAttemptRepository, lockoutRepo repository.LockoutRepository, userRepo repository.UserRepository, mailer nmailer.Mailer) LoginGuard {
+	return &loginGuard{
+		attemptRepo: attemptRepo,
+		lockoutRepo: lockoutRepo,
+		userRepo:    userRepo,
+		mailer:      mailer,
+	}
+}
@@ -0,0 +1,34 @@
+package service
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// Page wraps one page of a paginated listing (admin API, audit log)
+type Page[T any] struct {
+	Items []T   `json:"items"`
+	Total int64 `json:"total"`
+	Page  int64 `json:"page"`
+	Limit int64 `json:"limit"`
+}
+
+// CursorPage is one page of a cursor paginated listing, NextCursor is empty on the last page
+// and is sent back as is (clients shouldn't look inside)
+type CursorPage[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	Limit      int64  `json:"limit"`
+}
+
+// pageBounds clamps page / limit from the query string and turns them into a skip
+func pageBounds(page int64, limit int64) (int64, int64, int64) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = defaultPageSize
+	}
+	limit = min(limit, maxPageSize)
+	return page, limit, (page - 1) * limit
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/pkg/nbcrypt"
+	"github.com/ndk123-web/fast-todo/pkg/nmailer"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// passwordReset is a user service over fakes that mails through a FileMailer
+type passwordReset struct {
+	service  UserService
+	users    *fakeUserRepo
+	sessions *fakeSessionRepo
+	tokens   *fakeTokenRepo
+	mailbox  string
+	userId   string
+}
+
+func newPasswordReset(t *testing.T) *passwordReset {
+	t.Helper()
+	hashed, err := nbcrypt.BcryptForPassword("old password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := repository.SignInUserRequest{ID: primitive.NewObjectID(), Email: "someone@example.com", HashedPassword: hashed}
+
+	reset := &passwordReset{
+		users:    newFakeUserRepo(user),
+		sessions: &fakeSessionRepo{},
+		tokens:   newFakeTokenRepo(),
+		mailbox:  filepath.Join(t.TempDir(), "mail.log"),
+		userId:   user.ID.Hex(),
+	}
+	reset.service = NewUserService(reset.users, reset.sessions, reset.tokens, nil, nmailer.NewFileMailer(reset.mailbox), nil, &fakeAudit{})
+	return reset
+}
+
+var resetLinkPattern = regexp.MustCompile(`/reset-password\?token=(\S+)`)
+
+// resetTokens are the tokens of every reset link mailed so far, oldest first
+func (r *passwordReset) resetTokens(t *testing.T) []string {
+	t.Helper()
+	mail, err := os.ReadFile(r.mailbox)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tokens []string
+	for _, match := range resetLinkPattern.FindAllStringSubmatch(string(mail), -1) {
+		token, err := url.QueryUnescape(match[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func (r *passwordReset) forgot(t *testing.T) string {
+	t.Helper()
+	if err := r.service.ForgotPassword(context.Background(), "someone@example.com"); err != nil {
+		t.Fatalf("ForgotPassword() error = %v", err)
+	}
+	tokens := r.resetTokens(t)
+	if len(tokens) == 0 {
+		t.Fatalf("no reset link was mailed")
+	}
+	return tokens[len(tokens)-1]
+}
+
+func (r *passwordReset) passwordIs(t *testing.T, password string) bool {
+	t.Helper()
+	user, err := r.users.GetUserByEmail(context.Background(), "someone@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok, _ := nbcrypt.ValidatePassword(password, user.HashedPassword)
+	return ok
+}
+
+func TestResetPassword(t *testing.T) {
+	reset := newPasswordReset(t)
+	token := reset.forgot(t)
+
+	if err := reset.service.ResetPassword(context.Background(), token, "new password"); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if !reset.passwordIs(t, "new password") || reset.passwordIs(t, "old password") {
+		t.Fatalf("the password wasn't changed")
+	}
+	// every session of the owner is signed out
+	if len(reset.sessions.revokedAll) != 1 || reset.sessions.revokedAll[0] != reset.userId {
+		t.Fatalf("revoked sessions of %v, want %s", reset.sessions.revokedAll, reset.userId)
+	}
+}
+
+func TestResetTokenIsSingleUse(t *testing.T) {
+	reset := newPasswordReset(t)
+	token := reset.forgot(t)
+
+	if err := reset.service.ResetPassword(context.Background(), token, "new password"); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+	if err := reset.service.ResetPassword(context.Background(), token, "another password"); !errors.Is(err, repository.ErrInvalidToken) {
+		t.Fatalf("second ResetPassword() error = %v, want ErrInvalidToken", err)
+	}
+	if !reset.passwordIs(t, "new password") {
+		t.Fatalf("the used token changed the password again")
+	}
+}
+
+func TestResetTokenExpires(t *testing.T) {
+	tests := []struct {
+		name    string
+		after   time.Duration
+		wantErr error
+	}{
+		{name: "just before the ttl", after: passwordResetTTL - time.Second},
+		{name: "at the ttl", after: passwordResetTTL, wantErr: repository.ErrInvalidToken},
+		{name: "long after", after: 24 * time.Hour, wantErr: repository.ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset := newPasswordReset(t)
+			token := reset.forgot(t)
+			reset.tokens.advance(tt.after)
+
+			err := reset.service.ResetPassword(context.Background(), token, "new password")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResetPassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if changed := reset.passwordIs(t, "new password"); changed != (tt.wantErr == nil) {
+				t.Fatalf("password changed = %v", changed)
+			}
+			if revoked := len(reset.sessions.revokedAll) > 0; revoked != (tt.wantErr == nil) {
+				t.Fatalf("sessions revoked = %v", revoked)
+			}
+		})
+	}
+}
+
+func TestResetPasswordRejects(t *testing.T) {
+	reset := newPasswordReset(t)
+	token := reset.forgot(t)
+
+	if err := reset.service.ResetPassword(context.Background(), "", "new password"); !errors.Is(err, repository.ErrInvalidToken) {
+		t.Fatalf("ResetPassword(no token) error = %v, want ErrInvalidToken", err)
+	}
+	if err := reset.service.ResetPassword(context.Background(), strings.Repeat("0", 64), "new password"); !errors.Is(err, repository.ErrInvalidToken) {
+		t.Fatalf("ResetPassword(made up token) error = %v, want ErrInvalidToken", err)
+	}
+	// a too short password doesn't use up the token
+	if err := reset.service.ResetPassword(context.Background(), token, "short"); err == nil {
+		t.Fatalf("ResetPassword(short password) succeeded")
+	}
+	if err := reset.service.ResetPassword(context.Background(), token, "new password"); err != nil {
+		t.Fatalf("ResetPassword() error = %v", err)
+	}
+}
+
+func TestForgotPassword(t *testing.T) {
+	reset := newPasswordReset(t)
+
+	// unknown emails look the same to the caller and get no mail
+	if err := reset.service.ForgotPassword(context.Background(), "nobody@example.com"); err != nil {
+		t.Fatalf("ForgotPassword(unknown) error = %v", err)
+	}
+	if tokens := reset.resetTokens(t); len(tokens) != 0 {
+		t.Fatalf("a reset link was mailed for an unknown email")
+	}
+
+	// every request mails a new token, each one works once
+	first, second := reset.forgot(t), reset.forgot(t)
+	if first == second {
+		t.Fatalf("two requests got the same token")
+	}
+	if err := reset.service.ResetPassword(context.Background(), first, "first password"); err != nil {
+		t.Fatalf("ResetPassword(first) error = %v", err)
+	}
+	if err := reset.service.ResetPassword(context.Background(), second, "second password"); err != nil {
+		t.Fatalf("ResetPassword(second) error = %v", err)
+	}
+}
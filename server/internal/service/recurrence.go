@@ -0,0 +1,146 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/pkg/nrrule"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotRecurring is returned by the series actions for a todo that doesn't repeat
+	ErrNotRecurring = errors.New("todo doesn't repeat")
+	// ErrSeriesEnded is returned when the rule has no occurrence left (COUNT / UNTIL reached)
+	ErrSeriesEnded = errors.New("the series has no more occurrences")
+)
+
+// exceptions can skip at most this many occurrences in a row
+const maxSkippedOccurrences = 1000
+
+func seriesLocation(timeZone string) (*time.Location, error) {
+	if timeZone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", timeZone)
+	}
+	return loc, nil
+}
+
+// normalizeRRule parses rrule and returns it in canonical form
+func normalizeRRule(rrule string) (string, error) {
+	rule, err := nrrule.Parse(rrule)
+	if err != nil {
+		return "", err
+	}
+	return rule.String(), nil
+}
+
+// newRecurrence starts a series at todo, its due date is the first occurrence
+func newRecurrence(todo model.Todo, rrule string, timeZone string) (*model.Recurrence, error) {
+	if todo.DueAt == nil {
+		return nil, errors.New("repeating todos need a dueAt")
+	}
+	rrule, err := normalizeRRule(rrule)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := seriesLocation(timeZone); err != nil {
+		return nil, err
+	}
+
+	recurrence := &model.Recurrence{
+		RRule:      rrule,
+		TimeZone:   timeZone,
+		SeriesId:   todo.ID,
+		Start:      *todo.DueAt,
+		Occurrence: *todo.DueAt,
+	}
+	setSeriesTemplate(recurrence, todo)
+	return recurrence, nil
+}
+
+// setSeriesTemplate copies what the next instances inherit from todo
+func setSeriesTemplate(recurrence *model.Recurrence, todo model.Todo) {
+	recurrence.SeriesTask = todo.Task
+	recurrence.SeriesPriority = todo.Priority
+	recurrence.StartOffset = 0
+	if todo.StartAt != nil && todo.DueAt != nil {
+		recurrence.StartOffset = todo.DueAt.Sub(*todo.StartAt)
+	}
+}
+
+// isException compares calendar days in the series time zone, so a client can send the
+// occurrence at midnight or at its exact time
+func isException(recurrence *model.Recurrence, occurrence time.Time, loc *time.Location) bool {
+	y, m, d := occurrence.In(loc).Date()
+	for _, exception := range recurrence.Exceptions {
+		ey, em, ed := exception.In(loc).Date()
+		if y == ey && m == em && d == ed {
+			return true
+		}
+	}
+	return false
+}
+
+// nextOccurrence is the first occurrence of the series after after that isn't an exception
+func nextOccurrence(recurrence *model.Recurrence, after time.Time) (time.Time, bool, error) {
+	rule, err := nrrule.Parse(recurrence.RRule)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	loc, err := seriesLocation(recurrence.TimeZone)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	start := recurrence.Start.In(loc)
+	for range maxSkippedOccurrences {
+		next, ok := rule.After(start, after)
+		if !ok {
+			return time.Time{}, false, nil
+		}
+		if !isException(recurrence, next, loc) {
+			return next, true, nil
+		}
+		after = next
+	}
+	return time.Time{}, false, nil
+}
+
+// nextInstance builds the todo that follows todo in its series, false when the series ended
+func nextInstance(todo model.Todo) (model.Todo, bool, error) {
+	occurrence, ok, err := nextOccurrence(todo.Recurrence, todo.Recurrence.Occurrence)
+	if err != nil || !ok {
+		return model.Todo{}, false, err
+	}
+
+	recurrence := *todo.Recurrence
+	recurrence.Occurrence = occurrence
+	recurrence.NextCreated = false
+
+	next := model.Todo{
+		ID:         primitive.NewObjectID(),
+		Task:       recurrence.SeriesTask,
+		Status:     model.TodoStatusNotStarted,
+		Priority:   recurrence.SeriesPriority,
+		DueAt:      &occurrence,
+		Recurrence: &recurrence,
+		// the checklist starts over unticked, a repeating subtask stays under its parent
+		ParentId:     todo.ParentId,
+		AutoComplete: todo.AutoComplete,
+		Labels:       todo.Labels,
+	}
+	for _, item := range todo.Checklist {
+		next.Checklist = append(next.Checklist, model.ChecklistItem{ID: primitive.NewObjectID(), Text: item.Text, CreatedAt: time.Now()})
+	}
+	if recurrence.StartOffset > 0 {
+		startAt := occurrence.Add(-recurrence.StartOffset)
+		next.StartAt = &startAt
+	}
+	return next, true, nil
+}
@@ -0,0 +1,252 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testSeries is a weekly series on mondays with three instances: done, open and a later one
+type testSeries struct {
+	repo    *fakeTodoRepo
+	service *todoService
+	userId  string
+	done    primitive.ObjectID
+	open    primitive.ObjectID
+	later   primitive.ObjectID
+}
+
+func monday(day int) time.Time {
+	return time.Date(2026, 3, day, 9, 0, 0, 0, time.UTC)
+}
+
+func newTestSeries(t *testing.T) *testSeries {
+	t.Helper()
+	userId := primitive.NewObjectID()
+	workspaceId := primitive.NewObjectID()
+	seriesId := primitive.NewObjectID()
+
+	instance := func(id primitive.ObjectID, occurrence time.Time, status string) model.Todo {
+		due := occurrence
+		start := occurrence.Add(-2 * time.Hour)
+		return model.Todo{
+			ID:          id,
+			UserId:      userId,
+			WorkspaceId: workspaceId,
+			Task:        "Weekly review",
+			Priority:    "medium",
+			Status:      status,
+			DueAt:       &due,
+			StartAt:     &start,
+			Recurrence: &model.Recurrence{
+				RRule:          "FREQ=WEEKLY;BYDAY=MO",
+				SeriesId:       seriesId,
+				Start:          monday(2),
+				Occurrence:     occurrence,
+				SeriesTask:     "Weekly review",
+				SeriesPriority: "medium",
+				StartOffset:    2 * time.Hour,
+			},
+		}
+	}
+
+	series := &testSeries{userId: userId.Hex(), done: seriesId, open: primitive.NewObjectID(), later: primitive.NewObjectID()}
+	series.repo = newFakeTodoRepo(
+		instance(series.done, monday(2), model.TodoStatusDone),
+		instance(series.open, monday(9), model.TodoStatusNotStarted),
+		instance(series.later, monday(16), model.TodoStatusNotStarted),
+	)
+	series.service = &todoService{repo: series.repo, audit: &fakeAudit{}}
+	return series
+}
+
+func (s *testSeries) update(t *testing.T, update model.TodoUpdate) model.Todo {
+	t.Helper()
+	updated, err := s.service.UpdateTodo(context.Background(), s.open.Hex(), update, s.userId)
+	if err != nil {
+		t.Fatalf("UpdateTodo() error = %v", err)
+	}
+	return updated
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
+
+func TestUpdateSeriesScopes(t *testing.T) {
+	tuesday := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		update model.TodoUpdate
+		// what the open and the later instance look like afterwards
+		openTask, laterTask     string
+		openSeries, laterSeries string
+		openStart, laterStart   time.Time
+		openRRule, laterRRule   string
+	}{
+		{
+			name:     "this changes only the instance",
+			update:   model.TodoUpdate{Task: stringPtr("Monthly review")},
+			openTask: "Monthly review", laterTask: "Weekly review",
+			openSeries: "Weekly review", laterSeries: "Weekly review",
+			openStart: monday(2), laterStart: monday(2),
+			openRRule: "FREQ=WEEKLY;BYDAY=MO", laterRRule: "FREQ=WEEKLY;BYDAY=MO",
+		},
+		{
+			name:     "this with a new due date keeps the series where it was",
+			update:   model.TodoUpdate{DueAt: &tuesday},
+			openTask: "Weekly review", laterTask: "Weekly review",
+			openSeries: "Weekly review", laterSeries: "Weekly review",
+			openStart: monday(2), laterStart: monday(2),
+			openRRule: "FREQ=WEEKLY;BYDAY=MO", laterRRule: "FREQ=WEEKLY;BYDAY=MO",
+		},
+		{
+			name:     "future changes the later instances and the template",
+			update:   model.TodoUpdate{Task: stringPtr("Monthly review"), Scope: model.SeriesScopeFuture},
+			openTask: "Monthly review", laterTask: "Monthly review",
+			openSeries: "Monthly review", laterSeries: "Monthly review",
+			openStart: monday(2), laterStart: monday(2),
+			openRRule: "FREQ=WEEKLY;BYDAY=MO", laterRRule: "FREQ=WEEKLY;BYDAY=MO",
+		},
+		{
+			name:     "future with a new due date starts the series over there",
+			update:   model.TodoUpdate{DueAt: &tuesday, Scope: model.SeriesScopeFuture},
+			openTask: "Weekly review", laterTask: "Weekly review",
+			openSeries: "Weekly review", laterSeries: "Weekly review",
+			openStart: tuesday, laterStart: tuesday,
+			openRRule: "FREQ=WEEKLY;BYDAY=MO", laterRRule: "FREQ=WEEKLY;BYDAY=MO",
+		},
+		{
+			name:     "a new rule applies from this instance on",
+			update:   model.TodoUpdate{RRule: stringPtr("freq=daily;count=3")},
+			openTask: "Weekly review", laterTask: "Weekly review",
+			openSeries: "Weekly review", laterSeries: "Weekly review",
+			openStart: monday(9), laterStart: monday(9),
+			openRRule: "FREQ=DAILY;COUNT=3", laterRRule: "FREQ=DAILY;COUNT=3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := newTestSeries(t)
+			series.update(t, tt.update)
+
+			open, later := series.repo.get(series.open), series.repo.get(series.later)
+			if open.Task != tt.openTask || later.Task != tt.laterTask {
+				t.Fatalf("tasks = %q / %q, want %q / %q", open.Task, later.Task, tt.openTask, tt.laterTask)
+			}
+			if open.Recurrence.SeriesTask != tt.openSeries || later.Recurrence.SeriesTask != tt.laterSeries {
+				t.Fatalf("series tasks = %q / %q, want %q / %q", open.Recurrence.SeriesTask, later.Recurrence.SeriesTask, tt.openSeries, tt.laterSeries)
+			}
+			if !open.Recurrence.Start.Equal(tt.openStart) || !later.Recurrence.Start.Equal(tt.laterStart) {
+				t.Fatalf("starts = %v / %v, want %v / %v", open.Recurrence.Start, later.Recurrence.Start, tt.openStart, tt.laterStart)
+			}
+			if open.Recurrence.RRule != tt.openRRule || later.Recurrence.RRule != tt.laterRRule {
+				t.Fatalf("rules = %q / %q, want %q / %q", open.Recurrence.RRule, later.Recurrence.RRule, tt.openRRule, tt.laterRRule)
+			}
+
+			// what came before the edited instance is never touched
+			if done := series.repo.get(series.done); done.Task != "Weekly review" || !done.Recurrence.Start.Equal(monday(2)) || done.Recurrence.RRule != "FREQ=WEEKLY;BYDAY=MO" {
+				t.Fatalf("the done instance changed: %+v", done)
+			}
+			// priority was never sent
+			if open.Priority != "medium" || later.Priority != "medium" {
+				t.Fatalf("priorities = %q / %q, want medium", open.Priority, later.Priority)
+			}
+		})
+	}
+}
+
+func TestUpdateSeriesStopRepeating(t *testing.T) {
+	series := newTestSeries(t)
+	updated := series.update(t, model.TodoUpdate{RRule: stringPtr("")})
+
+	if updated.Recurrence != nil || series.repo.get(series.open).Recurrence != nil || series.repo.get(series.later).Recurrence != nil {
+		t.Fatalf("the open and later instances still repeat")
+	}
+	if series.repo.get(series.done).Recurrence == nil {
+		t.Fatalf("the done instance lost its recurrence")
+	}
+}
+
+func TestUpdateSeriesRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		update model.TodoUpdate
+	}{
+		{name: "unknown scope", update: model.TodoUpdate{Scope: "all"}},
+		{name: "bad rule", update: model.TodoUpdate{RRule: stringPtr("FREQ=HOURLY")}},
+		{name: "unknown time zone", update: model.TodoUpdate{RRule: stringPtr("FREQ=DAILY"), TimeZone: "Mars/Olympus"}},
+		{name: "removing the due date of a repeating todo", update: model.TodoUpdate{DueAt: &time.Time{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series := newTestSeries(t)
+			before := series.repo.get(series.open)
+			if _, err := series.service.UpdateTodo(context.Background(), series.open.Hex(), tt.update, series.userId); err == nil {
+				t.Fatalf("UpdateTodo() succeeded")
+			}
+			if after := series.repo.get(series.open); !reflect.DeepEqual(after, before) {
+				t.Fatalf("a rejected update wrote %+v", after)
+			}
+		})
+	}
+}
+
+func TestSeriesExceptions(t *testing.T) {
+	series := newTestSeries(t)
+	ctx := context.Background()
+
+	// skip the 16th and the 23rd for every instance of the series
+	for _, day := range []int{16, 23} {
+		if err := series.service.AddException(ctx, series.open.Hex(), series.userId, monday(day)); err != nil {
+			t.Fatalf("AddException() error = %v", err)
+		}
+	}
+	for _, id := range []primitive.ObjectID{series.done, series.open, series.later} {
+		if got := len(series.repo.get(id).Recurrence.Exceptions); got != 2 {
+			t.Fatalf("%s has %d exceptions, want 2", id.Hex(), got)
+		}
+	}
+
+	// a future edit carries the exceptions on to the later instances
+	series.update(t, model.TodoUpdate{Priority: stringPtr("high"), Scope: model.SeriesScopeFuture})
+	later := series.repo.get(series.later)
+	if later.Priority != "high" || later.Recurrence.SeriesPriority != "high" || len(later.Recurrence.Exceptions) != 2 {
+		t.Fatalf("later instance = %+v", later)
+	}
+
+	// skipping the open instance jumps over both exceptions, task and priority stay
+	skipped, err := series.service.SkipOccurrence(ctx, series.open.Hex(), series.userId)
+	if err != nil {
+		t.Fatalf("SkipOccurrence() error = %v", err)
+	}
+	if !skipped.DueAt.Equal(monday(30)) || !skipped.Recurrence.Occurrence.Equal(monday(30)) {
+		t.Fatalf("skipped to %v / %v, want %v", skipped.DueAt, skipped.Recurrence.Occurrence, monday(30))
+	}
+	if want := monday(30).Add(-2 * time.Hour); !skipped.StartAt.Equal(want) {
+		t.Fatalf("startAt = %v, want %v", skipped.StartAt, want)
+	}
+	if skipped.Task != "Weekly review" || skipped.Priority != "high" {
+		t.Fatalf("SkipOccurrence() changed task / priority: %q / %q", skipped.Task, skipped.Priority)
+	}
+
+	if _, err := series.service.SkipOccurrence(ctx, primitive.NewObjectID().Hex(), series.userId); err == nil {
+		t.Fatalf("SkipOccurrence() of an unknown todo succeeded")
+	}
+}
+
+func TestSkipOccurrenceEndOfSeries(t *testing.T) {
+	series := newTestSeries(t)
+	series.update(t, model.TodoUpdate{RRule: stringPtr("FREQ=WEEKLY;COUNT=1")})
+
+	if _, err := series.service.SkipOccurrence(context.Background(), series.open.Hex(), series.userId); !errors.Is(err, ErrSeriesEnded) {
+		t.Fatalf("SkipOccurrence() error = %v, want ErrSeriesEnded", err)
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/internal/search"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	maxSearchText      = 200
+)
+
+// ErrInvalidSearch wraps every problem with a search query so the handler can answer 400
+var ErrInvalidSearch = errors.New("invalid search")
+
+type SearchService interface {
+	Search(ctx context.Context, q search.Query) ([]search.Result, error)
+}
+
+type searchService struct {
+	index         search.SearchIndex
+	workspaceRepo repository.WorkSpaceRepository
+}
+
+func invalidSearch(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidSearch, reason)
+}
+
+// Search checks q and runs it on the index, q.UserId is always the caller
+func (s *searchService) Search(ctx context.Context, q search.Query) ([]search.Result, error) {
+	if q.UserId == "" {
+		return nil, errors.New("UserId is Empty in Service")
+	}
+	if q.Text == "" {
+		return nil, invalidSearch("q is required")
+	}
+	if len(q.Text) > maxSearchText {
+		return nil, invalidSearch("q is too long")
+	}
+
+	for _, kind := range q.Kinds {
+		if kind != search.KindTodo && kind != search.KindGoal && kind != search.KindWorkspace {
+			return nil, invalidSearch("kinds can be todo, goal and workspace")
+		}
+	}
+	if q.Status != "" {
+		if _, ok := todoTransitions[q.Status]; !ok {
+			return nil, invalidSearch(ErrUnknownStatus.Error())
+		}
+	}
+	if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+		return nil, invalidSearch("from has to be before to")
+	}
+
+	if q.Limit <= 0 {
+		q.Limit = defaultSearchLimit
+	}
+	if q.Limit > maxSearchLimit {
+		q.Limit = maxSearchLimit
+	}
+
+	if q.WorkspaceId != "" {
+		ok, err := s.workspaceRepo.IsWorkspaceOwner(ctx, q.UserId, q.WorkspaceId)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, repository.ErrNotFound
+		}
+	}
+
+	return s.index.Search(ctx, q)
+}
+
+func NewSearchService(index search.SearchIndex, workspaceRepo repository.WorkSpaceRepository) SearchService {
+	return &searchService{index: index, workspaceRepo: workspaceRepo}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+)
+
+// SessionService lets a user see where they are signed in and sign out a single device
+type SessionService interface {
+	ListSessions(ctx context.Context, userId string, currentSessionId string) ([]model.Session, error)
+	RevokeSession(ctx context.Context, userId string, sessionId string) error
+}
+
+type sessionService struct {
+	repo repository.SessionRepository
+}
+
+func (s *sessionService) ListSessions(ctx context.Context, userId string, currentSessionId string) ([]model.Session, error) {
+	if userId == "" {
+		return nil, errors.New("UserId is Empty in Service")
+	}
+
+	sessions, err := s.repo.ListSessions(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// mark the session of the token used for this request so the client can label it
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionId
+	}
+
+	return sessions, nil
+}
+
+func (s *sessionService) RevokeSession(ctx context.Context, userId string, sessionId string) error {
+	if userId == "" || sessionId == "" {
+		return errors.New("UserId / SessionId is Empty in Service")
+	}
+
+	return s.repo.RevokeSession(ctx, userId, sessionId)
+}
+
+// DeviceFromUserAgent turns a user agent into a short label like "Chrome on Windows"
+// good enough for a sessions list, not meant to be an exact browser detection
+func DeviceFromUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	ua := strings.ToLower(userAgent)
+
+	platform := "Unknown OS"
+	switch {
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"):
+		platform = "iOS"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os"), strings.Contains(ua, "macintosh"):
+		platform = "macOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	// order matters, Edge and Opera also say Chrome and Chrome also says Safari
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/"), strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"), strings.Contains(ua, "postman"), strings.Contains(ua, "go-http-client"):
+		return userAgent
+	}
+
+	return browser + " on " + platform
+}
+
+func NewSessionService(repo repository.SessionRepository) SessionService {
+	return &sessionService{
+		repo: repo,
+	}
+}
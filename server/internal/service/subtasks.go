@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	maxChecklistItems    = 100
+	maxChecklistItemText = 500
+)
+
+// newChecklistItem checks the text of an item and gives it an id
+func newChecklistItem(text string) (model.ChecklistItem, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return model.ChecklistItem{}, errors.New("checklist item text is empty")
+	}
+	if len(text) > maxChecklistItemText {
+		return model.ChecklistItem{}, errors.New("checklist item text is too long")
+	}
+	return model.ChecklistItem{ID: primitive.NewObjectID(), Text: text, CreatedAt: time.Now()}, nil
+}
+
+// progressOf adds the checklist of todo to the counts of its subtasks, nil when it has neither
+func progressOf(todo model.Todo, children model.TodoProgress) *model.TodoProgress {
+	progress := children
+	for _, item := range todo.Checklist {
+		progress.Total++
+		if item.Done {
+			progress.Done++
+		}
+	}
+	if progress.Total == 0 {
+		return nil
+	}
+	return &progress
+}
+
+// withProgress fills Progress of todos, subtasks of every parent among them are counted in one query
+func (s *todoService) withProgress(ctx context.Context, userId string, todos []model.Todo) error {
+	var parentIds []primitive.ObjectID
+	for _, todo := range todos {
+		if todo.ParentId == nil {
+			parentIds = append(parentIds, todo.ID)
+		}
+	}
+
+	children, err := s.repo.ChildProgress(ctx, userId, parentIds)
+	if err != nil {
+		return err
+	}
+	for i := range todos {
+		todos[i].Progress = progressOf(todos[i], children[todos[i].ID])
+	}
+	return nil
+}
+
+// withTodoProgress is withProgress for a single todo
+func (s *todoService) withTodoProgress(ctx context.Context, todo model.Todo, userId string) (model.Todo, error) {
+	todos := []model.Todo{todo}
+	if err := s.withProgress(ctx, userId, todos); err != nil {
+		return model.Todo{}, err
+	}
+	return todos[0], nil
+}
+
+// syncAutoComplete marks an auto completing todo done once every item / subtask is, and open again
+// when one is reopened or added, todo needs its Progress filled
+// a blocked / cancelled todo stays where the user put it
+func (s *todoService) syncAutoComplete(ctx context.Context, todo *model.Todo, userId string) error {
+	if !todo.AutoComplete || todo.Progress == nil {
+		return nil
+	}
+
+	allDone := todo.Progress.Done == todo.Progress.Total
+	if allDone == todo.Done {
+		return nil
+	}
+
+	status := model.TodoStatusDone
+	if !allDone {
+		status = model.TodoStatusNotStarted
+		if todo.Progress.Done > 0 {
+			status = model.TodoStatusInProgress
+		}
+	}
+	if !canTransition(todo.Status, status) {
+		return nil
+	}
+
+	after, err := s.TransitionTodo(ctx, todo.ID.Hex(), status, userId)
+	if err != nil {
+		return err
+	}
+	after.Progress = todo.Progress
+	*todo = after
+	return nil
+}
+
+// syncParent re-checks the parent of a subtask that was added, toggled or deleted
+func (s *todoService) syncParent(ctx context.Context, parentId primitive.ObjectID, userId string) error {
+	parent, err := s.repo.GetTodo(ctx, parentId.Hex(), userId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if parent, err = s.withTodoProgress(ctx, parent, userId); err != nil {
+		return err
+	}
+	return s.syncAutoComplete(ctx, &parent, userId)
+}
+
+// checklistChanged finishes every checklist action: progress, auto complete and the audit event
+func (s *todoService) checklistChanged(ctx context.Context, before model.Todo, after model.Todo, userId string) (model.Todo, error) {
+	after, err := s.withTodoProgress(ctx, after, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	if err := s.syncAutoComplete(ctx, &after, userId); err != nil {
+		return model.Todo{}, err
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditTodoUpdate, userId, "todo", after.ID.Hex(), todoSummary(before), todoSummary(after)))
+	return after, nil
+}
+
+// AddChecklistItem appends an item to the checklist of todoId
+func (s *todoService) AddChecklistItem(ctx context.Context, todoId string, userId string, text string) (model.Todo, error) {
+	before, err := s.repo.GetTodo(ctx, todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	if len(before.Checklist) >= maxChecklistItems {
+		return model.Todo{}, errors.New("a checklist holds at most 100 items")
+	}
+
+	item, err := newChecklistItem(text)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	after, err := s.repo.AddChecklistItem(ctx, todoId, userId, item)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	return s.checklistChanged(ctx, before, after, userId)
+}
+
+// UpdateChecklistItem renames and / or ticks one item, nil leaves that part alone
+func (s *todoService) UpdateChecklistItem(ctx context.Context, todoId string, userId string, itemId string, text *string, done *bool) (model.Todo, error) {
+	before, err := s.repo.GetTodo(ctx, todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	if text != nil {
+		item, err := newChecklistItem(*text)
+		if err != nil {
+			return model.Todo{}, err
+		}
+		text = &item.Text
+	}
+
+	after, err := s.repo.UpdateChecklistItem(ctx, todoId, userId, itemId, text, done)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	return s.checklistChanged(ctx, before, after, userId)
+}
+
+// ReorderChecklist puts the items in the order of itemIds, which must list every item once
+func (s *todoService) ReorderChecklist(ctx context.Context, todoId string, userId string, itemIds []string) (model.Todo, error) {
+	before, err := s.repo.GetTodo(ctx, todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	items := map[string]model.ChecklistItem{}
+	for _, item := range before.Checklist {
+		items[item.ID.Hex()] = item
+	}
+	if len(itemIds) != len(items) {
+		return model.Todo{}, errors.New("itemIds must list every checklist item once")
+	}
+
+	ordered := make([]model.ChecklistItem, 0, len(itemIds))
+	for _, itemId := range itemIds {
+		item, ok := items[itemId]
+		if !ok {
+			return model.Todo{}, errors.New("itemIds must list every checklist item once")
+		}
+		delete(items, itemId)
+		ordered = append(ordered, item)
+	}
+
+	after, err := s.repo.ReorderChecklist(ctx, todoId, userId, ordered)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	return s.checklistChanged(ctx, before, after, userId)
+}
+
+func (s *todoService) DeleteChecklistItem(ctx context.Context, todoId string, userId string, itemId string) (model.Todo, error) {
+	before, err := s.repo.GetTodo(ctx, todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	after, err := s.repo.DeleteChecklistItem(ctx, todoId, userId, itemId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	return s.checklistChanged(ctx, before, after, userId)
+}
+
+// ListSubtasks returns the subtasks of todoId, oldest first
+func (s *todoService) ListSubtasks(ctx context.Context, todoId string, userId string) ([]model.Todo, error) {
+	if _, err := s.repo.GetTodo(ctx, todoId, userId); err != nil {
+		return nil, err
+	}
+
+	children, err := s.repo.ListChildren(ctx, todoId, userId)
+	if err != nil {
+		return nil, err
+	}
+	// subtasks have no subtasks, only their checklist counts
+	for i := range children {
+		children[i].Progress = progressOf(children[i], model.TodoProgress{})
+	}
+	return children, nil
+}
+
+// prepareSubtask checks the parent of a new subtask: same owner and workspace, not a subtask itself
+func (s *todoService) prepareSubtask(ctx context.Context, todo model.Todo, workspaceId string, userId string) error {
+	parent, err := s.repo.GetTodo(ctx, todo.ParentId.Hex(), userId)
+	if err != nil {
+		return err
+	}
+	if parent.WorkspaceId.Hex() != workspaceId {
+		return errors.New("a subtask must be in the workspace of its parent")
+	}
+	if parent.ParentId != nil {
+		return errors.New("subtasks can't have subtasks")
+	}
+	return nil
+}
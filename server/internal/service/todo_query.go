@@ -0,0 +1,151 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrUnknownLabelMatch is returned for a label match other than any / all
+var ErrUnknownLabelMatch = errors.New("unknown label match, use any or all")
+
+// ErrInvalidTodoQuery wraps the problems of a todo listing query (sort, cursor, filters) for a 400
+var ErrInvalidTodoQuery = errors.New("invalid todo query")
+
+func invalidTodoQuery(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidTodoQuery, reason)
+}
+
+// todoCursorToken is what an opaque cursor holds: the sort it belongs to and the sort
+// value / id of the last todo of the page
+type todoCursorToken struct {
+	Sort string     `json:"s"`
+	Time *time.Time `json:"t,omitempty"`
+	Text *string    `json:"x,omitempty"`
+	ID   string     `json:"i"`
+}
+
+// parseTodoSort splits "-dueAt" into the field and the direction
+func parseTodoSort(sort string) (string, bool, error) {
+	field, desc := strings.CutPrefix(sort, "-")
+	switch field {
+	case model.TodoSortCreated, model.TodoSortUpdated, model.TodoSortDue, model.TodoSortTask, model.TodoSortRank:
+		return field, desc, nil
+	default:
+		return "", false, invalidTodoQuery("sort can be createdAt, updatedAt, dueAt, task or rank, - in front for descending")
+	}
+}
+
+// encodeTodoCursor describes the position of todo in a listing sorted by sort
+func encodeTodoCursor(sort string, todo model.Todo) string {
+	token := todoCursorToken{Sort: sort, ID: todo.ID.Hex()}
+	field, _, _ := parseTodoSort(sort)
+	switch field {
+	case model.TodoSortCreated:
+		token.Time = &todo.CreatedAt
+	case model.TodoSortUpdated:
+		token.Time = &todo.UpdatedAt
+	case model.TodoSortDue:
+		token.Time = todo.DueAt
+	case model.TodoSortTask:
+		token.Text = &todo.Task
+	case model.TodoSortRank:
+		if todo.Rank != "" {
+			token.Text = &todo.Rank
+		}
+	}
+
+	raw, _ := json.Marshal(token)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeTodoCursor reads a cursor made by encodeTodoCursor, it only continues the sort it came from
+func decodeTodoCursor(sort string, cursor string) (*model.TodoCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalidTodoQuery("cursor is malformed")
+	}
+	var token todoCursorToken
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return nil, invalidTodoQuery("cursor is malformed")
+	}
+	if token.Sort != sort {
+		return nil, invalidTodoQuery("cursor belongs to another sort")
+	}
+	id, err := primitive.ObjectIDFromHex(token.ID)
+	if err != nil {
+		return nil, invalidTodoQuery("cursor is malformed")
+	}
+
+	after := &model.TodoCursor{ID: id}
+	switch {
+	case token.Time != nil:
+		after.Value = *token.Time
+	case token.Text != nil:
+		after.Value = *token.Text
+	}
+	return after, nil
+}
+
+// todoQueryFilter turns what the client asked for into a TodoFilter, now is when
+// "today" / "this week" are worked out
+func todoQueryFilter(query model.TodoQuery, now time.Time) (model.TodoFilter, error) {
+	loc := query.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	filter, err := todoViewFilter(query.View, now, loc)
+	if err != nil {
+		return model.TodoFilter{}, err
+	}
+
+	if query.LabelMatch != "" && query.LabelMatch != model.LabelMatchAny && query.LabelMatch != model.LabelMatchAll {
+		return model.TodoFilter{}, ErrUnknownLabelMatch
+	}
+	filter.Labels = query.Labels
+	filter.LabelMatch = query.LabelMatch
+
+	for _, status := range query.Statuses {
+		if _, ok := todoTransitions[status]; !ok {
+			return model.TodoFilter{}, ErrUnknownStatus
+		}
+	}
+	filter.Statuses = query.Statuses
+	filter.Priorities = query.Priorities
+	filter.CreatedFrom, filter.CreatedBefore = query.CreatedFrom, query.CreatedBefore
+	filter.UpdatedFrom, filter.UpdatedBefore = query.UpdatedFrom, query.UpdatedBefore
+	filter.TaskPrefix = strings.TrimSpace(query.TaskPrefix)
+
+	// due date views keep listing by due date unless asked otherwise
+	sort := query.Sort
+	if sort == "" {
+		sort = model.TodoSortCreated
+		if filter.DueFrom != nil || filter.DueBefore != nil {
+			sort = model.TodoSortDue
+		}
+	}
+	if filter.Sort, filter.Desc, err = parseTodoSort(sort); err != nil {
+		return model.TodoFilter{}, err
+	}
+
+	// only an explicit all lists without a page size
+	if query.All {
+		if query.Limit > 0 || query.Cursor != "" {
+			return model.TodoFilter{}, invalidTodoQuery("all can't be used with limit / cursor")
+		}
+	} else {
+		_, filter.Limit, _ = pageBounds(1, query.Limit)
+	}
+	if query.Cursor != "" {
+		if filter.After, err = decodeTodoCursor(sort, query.Cursor); err != nil {
+			return model.TodoFilter{}, err
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// listingTodos are the todos of one workspace with ties on every sort key and
+// todos without due date / rank
+func listingTodos(userId primitive.ObjectID, workspaceId primitive.ObjectID) []model.Todo {
+	day := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	values := []struct {
+		created, updated int
+		due              int // -1 for none
+		task, rank       string
+	}{
+		{0, 5, 2, "Buy milk", "i"},
+		{0, 5, -1, "Buy milk", ""},
+		{1, 3, 2, "Call mom", "r"},
+		{1, 3, -1, "Buy milk", "i"},
+		{2, 5, 1, "Write report", ""},
+		{3, 1, 2, "Call mom", "r"},
+		{3, 1, -1, "Ask for a raise", "a"},
+	}
+
+	todos := make([]model.Todo, 0, len(values))
+	for _, v := range values {
+		todo := model.Todo{
+			ID:          primitive.NewObjectID(),
+			UserId:      userId,
+			WorkspaceId: workspaceId,
+			Task:        v.task,
+			Status:      model.TodoStatusNotStarted,
+			Rank:        v.rank,
+			CreatedAt:   day.Add(time.Duration(v.created) * time.Hour),
+			UpdatedAt:   day.Add(time.Duration(v.updated) * time.Hour),
+		}
+		if v.due >= 0 {
+			due := day.AddDate(0, 0, v.due)
+			todo.DueAt = &due
+		}
+		todos = append(todos, todo)
+	}
+	return todos
+}
+
+var todoSorts = []string{
+	model.TodoSortCreated, "-" + model.TodoSortCreated,
+	model.TodoSortUpdated, "-" + model.TodoSortUpdated,
+	model.TodoSortDue, "-" + model.TodoSortDue,
+	model.TodoSortTask, "-" + model.TodoSortTask,
+	model.TodoSortRank, "-" + model.TodoSortRank,
+}
+
+func TestTodoCursorRoundTrip(t *testing.T) {
+	todos := listingTodos(primitive.NewObjectID(), primitive.NewObjectID())
+
+	for _, sort := range todoSorts {
+		t.Run(sort, func(t *testing.T) {
+			field, _, _ := parseTodoSort(sort)
+			for _, todo := range todos {
+				after, err := decodeTodoCursor(sort, encodeTodoCursor(sort, todo))
+				if err != nil {
+					t.Fatalf("decodeTodoCursor() error = %v", err)
+				}
+				if after.ID != todo.ID || compareSortValues(after.Value, todoSortValue(todo, field)) != 0 {
+					t.Fatalf("cursor of %s = %+v, want %v", todo.ID.Hex(), after, todoSortValue(todo, field))
+				}
+			}
+		})
+	}
+}
+
+func TestTodoCursorRejects(t *testing.T) {
+	todo := listingTodos(primitive.NewObjectID(), primitive.NewObjectID())[0]
+	cursor := encodeTodoCursor(model.TodoSortDue, todo)
+
+	tests := []struct {
+		name   string
+		sort   string
+		cursor string
+	}{
+		{name: "another sort", sort: model.TodoSortTask, cursor: cursor},
+		{name: "the other direction", sort: "-" + model.TodoSortDue, cursor: cursor},
+		{name: "not base64", sort: model.TodoSortDue, cursor: "%%%"},
+		{name: "not json", sort: model.TodoSortDue, cursor: "bm90IGpzb24"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeTodoCursor(tt.sort, tt.cursor); !errors.Is(err, ErrInvalidTodoQuery) {
+				t.Fatalf("decodeTodoCursor() error = %v, want ErrInvalidTodoQuery", err)
+			}
+		})
+	}
+}
+
+func TestTodoQueryPageSize(t *testing.T) {
+	cursor := encodeTodoCursor(model.TodoSortCreated, listingTodos(primitive.NewObjectID(), primitive.NewObjectID())[0])
+
+	tests := []struct {
+		name      string
+		query     model.TodoQuery
+		wantLimit int64
+		wantErr   bool
+	}{
+		{name: "default page size", query: model.TodoQuery{}, wantLimit: defaultPageSize},
+		{name: "cursor alone", query: model.TodoQuery{Cursor: cursor}, wantLimit: defaultPageSize},
+		{name: "limit", query: model.TodoQuery{Limit: 10}, wantLimit: 10},
+		{name: "limit over the max", query: model.TodoQuery{Limit: 100000}, wantLimit: maxPageSize},
+		{name: "all", query: model.TodoQuery{All: true}, wantLimit: 0},
+		{name: "all with a limit", query: model.TodoQuery{All: true, Limit: 10}, wantErr: true},
+		{name: "all with a cursor", query: model.TodoQuery{All: true, Cursor: cursor}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := todoQueryFilter(tt.query, time.Now())
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTodoQuery) {
+					t.Fatalf("todoQueryFilter() error = %v, want ErrInvalidTodoQuery", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("todoQueryFilter() error = %v", err)
+			}
+			if filter.Limit != tt.wantLimit {
+				t.Fatalf("limit = %d, want %d", filter.Limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestGetSpecificTodoPages(t *testing.T) {
+	userId, workspaceId := primitive.NewObjectID(), primitive.NewObjectID()
+	todos := listingTodos(userId, workspaceId)
+	repo := newFakeTodoRepo(todos...)
+	// a todo of another user in the same workspace id never shows up
+	repo.todos[primitive.NewObjectID()] = model.Todo{ID: primitive.NewObjectID(), UserId: primitive.NewObjectID(), WorkspaceId: workspaceId, Task: "Buy milk"}
+	workspaces := &fakeWorkspaceRepo{owners: map[string]string{workspaceId.Hex(): userId.Hex()}}
+	service := &todoService{repo: repo, workspaceRepo: workspaces, audit: &fakeAudit{}}
+	ctx := context.Background()
+
+	for _, sort := range todoSorts {
+		for _, limit := range []int64{1, 2, 3} {
+			t.Run(fmt.Sprintf("%s limit %d", sort, limit), func(t *testing.T) {
+				all, err := service.GetSpecificTodo(ctx, workspaceId.Hex(), userId.Hex(), model.TodoQuery{Sort: sort, All: true})
+				if err != nil {
+					t.Fatalf("GetSpecificTodo(all) error = %v", err)
+				}
+				if len(all.Items) != len(todos) || all.NextCursor != "" {
+					t.Fatalf("GetSpecificTodo(all) = %d todos, cursor %q", len(all.Items), all.NextCursor)
+				}
+
+				// walking the pages lists every todo once, in the order of the full listing
+				var walked []model.Todo
+				cursor := ""
+				for pages := 0; ; pages++ {
+					if pages > len(todos) {
+						t.Fatalf("no end after %d pages", pages)
+					}
+					page, err := service.GetSpecificTodo(ctx, workspaceId.Hex(), userId.Hex(), model.TodoQuery{Sort: sort, Limit: limit, Cursor: cursor})
+					if err != nil {
+						t.Fatalf("GetSpecificTodo() error = %v", err)
+					}
+					if int64(len(page.Items)) > limit {
+						t.Fatalf("page of %d todos, limit %d", len(page.Items), limit)
+					}
+					walked = append(walked, page.Items...)
+					if page.NextCursor == "" {
+						break
+					}
+					cursor = page.NextCursor
+				}
+
+				if len(walked) != len(all.Items) {
+					t.Fatalf("walked %d todos, want %d", len(walked), len(all.Items))
+				}
+				for i := range walked {
+					if walked[i].ID != all.Items[i].ID {
+						t.Fatalf("todo %d is %s, want %s", i, walked[i].ID.Hex(), all.Items[i].ID.Hex())
+					}
+				}
+			})
+		}
+	}
+
+	// an empty workspace lists no items, not null
+	empty := primitive.NewObjectID()
+	workspaces.owners[empty.Hex()] = userId.Hex()
+	page, err := service.GetSpecificTodo(ctx, empty.Hex(), userId.Hex(), model.TodoQuery{})
+	if err != nil {
+		t.Fatalf("GetSpecificTodo(empty) error = %v", err)
+	}
+	if body, _ := json.Marshal(page); !strings.Contains(string(body), `"items":[]`) {
+		t.Fatalf("GetSpecificTodo(empty) = %s, want empty items", body)
+	}
+
+	// the workspace of another user is not found
+	if _, err := service.GetSpecificTodo(ctx, workspaceId.Hex(), primitive.NewObjectID().Hex(), model.TodoQuery{}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetSpecificTodo(other user) error = %v, want ErrNotFound", err)
+	}
+}
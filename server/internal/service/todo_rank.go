@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+)
+
+// ranks are strings of base 36 digits compared as plain strings, there is always room
+// between two of them so a move only rewrites the moved todo
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// maxRankLength is where a column gets rebalanced, moving a todo into the same gap over
+// and over makes ranks about one digit longer every five moves
+const maxRankLength = 12
+
+// ErrInvalidMove wraps the problems of a move request (no neighbour, another column) for a 400
+var ErrInvalidMove = errors.New("invalid move")
+
+// errRankOrder means two ranks leave no room between them (same rank twice, a malformed rank),
+// the column has to be rebalanced first
+var errRankOrder = errors.New("ranks out of order")
+
+func invalidMove(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidMove, reason)
+}
+
+// validRank is a rank this file could have made, it never ends in the lowest digit
+// so there is always room before it
+func validRank(rank string) bool {
+	if rank == "" || rank[len(rank)-1] == rankDigits[0] {
+		return false
+	}
+	for i := 0; i < len(rank); i++ {
+		if strings.IndexByte(rankDigits, rank[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rankBetween makes a rank sorting after lower and before upper, "" leaves that side open
+func rankBetween(lower string, upper string) (string, error) {
+	if (lower != "" && !validRank(lower)) || (upper != "" && !validRank(upper)) {
+		return "", errRankOrder
+	}
+	if upper != "" && lower >= upper {
+		return "", errRankOrder
+	}
+
+	digitAt := func(rank string, i int) int {
+		if i < len(rank) {
+			return strings.IndexByte(rankDigits, rank[i])
+		}
+		return 0
+	}
+
+	rank := []byte{}
+	bounded := upper != ""
+	for i := 0; ; i++ {
+		lo, hi := digitAt(lower, i), len(rankDigits)
+		if bounded {
+			hi = digitAt(upper, i)
+		}
+
+		switch {
+		case lo == hi:
+			rank = append(rank, rankDigits[lo])
+		case hi-lo > 1:
+			return string(append(rank, rankDigits[(lo+hi)/2])), nil
+		default:
+			// neighbouring digits, keep lo and anything after it is below upper
+			rank = append(rank, rankDigits[lo])
+			bounded = false
+		}
+	}
+}
+
+// spreadRanks makes n evenly spaced ranks of the same length, short enough to leave room
+// for plenty of moves between each two
+func spreadRanks(n int) []string {
+	base := int64(len(rankDigits))
+	width, space := 1, base
+	for space < int64(n+1)*base*base && width < 11 {
+		width++
+		space *= base
+	}
+	step := space / int64(n+1)
+
+	ranks := make([]string, n)
+	for i := range ranks {
+		value := step * int64(i+1)
+		digits := make([]byte, width)
+		for d := width - 1; d >= 0; d-- {
+			digits[d] = rankDigits[value%base]
+			value /= base
+		}
+		ranks[i] = strings.TrimRight(string(digits), rankDigits[:1])
+	}
+	return ranks
+}
+
+// rankAtEnd is a rank after every todo of the column, where new and re-columned todos go
+func (s *todoService) rankAtEnd(ctx context.Context, userId string, workspaceId string, status string) (string, error) {
+	last, err := s.repo.LastRank(ctx, userId, workspaceId, status)
+	if err != nil {
+		return "", err
+	}
+	rank, err := rankBetween(last, "")
+	if !errors.Is(err, errRankOrder) {
+		return rank, err
+	}
+
+	// a malformed last rank, fresh ranks always leave room after them
+	if err := s.rebalanceColumn(ctx, userId, workspaceId, status); err != nil {
+		return "", err
+	}
+	if last, err = s.repo.LastRank(ctx, userId, workspaceId, status); err != nil {
+		return "", err
+	}
+	return rankBetween(last, "")
+}
+
+// rebalanceColumn gives every todo of the column a fresh, short rank keeping their order,
+// unranked todos (from before ranks) get theirs here too
+func (s *todoService) rebalanceColumn(ctx context.Context, userId string, workspaceId string, status string) error {
+	todos, err := s.repo.ListColumn(ctx, userId, workspaceId, status)
+	if err != nil {
+		return err
+	}
+
+	ranks := spreadRanks(len(todos))
+	changes := make([]model.RankChange, 0, len(todos))
+	for i, todo := range todos {
+		if todo.Rank != ranks[i] {
+			changes = append(changes, model.RankChange{ID: todo.ID, From: todo.Rank, To: ranks[i]})
+		}
+	}
+	return s.repo.SetRanks(ctx, userId, status, changes)
+}
+
+// rebalanceLater rebalances a column off the request, a move only has to be fast
+func (s *todoService) rebalanceLater(userId string, workspaceId string, status string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.rebalanceColumn(ctx, userId, workspaceId, status); err != nil {
+			log.Printf("rank rebalance of %s / %s failed: %v", workspaceId, status, err)
+		}
+	}()
+}
+
+// moveRank works out the rank between the two neighbours of a move, beforeTodo is the todo
+// the moved one goes before (nil when only after was given) and afterTodo the one it follows
+func (s *todoService) moveRank(ctx context.Context, todo model.Todo, beforeTodo *model.Todo, afterTodo *model.Todo, userId string) (string, error) {
+	workspaceId := todo.WorkspaceId.Hex()
+	lower, upper := "", ""
+	var err error
+
+	if afterTodo != nil {
+		if afterTodo.Rank == "" {
+			return "", errRankOrder
+		}
+		lower = afterTodo.Rank
+	}
+	if beforeTodo != nil {
+		if beforeTodo.Rank == "" {
+			return "", errRankOrder
+		}
+		upper = beforeTodo.Rank
+	}
+
+	if afterTodo == nil {
+		lower, err = s.repo.NeighbourRank(ctx, userId, workspaceId, todo.Status, upper, false, todo.ID.Hex())
+	} else if beforeTodo == nil {
+		upper, err = s.repo.NeighbourRank(ctx, userId, workspaceId, todo.Status, lower, true, todo.ID.Hex())
+	}
+	if err != nil {
+		return "", err
+	}
+	return rankBetween(lower, upper)
+}
+
+// MoveTodo places a todo before the todo beforeId and / or after afterId, both have to be in
+// its status column, only the rank of the moved todo is written
+func (s *todoService) MoveTodo(ctx context.Context, todoId string, userId string, beforeId string, afterId string) (model.Todo, error) {
+	if beforeId == "" && afterId == "" {
+		return model.Todo{}, invalidMove("before or after is required")
+	}
+	if beforeId == todoId || afterId == todoId {
+		return model.Todo{}, invalidMove("a todo can't be moved next to itself")
+	}
+
+	todo, err := s.repo.GetTodo(ctx, todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	// neighbours are loaded again after a rebalance, their ranks changed
+	neighbour := func(id string) (*model.Todo, error) {
+		if id == "" {
+			return nil, nil
+		}
+		other, err := s.repo.GetTodo(ctx, id, userId)
+		if err != nil {
+			return nil, err
+		}
+		if other.WorkspaceId != todo.WorkspaceId || other.Status != todo.Status {
+			return nil, invalidMove("before / after have to be in the same status column")
+		}
+		return &other, nil
+	}
+
+	var rank string
+	for attempt := 0; attempt < 2; attempt++ {
+		beforeTodo, err := neighbour(beforeId)
+		if err != nil {
+			return model.Todo{}, err
+		}
+		afterTodo, err := neighbour(afterId)
+		if err != nil {
+			return model.Todo{}, err
+		}
+
+		rank, err = s.moveRank(ctx, todo, beforeTodo, afterTodo, userId)
+		if !errors.Is(err, errRankOrder) {
+			if err != nil {
+				return model.Todo{}, err
+			}
+			break
+		}
+		if attempt == 1 {
+			return model.Todo{}, invalidMove("the after todo has to be above the before todo")
+		}
+		if err := s.rebalanceColumn(ctx, userId, todo.WorkspaceId.Hex(), todo.Status); err != nil {
+			return model.Todo{}, err
+		}
+	}
+
+	moved, err := s.repo.SetRank(ctx, todoId, userId, rank)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	if len(rank) > maxRankLength {
+		s.rebalanceLater(userId, todo.WorkspaceId.Hex(), todo.Status)
+	}
+	return s.withTodoProgress(ctx, moved, userId)
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		name         string
+		lower, upper string
+		want         string
+		wantErr      bool
+	}{
+		{name: "empty column", want: "i"},
+		{name: "after the last", lower: "i", want: "r"},
+		{name: "before the first", upper: "i", want: "9"},
+		{name: "room at the first digit", lower: "a", upper: "k", want: "f"},
+		{name: "adjacent keys", lower: "a", upper: "b", want: "ai"},
+		{name: "adjacent keys of different length", lower: "ai", upper: "aj", want: "aii"},
+		{name: "a prefix of upper", lower: "a", upper: "a1", want: "a0i"},
+		{name: "after the highest digit", lower: "z", want: "zi"},
+		{name: "before the lowest rank", upper: "01", want: "00i"},
+		{name: "same rank twice", lower: "i", upper: "i", wantErr: true},
+		{name: "out of order", lower: "k", upper: "a", wantErr: true},
+		{name: "ending in the lowest digit", lower: "a0", wantErr: true},
+		{name: "not a rank digit", upper: "A", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rankBetween(tt.lower, tt.upper)
+			if tt.wantErr {
+				if !errors.Is(err, errRankOrder) {
+					t.Fatalf("rankBetween() = %q, %v, want errRankOrder", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("rankBetween() = %q, %v, want %q", got, err, tt.want)
+			}
+			if !validRank(got) || got <= tt.lower || (tt.upper != "" && got >= tt.upper) {
+				t.Fatalf("rankBetween() = %q is not between %q and %q", got, tt.lower, tt.upper)
+			}
+		})
+	}
+}
+
+func TestRankBetweenAtEitherEnd(t *testing.T) {
+	first, last := "i", "i"
+	for i := 0; i < 200; i++ {
+		top, err := rankBetween("", first)
+		if err != nil || !validRank(top) || top >= first {
+			t.Fatalf("insert %d at the top: %q, %v after %q", i, top, err, first)
+		}
+		bottom, err := rankBetween(last, "")
+		if err != nil || !validRank(bottom) || bottom <= last {
+			t.Fatalf("insert %d at the bottom: %q, %v after %q", i, bottom, err, last)
+		}
+		first, last = top, bottom
+	}
+}
+
+func TestRankBetweenSameSpot(t *testing.T) {
+	// every insert goes right below "a", between it and the todo inserted before
+	lower, upper := "a", "b"
+	inserts := 0
+	for len(upper) <= maxRankLength {
+		rank, err := rankBetween(lower, upper)
+		if err != nil {
+			t.Fatalf("insert %d: %v", inserts, err)
+		}
+		if !validRank(rank) || rank <= lower || rank >= upper {
+			t.Fatalf("insert %d: %q is not between %q and %q", inserts, rank, lower, upper)
+		}
+		upper = rank
+		inserts++
+	}
+
+	// ranks grow by about a digit every five inserts, a rebalance is rare
+	if inserts < 5*(maxRankLength-2) {
+		t.Fatalf("a rank got longer than %d after %d inserts", maxRankLength, inserts)
+	}
+}
+
+func TestSpreadRanks(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 35, 36, 1000, 50000} {
+		ranks := spreadRanks(n)
+		if len(ranks) != n {
+			t.Fatalf("spreadRanks(%d) made %d ranks", n, len(ranks))
+		}
+		for i, rank := range ranks {
+			if !validRank(rank) || len(rank) > maxRankLength/2 {
+				t.Fatalf("spreadRanks(%d)[%d] = %q", n, i, rank)
+			}
+			if i == 0 {
+				continue
+			}
+			if rank <= ranks[i-1] {
+				t.Fatalf("spreadRanks(%d) out of order at %d: %q after %q", n, i, rank, ranks[i-1])
+			}
+			if _, err := rankBetween(ranks[i-1], rank); err != nil {
+				t.Fatalf("spreadRanks(%d) has no room between %q and %q", n, ranks[i-1], rank)
+			}
+		}
+	}
+}
+
+// testColumn is one status column of a workspace with the ranks given, in that order
+type testColumn struct {
+	repo    *fakeTodoRepo
+	service *todoService
+	userId  string
+	ids     []primitive.ObjectID
+}
+
+func newTestColumn(ranks ...string) *testColumn {
+	userId := primitive.NewObjectID()
+	workspaceId := primitive.NewObjectID()
+	column := &testColumn{repo: newFakeTodoRepo(), userId: userId.Hex()}
+	for _, rank := range ranks {
+		todo := model.Todo{ID: primitive.NewObjectID(), UserId: userId, WorkspaceId: workspaceId, Status: model.TodoStatusNotStarted, Rank: rank}
+		column.repo.todos[todo.ID] = todo
+		column.ids = append(column.ids, todo.ID)
+	}
+	column.service = &todoService{repo: column.repo, audit: &fakeAudit{}}
+	return column
+}
+
+func (c *testColumn) id(i int) string {
+	if i < 0 {
+		return ""
+	}
+	return c.ids[i].Hex()
+}
+
+// order lists the indexes of the todos as the column sorts them now
+func (c *testColumn) order() []int {
+	index := map[primitive.ObjectID]int{}
+	for i, id := range c.ids {
+		index[id] = i
+	}
+	var order []int
+	for _, todo := range c.repo.column(c.userId, c.repo.get(c.ids[0]).WorkspaceId.Hex(), model.TodoStatusNotStarted) {
+		order = append(order, index[todo.ID])
+	}
+	return order
+}
+
+func TestMoveTodo(t *testing.T) {
+	tests := []struct {
+		name  string
+		ranks []string
+		// move todo, before / after are indexes, -1 leaves them out
+		todo, before, after int
+		want                []int
+		rebalanced          bool
+	}{
+		{name: "between two", ranks: []string{"a", "k", "t"}, todo: 2, before: 1, after: 0, want: []int{0, 2, 1}},
+		{name: "to the top", ranks: []string{"a", "k", "t"}, todo: 2, before: 0, after: -1, want: []int{2, 0, 1}},
+		{name: "to the bottom", ranks: []string{"a", "k", "t"}, todo: 0, before: -1, after: 2, want: []int{1, 2, 0}},
+		{name: "after, neighbour looked up", ranks: []string{"a", "k", "t"}, todo: 2, before: -1, after: 0, want: []int{0, 2, 1}},
+		{name: "before, neighbour looked up", ranks: []string{"a", "k", "t"}, todo: 0, before: 2, after: -1, want: []int{1, 0, 2}},
+		{name: "neighbours with the same rank", ranks: []string{"i", "i", "t"}, todo: 2, before: 1, after: 0, want: []int{0, 2, 1}, rebalanced: true},
+		{name: "unranked neighbours", ranks: []string{"", "", "t"}, todo: 2, before: 1, after: 0, want: []int{0, 2, 1}, rebalanced: true},
+		{name: "a malformed rank", ranks: []string{"a", "k0", "t"}, todo: 2, before: 1, after: 0, want: []int{0, 2, 1}, rebalanced: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			column := newTestColumn(tt.ranks...)
+			moved, err := column.service.MoveTodo(context.Background(), column.id(tt.todo), column.userId, column.id(tt.before), column.id(tt.after))
+			if err != nil {
+				t.Fatalf("MoveTodo() error = %v", err)
+			}
+			if !validRank(moved.Rank) {
+				t.Fatalf("MoveTodo() rank = %q", moved.Rank)
+			}
+			if got := column.order(); !equalInts(got, tt.want) {
+				t.Fatalf("column order = %v, want %v", got, tt.want)
+			}
+			if rebalanced := column.repo.rebalances() > 0; rebalanced != tt.rebalanced {
+				t.Fatalf("rebalanced = %v, want %v", rebalanced, tt.rebalanced)
+			}
+		})
+	}
+}
+
+func TestMoveTodoInvalid(t *testing.T) {
+	column := newTestColumn("a", "k", "t")
+	other := newTestColumn("a")
+	otherTodo := other.repo.get(other.ids[0])
+	// same owner, another workspace
+	otherTodo.UserId = column.repo.get(column.ids[0]).UserId
+	column.repo.todos[otherTodo.ID] = otherTodo
+
+	tests := []struct {
+		name          string
+		todo          string
+		before, after string
+	}{
+		{name: "no neighbour", todo: column.id(0)},
+		{name: "next to itself", todo: column.id(0), before: column.id(0)},
+		{name: "after below before, even after a rebalance", todo: column.id(1), before: column.id(0), after: column.id(2)},
+		{name: "another column", todo: column.id(0), before: otherTodo.ID.Hex()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := column.service.MoveTodo(context.Background(), tt.todo, column.userId, tt.before, tt.after); !errors.Is(err, ErrInvalidMove) {
+				t.Fatalf("MoveTodo() error = %v, want ErrInvalidMove", err)
+			}
+		})
+	}
+}
+
+func TestMoveTodoRebalancesLongRanks(t *testing.T) {
+	column := newTestColumn("a", "b", "t")
+	ctx := context.Background()
+
+	// keep moving the last todo in right below the first one, the gap narrows every time
+	for i := 0; column.repo.rebalances() == 0; i++ {
+		if i > 200 {
+			t.Fatalf("no rebalance after %d moves", i)
+		}
+		target, inGap := 2, 1
+		if i%2 == 1 {
+			target, inGap = 1, 2
+		}
+		moved, err := column.service.MoveTodo(ctx, column.id(target), column.userId, column.id(inGap), column.id(0))
+		if err != nil {
+			t.Fatalf("move %d: %v", i, err)
+		}
+		if len(moved.Rank) > maxRankLength {
+			// the rebalance runs off the request, give it a moment
+			deadline := time.Now().Add(5 * time.Second)
+			for column.repo.rebalances() == 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	}
+
+	for _, id := range column.ids {
+		if rank := column.repo.get(id).Rank; len(rank) > maxRankLength/2 {
+			t.Fatalf("rank %q is still long after the rebalance", rank)
+		}
+	}
+}
+
+func equalInts(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -4,74 +4,492 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/ndk123-web/fast-todo/internal/model"
 	"github.com/ndk123-web/fast-todo/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // TodoService defines the interface for todo business logic operations
 type TodoService interface {
-	GetTodos(ctx context.Context) ([]model.Todo, error)
 	CreateTodo(ctx context.Context, todo model.Todo, workspaceId string, userId string) (model.Todo, error)
-	UpdateTodo(ctx context.Context, todoId string, updatedTask string, priority string) (model.Todo, error)
-	DeleteTodo(ctx context.Context, todoId string) (bool, error)
-	GetSpecificTodo(ctx context.Context, workspaceId string, userId string) ([]model.Todo, error)
-	ToggleTodo(ctx context.Context, todoId string, toggle string, userId string) (bool, error)
+	UpdateTodo(ctx context.Context, todoId string, update model.TodoUpdate, userId string) (model.Todo, error)
+	DeleteTodo(ctx context.Context, todoId string, userId string) (bool, error)
+	GetSpecificTodo(ctx context.Context, workspaceId string, userId string, query model.TodoQuery) (CursorPage[model.Todo], error)
+	ToggleTodo(ctx context.Context, todoId string, toggle string, userId string) (model.Todo, error)
+	TransitionTodo(ctx context.Context, todoId string, status string, userId string) (model.Todo, error)
 	AnalyticsOfTodos(ctx context.Context, year string, userId string, workspaceId string) (any, error)
+	SkipOccurrence(ctx context.Context, todoId string, userId string) (model.Todo, error)
+	AddException(ctx context.Context, todoId string, userId string, occurrence time.Time) error
+	AddChecklistItem(ctx context.Context, todoId string, userId string, text string) (model.Todo, error)
+	UpdateChecklistItem(ctx context.Context, todoId string, userId string, itemId string, text *string, done *bool) (model.Todo, error)
+	ReorderChecklist(ctx context.Context, todoId string, userId string, itemIds []string) (model.Todo, error)
+	DeleteChecklistItem(ctx context.Context, todoId string, userId string, itemId string) (model.Todo, error)
+	ListSubtasks(ctx context.Context, todoId string, userId string) ([]model.Todo, error)
+	MoveTodo(ctx context.Context, todoId string, userId string, beforeId string, afterId string) (model.Todo, error)
+	MoveTodos(ctx context.Context, todoIds []string, workspaceId string, userId string) (model.TodoTransfer, error)
+	CopyTodos(ctx context.Context, todoIds []string, workspaceId string, userId string) (model.TodoTransfer, error)
 }
 
 // todoService implements TodoService with a repository layer dependency
 type todoService struct {
-	repo repository.TodoRepository // Repository for data access
+	repo          repository.TodoRepository      // Repository for data access
+	workspaceRepo repository.WorkSpaceRepository // Used to check workspace ownership
+	labelRepo     repository.LabelRepository     // Labels of a todo have to be in the workspace catalog
+	audit         AuditService                   // Records creates / updates / deletes
+	tx            repository.Transactor          // Makes a move of several todos all or nothing
 }
 
-// NewTodoService creates a new instance of TodoService with the provided repository
-func NewTodoService(repo repository.TodoRepository) TodoService {
-	return &todoService{repo: repo}
+// NewTodoService creates a new instance of TodoService with the provided repositories
+func NewTodoService(repo repository.TodoRepository, workspaceRepo repository.WorkSpaceRepository, labelRepo repository.LabelRepository, audit AuditService, tx repository.Transactor) TodoService {
+	return &todoService{repo: repo, workspaceRepo: workspaceRepo, labelRepo: labelRepo, audit: audit, tx: tx}
 }
 
-// GetTodos retrieves all todo items from the repository
-func (s *todoService) GetTodos(ctx context.Context) ([]model.Todo, error) {
-	return s.repo.GetAll(ctx)
+// ensureWorkspaceOwner returns ErrNotFound unless workspaceId belongs to userId
+func (s *todoService) ensureWorkspaceOwner(ctx context.Context, userId string, workspaceId string) error {
+	ok, err := s.workspaceRepo.IsWorkspaceOwner(ctx, userId, workspaceId)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// ErrInvalidTodoUpdate wraps the problems of an update body (a blank task) for a 400
+var ErrInvalidTodoUpdate = errors.New("invalid todo update")
+
+// ErrUnknownTodoView is returned for a view GetSpecificTodo doesn't know
+var ErrUnknownTodoView = errors.New("unknown view, use overdue, today, week or no-date")
+
+// validateTodoDates refuses a start after the due date, either date may be missing
+func validateTodoDates(startAt *time.Time, dueAt *time.Time) error {
+	if startAt != nil && dueAt != nil && startAt.After(*dueAt) {
+		return errors.New("startAt can't be after dueAt")
+	}
+	return nil
 }
 
-func (s *todoService) ToggleTodo(ctx context.Context, todoId string, toggle string, userId string) (bool, error) {
-	if todoId == "" || toggle == "" || userId == "" {
-		return false, errors.New("Something is missing from userId,todoId,toggle in service")
+// todoViewFilter turns a view into a due date range, "today" and "week" are the calendar
+// day / ISO week (monday to sunday) of now in loc, so the client sends its time zone
+func todoViewFilter(view string, now time.Time, loc *time.Location) (model.TodoFilter, error) {
+	now = now.In(loc)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch view {
+	case "":
+		return model.TodoFilter{}, nil
+	case model.TodoViewOverdue:
+		return model.TodoFilter{DueBefore: &now, OpenOnly: true}, nil
+	case model.TodoViewToday:
+		end := startOfDay.AddDate(0, 0, 1)
+		return model.TodoFilter{DueFrom: &startOfDay, DueBefore: &end}, nil
+	case model.TodoViewWeek:
+		// time.Weekday starts on sunday, the week here starts on monday
+		startOfWeek := startOfDay.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+		end := startOfWeek.AddDate(0, 0, 7)
+		return model.TodoFilter{DueFrom: &startOfWeek, DueBefore: &end}, nil
+	case model.TodoViewNoDate:
+		return model.TodoFilter{NoDueDate: true}, nil
+	default:
+		return model.TodoFilter{}, ErrUnknownTodoView
 	}
-	// Delegate to repository to actually update the DB
-	return s.repo.ToggleTodo(ctx, todoId, toggle, userId)
+}
+
+// createNextInstance adds the instance after todo to its workspace, once per instance
+func (s *todoService) createNextInstance(ctx context.Context, todo model.Todo, userId string) error {
+	next, ok, err := nextInstance(todo)
+	if err != nil || !ok {
+		return err
+	}
+
+	claimed, err := s.repo.ClaimNextInstance(ctx, todo.ID.Hex(), userId)
+	if err != nil || !claimed {
+		return err
+	}
+	if next.Rank, err = s.rankAtEnd(ctx, userId, todo.WorkspaceId.Hex(), next.Status); err != nil {
+		return err
+	}
+
+	created, err := s.repo.CreateTodo(ctx, next, todo.WorkspaceId.Hex(), userId)
+	if err != nil {
+		// give the claim back so completing the todo again retries
+		released := *todo.Recurrence
+		released.NextCreated = false
+		s.repo.SetRecurrence(ctx, todo.ID.Hex(), userId, &released)
+		return err
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditTodoCreate, userId, "todo", created.ID.Hex(), nil, todoSummary(created)))
+	return nil
 }
 
 // CreateTodo adds a new todo item through the repository
 func (s *todoService) CreateTodo(ctx context.Context, todo model.Todo, workspaceId string, userId string) (model.Todo, error) {
-	return s.repo.CreateTodo(ctx, todo, workspaceId, userId)
+	if err := s.ensureWorkspaceOwner(ctx, userId, workspaceId); err != nil {
+		return model.Todo{}, err
+	}
+	if err := validateTodoDates(todo.StartAt, todo.DueAt); err != nil {
+		return model.Todo{}, err
+	}
+
+	// clients only send rrule / timeZone, the todo becomes the first instance of its series
+	if todo.Recurrence != nil {
+		todo.ID = primitive.NewObjectID()
+		recurrence, err := newRecurrence(todo, todo.Recurrence.RRule, todo.Recurrence.TimeZone)
+		if err != nil {
+			return model.Todo{}, err
+		}
+		todo.Recurrence = recurrence
+	}
+
+	if todo.ParentId != nil {
+		if err := s.prepareSubtask(ctx, todo, workspaceId, userId); err != nil {
+			return model.Todo{}, err
+		}
+	}
+
+	labels, err := checkTodoLabels(ctx, s.labelRepo, userId, workspaceId, todo.Labels)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	todo.Labels = labels
+
+	// a todo may start in any status, done for clients that only send done
+	if todo.Status == "" && todo.Done {
+		todo.Status = model.TodoStatusDone
+	}
+	if todo.Status == "" {
+		todo.Status = model.TodoStatusNotStarted
+	}
+	if _, ok := todoTransitions[todo.Status]; !ok {
+		return model.Todo{}, ErrUnknownStatus
+	}
+	change := statusChange(model.Todo{}, todo.Status, time.Now())
+	todo.StartedAt, todo.CompletedAt = change.StartedAt, change.CompletedAt
+
+	// new todos go to the bottom of their column, clients don't pick ranks
+	if todo.Rank, err = s.rankAtEnd(ctx, userId, workspaceId, todo.Status); err != nil {
+		return model.Todo{}, err
+	}
+
+	// items sent with the todo only bring their text / done, ids are given here
+	if len(todo.Checklist) > maxChecklistItems {
+		return model.Todo{}, errors.New("a checklist holds at most 100 items")
+	}
+	for i, sent := range todo.Checklist {
+		item, err := newChecklistItem(sent.Text)
+		if err != nil {
+			return model.Todo{}, err
+		}
+		item.Done = sent.Done
+		todo.Checklist[i] = item
+	}
+
+	created, err := s.repo.CreateTodo(ctx, todo, workspaceId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditTodoCreate, userId, "todo", created.ID.Hex(), nil, todoSummary(created)))
+
+	// a new open subtask reopens an auto completed parent
+	if created.ParentId != nil {
+		if err := s.syncParent(ctx, *created.ParentId, userId); err != nil {
+			return model.Todo{}, err
+		}
+	}
+	created.Progress = progressOf(created, model.TodoProgress{})
+	if err := s.syncAutoComplete(ctx, &created, userId); err != nil {
+		return model.Todo{}, err
+	}
+	return created, nil
 }
 
-// UpdateTodo modifies an existing todo's task through the repository
-func (s *todoService) UpdateTodo(ctx context.Context, todoId string, updatedTask string, priority string) (model.Todo, error) {
-	return s.repo.UpdateTodo(ctx, todoId, updatedTask, priority)
+// UpdateTodo modifies an existing todo's task, priority and dates through the repository
+// for a repeating todo, scope "future" also changes the instances after it and the ones
+// still to come, a new rule always applies from this instance on
+func (s *todoService) UpdateTodo(ctx context.Context, todoId string, update model.TodoUpdate, userId string) (model.Todo, error) {
+	// task and priority may be left out but not blanked
+	if update.Task != nil && strings.TrimSpace(*update.Task) == "" {
+		return model.Todo{}, fmt.Errorf("%w: task can't be empty", ErrInvalidTodoUpdate)
+	}
+	if update.Priority != nil && strings.TrimSpace(*update.Priority) == "" {
+		return model.Todo{}, fmt.Errorf("%w: priority can't be empty", ErrInvalidTodoUpdate)
+	}
+
+	before, err := s.repo.GetTodo(ctx, todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	// the dates are checked as they will be after the update, a date not sent keeps its value
+	startAt, dueAt := before.StartAt, before.DueAt
+	if update.StartAt != nil {
+		startAt = update.StartAt
+		if startAt.IsZero() {
+			startAt = nil
+		}
+	}
+	if update.DueAt != nil {
+		dueAt = update.DueAt
+		if dueAt.IsZero() {
+			dueAt = nil
+		}
+	}
+	if err := validateTodoDates(startAt, dueAt); err != nil {
+		return model.Todo{}, err
+	}
+
+	scope := update.Scope
+	if scope == "" {
+		scope = model.SeriesScopeThis
+	}
+	if scope != model.SeriesScopeThis && scope != model.SeriesScopeFuture {
+		return model.Todo{}, errors.New("scope must be this or future")
+	}
+
+	// everything is checked before the first write so a bad rule doesn't leave half an update
+	var rrule string
+	if update.RRule != nil && *update.RRule != "" {
+		if rrule, err = normalizeRRule(*update.RRule); err != nil {
+			return model.Todo{}, err
+		}
+		if _, err := seriesLocation(update.TimeZone); err != nil {
+			return model.Todo{}, err
+		}
+	}
+	repeating := rrule != "" || (before.Recurrence != nil && (update.RRule == nil || *update.RRule != ""))
+	if repeating && dueAt == nil {
+		return model.Todo{}, errors.New("repeating todos need a dueAt")
+	}
+
+	if update.Labels != nil {
+		labels, err := checkTodoLabels(ctx, s.labelRepo, userId, before.WorkspaceId.Hex(), *update.Labels)
+		if err != nil {
+			return model.Todo{}, err
+		}
+		update.Labels = &labels
+	}
+
+	updated, err := s.repo.UpdateTodo(ctx, todoId, update, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	if err := s.updateSeries(ctx, before, &updated, update, scope, rrule, userId); err != nil {
+		return model.Todo{}, err
+	}
+
+	if updated, err = s.withTodoProgress(ctx, updated, userId); err != nil {
+		return model.Todo{}, err
+	}
+	if update.AutoComplete != nil && *update.AutoComplete {
+		if err := s.syncAutoComplete(ctx, &updated, userId); err != nil {
+			return model.Todo{}, err
+		}
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditTodoUpdate, userId, "todo", todoId, todoSummary(before), todoSummary(updated)))
+	return updated, nil
+}
+
+// updateSeries applies the recurrence side of an update, updated is the todo after the plain update
+func (s *todoService) updateSeries(ctx context.Context, before model.Todo, updated *model.Todo, update model.TodoUpdate, scope string, rrule string, userId string) error {
+	todoId := updated.ID.Hex()
+
+	switch {
+	case update.RRule != nil && rrule == "":
+		// stop repeating, from this instance on
+		if before.Recurrence == nil {
+			return nil
+		}
+		if err := s.repo.SetRecurrence(ctx, todoId, userId, nil); err != nil {
+			return err
+		}
+		updated.Recurrence = nil
+		return s.repo.UpdateLaterInstances(ctx, userId, before.Recurrence.SeriesId.Hex(), before.Recurrence.Occurrence, updated.Task, updated.Priority, nil)
+
+	case rrule != "" && before.Recurrence == nil:
+		recurrence, err := newRecurrence(*updated, rrule, update.TimeZone)
+		if err != nil {
+			return err
+		}
+		updated.Recurrence = recurrence
+		return s.repo.SetRecurrence(ctx, todoId, userId, recurrence)
+
+	case before.Recurrence != nil && (rrule != "" || scope == model.SeriesScopeFuture):
+		recurrence := *before.Recurrence
+		if rrule != "" {
+			recurrence.RRule = rrule
+			if update.TimeZone != "" {
+				recurrence.TimeZone = update.TimeZone
+			}
+		}
+		// a new rule or due date starts the rule over at this instance (COUNT counts from here)
+		if rrule != "" || update.DueAt != nil {
+			recurrence.Start = *updated.DueAt
+			recurrence.Occurrence = *updated.DueAt
+		}
+		setSeriesTemplate(&recurrence, *updated)
+
+		if err := s.repo.SetRecurrence(ctx, todoId, userId, &recurrence); err != nil {
+			return err
+		}
+		updated.Recurrence = &recurrence
+		return s.repo.UpdateLaterInstances(ctx, userId, recurrence.SeriesId.Hex(), before.Recurrence.Occurrence, updated.Task, updated.Priority, &recurrence)
+	}
+
+	return nil
 }
 
-// DeleteTodo removes a todo item by ID through the repository
+// SkipOccurrence moves an instance of a repeating todo to the next occurrence, the skipped
+// one is just not done, the series goes on as if it had been
+func (s *todoService) SkipOccurrence(ctx context.Context, todoId string, userId string) (model.Todo, error) {
+	before, err := s.repo.GetTodo(ctx, todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	if before.Recurrence == nil {
+		return model.Todo{}, ErrNotRecurring
+	}
+
+	occurrence, ok, err := nextOccurrence(before.Recurrence, before.Recurrence.Occurrence)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	if !ok {
+		return model.Todo{}, ErrSeriesEnded
+	}
+
+	update := model.TodoUpdate{DueAt: &occurrence}
+	if before.StartAt != nil {
+		startAt := occurrence.Add(-before.Recurrence.StartOffset)
+		update.StartAt = &startAt
+	}
+	updated, err := s.repo.UpdateTodo(ctx, todoId, update, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+
+	recurrence := *before.Recurrence
+	recurrence.Occurrence = occurrence
+	if err := s.repo.SetRecurrence(ctx, todoId, userId, &recurrence); err != nil {
+		return model.Todo{}, err
+	}
+	updated.Recurrence = &recurrence
+
+	s.audit.Record(ctx, auditEvent(model.AuditTodoUpdate, userId, "todo", todoId, todoSummary(before), todoSummary(updated)))
+	return updated, nil
+}
+
+// AddException skips a future occurrence of the series of todoId (EXDATE), the open
+// instance itself is moved with SkipOccurrence instead
+func (s *todoService) AddException(ctx context.Context, todoId string, userId string, occurrence time.Time) error {
+	todo, err := s.repo.GetTodo(ctx, todoId, userId)
+	if err != nil {
+		return err
+	}
+	if todo.Recurrence == nil {
+		return ErrNotRecurring
+	}
+
+	if err := s.repo.AddSeriesException(ctx, userId, todo.Recurrence.SeriesId.Hex(), occurrence); err != nil {
+		return err
+	}
+
+	after := todo
+	recurrence := *todo.Recurrence
+	recurrence.Exceptions = append(recurrence.Exceptions, occurrence)
+	after.Recurrence = &recurrence
+	s.audit.Record(ctx, auditEvent(model.AuditTodoUpdate, userId, "todo", todoId, todoSummary(todo), todoSummary(after)))
+	return nil
+}
+
+// DeleteTodo removes a todo item by ID through the repository, its subtasks go with it
 // Returns true if deletion was successful, false otherwise
-func (s *todoService) DeleteTodo(ctx context.Context, todoId string) (bool, error) {
-	return s.repo.DeleteTodo(ctx, todoId)
+func (s *todoService) DeleteTodo(ctx context.Context, todoId string, userId string) (bool, error) {
+	before, err := s.repo.GetTodo(ctx, todoId, userId)
+	if err != nil {
+		return false, err
+	}
+
+	var children []model.Todo
+	if before.ParentId == nil {
+		if children, err = s.repo.ListChildren(ctx, todoId, userId); err != nil {
+			return false, err
+		}
+	}
+
+	deleted, err := s.repo.DeleteTodo(ctx, todoId, userId)
+	if err != nil {
+		return false, err
+	}
+	s.audit.Record(ctx, auditEvent(model.AuditTodoDelete, userId, "todo", todoId, todoSummary(before), nil))
+
+	if len(children) > 0 {
+		if err := s.repo.DeleteChildren(ctx, todoId, userId); err != nil {
+			return false, err
+		}
+		for _, child := range children {
+			s.audit.Record(ctx, auditEvent(model.AuditTodoDelete, userId, "todo", child.ID.Hex(), todoSummary(child), nil))
+		}
+	}
+
+	// the parent may be complete without this subtask
+	if before.ParentId != nil {
+		if err := s.syncParent(ctx, *before.ParentId, userId); err != nil {
+			return false, err
+		}
+	}
+	return deleted, nil
 }
 
-func (s *todoService) GetSpecificTodo(ctx context.Context, workspaceId string, userId string) ([]model.Todo, error) {
+// GetSpecificTodo lists the todos of a workspace, see model.TodoQuery for the filters / sort
+// a query is paged by default (Limit falls back to the default page size), only All lists
+// every todo on one page
+func (s *todoService) GetSpecificTodo(ctx context.Context, workspaceId string, userId string, query model.TodoQuery) (CursorPage[model.Todo], error) {
 	if workspaceId == "" || userId == "" {
-		return nil, errors.New("Workspace ID / UserId is empty in service")
+		return CursorPage[model.Todo]{}, errors.New("Workspace ID / UserId is empty in service")
 	}
 
-	var todos []model.Todo
-	todos, err := s.repo.GetSpecificTodo(ctx, workspaceId, userId)
+	filter, err := todoQueryFilter(query, time.Now())
+	if err != nil {
+		return CursorPage[model.Todo]{}, err
+	}
 
+	if err := s.ensureWorkspaceOwner(ctx, userId, workspaceId); err != nil {
+		return CursorPage[model.Todo]{}, err
+	}
+
+	// one more than the page tells whether there is a next one
+	limit := filter.Limit
+	if limit > 0 {
+		filter.Limit++
+	}
+	todos, err := s.repo.GetSpecificTodo(ctx, workspaceId, userId, filter)
 	if err != nil {
-		return nil, err
+		return CursorPage[model.Todo]{}, err
+	}
+
+	page := CursorPage[model.Todo]{Items: todos, Limit: limit}
+	if limit > 0 && int64(len(todos)) > limit {
+		page.Items = todos[:limit]
+		sort := query.Sort
+		if sort == "" {
+			sort = filter.Sort
+		}
+		page.NextCursor = encodeTodoCursor(sort, page.Items[limit-1])
 	}
 
-	return todos, nil
+	if err := s.withProgress(ctx, userId, page.Items); err != nil {
+		return CursorPage[model.Todo]{}, err
+	}
+	return page, nil
 }
 
 func (s *todoService) AnalyticsOfTodos(ctx context.Context, year string, userId string, workspaceId string) (any, error) {
@@ -79,5 +497,12 @@ func (s *todoService) AnalyticsOfTodos(ctx context.Context, year string, userId
 		return nil, errors.New("Year / UserId is empty in service")
 	}
 
+	// workspaceId is optional here, analytics can span every workspace of the user
+	if workspaceId != "" {
+		if err := s.ensureWorkspaceOwner(ctx, userId, workspaceId); err != nil {
+			return nil, err
+		}
+	}
+
 	return s.repo.AnalyticsOfTodos(ctx, year, userId, workspaceId)
 }
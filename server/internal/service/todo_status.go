@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+)
+
+var (
+	// ErrUnknownStatus is returned for a status that isn't one of the model.TodoStatus constants
+	ErrUnknownStatus = errors.New("unknown status, use not-started, in-progress, blocked, done or cancelled")
+	// ErrInvalidTransition is returned for a move todoTransitions doesn't allow
+	ErrInvalidTransition = errors.New("status change not allowed")
+)
+
+// todoTransitions lists where a todo may go from each status, done / cancelled todos
+// are reopened through not-started or in-progress
+var todoTransitions = map[string][]string{
+	model.TodoStatusNotStarted: {model.TodoStatusInProgress, model.TodoStatusBlocked, model.TodoStatusDone, model.TodoStatusCancelled},
+	model.TodoStatusInProgress: {model.TodoStatusNotStarted, model.TodoStatusBlocked, model.TodoStatusDone, model.TodoStatusCancelled},
+	model.TodoStatusBlocked:    {model.TodoStatusNotStarted, model.TodoStatusInProgress, model.TodoStatusCancelled},
+	model.TodoStatusDone:       {model.TodoStatusNotStarted, model.TodoStatusInProgress},
+	model.TodoStatusCancelled:  {model.TodoStatusNotStarted, model.TodoStatusInProgress},
+}
+
+func canTransition(from string, to string) bool {
+	for _, allowed := range todoTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// closedStatus is true for the statuses that end the work on a todo
+func closedStatus(status string) bool {
+	return status == model.TodoStatusDone || status == model.TodoStatusCancelled
+}
+
+// statusChange works out the dates of moving todo to status at now: startedAt is kept from
+// the first time work began, completedAt is only set while the todo is done / cancelled
+// and going back to not-started forgets both
+func statusChange(todo model.Todo, status string, now time.Time) model.StatusChange {
+	change := model.StatusChange{Status: status}
+	clear := &time.Time{}
+
+	switch status {
+	case model.TodoStatusNotStarted:
+		if todo.StartedAt != nil {
+			change.StartedAt = clear
+		}
+		if todo.CompletedAt != nil {
+			change.CompletedAt = clear
+		}
+	case model.TodoStatusInProgress, model.TodoStatusBlocked:
+		if status == model.TodoStatusInProgress && todo.StartedAt == nil {
+			change.StartedAt = &now
+		}
+		if todo.CompletedAt != nil {
+			change.CompletedAt = clear
+		}
+	case model.TodoStatusDone, model.TodoStatusCancelled:
+		change.CompletedAt = &now
+	}
+	return change
+}
+
+// TransitionTodo moves a todo to status when todoTransitions allows it, moving to the
+// status it already has changes nothing
+func (s *todoService) TransitionTodo(ctx context.Context, todoId string, status string, userId string) (model.Todo, error) {
+	if _, ok := todoTransitions[status]; !ok {
+		return model.Todo{}, ErrUnknownStatus
+	}
+
+	before, err := s.repo.GetTodo(ctx, todoId, userId)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	if before.Status == status {
+		return s.withTodoProgress(ctx, before, userId)
+	}
+	if !canTransition(before.Status, status) {
+		return model.Todo{}, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, before.Status, status)
+	}
+
+	// the todo lands at the bottom of its new column
+	change := statusChange(before, status, time.Now())
+	if change.Rank, err = s.rankAtEnd(ctx, userId, before.WorkspaceId.Hex(), status); err != nil {
+		return model.Todo{}, err
+	}
+	after, err := s.repo.SetStatus(ctx, todoId, userId, before.Status, change)
+	if err != nil {
+		return model.Todo{}, err
+	}
+	s.audit.Record(ctx, auditEvent(model.AuditTodoUpdate, userId, "todo", todoId, todoSummary(before), todoSummary(after)))
+
+	// closing an instance of a repeating todo (done or cancelled) schedules the next one
+	if closedStatus(status) && before.Recurrence != nil {
+		if err := s.createNextInstance(ctx, before, userId); err != nil {
+			return model.Todo{}, err
+		}
+	}
+
+	// a parent with autoComplete follows its subtasks
+	if before.ParentId != nil && (before.Done != after.Done || closedStatus(before.Status) != closedStatus(after.Status)) {
+		if err := s.syncParent(ctx, *before.ParentId, userId); err != nil {
+			return model.Todo{}, err
+		}
+	}
+	return s.withTodoProgress(ctx, after, userId)
+}
+
+// ToggleTodo is the older done / not done switch, "completed" means done and every
+// status is accepted too, the moves are checked like TransitionTodo
+func (s *todoService) ToggleTodo(ctx context.Context, todoId string, toggle string, userId string) (model.Todo, error) {
+	if todoId == "" || toggle == "" || userId == "" {
+		return model.Todo{}, errors.New("Something is missing from userId,todoId,toggle in service")
+	}
+
+	status := toggle
+	if toggle == "completed" {
+		status = model.TodoStatusDone
+	}
+	return s.TransitionTodo(ctx, todoId, status, userId)
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxTransferTodos = 100
+
+// ErrInvalidTransfer wraps the problems of a move / copy request (no todos, a lone subtask) for a 400
+var ErrInvalidTransfer = errors.New("invalid transfer")
+
+func invalidTransfer(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidTransfer, reason)
+}
+
+// loadTransfer reads the todos of a move / copy to workspaceId in the order asked, the subtasks
+// of a listed todo come along and parents go first, the caller has to own every workspace involved
+func (s *todoService) loadTransfer(ctx context.Context, todoIds []string, workspaceId string, userId string) ([]model.Todo, []string, error) {
+	if len(todoIds) == 0 {
+		return nil, nil, invalidTransfer("todoIds is empty")
+	}
+	if len(todoIds) > maxTransferTodos {
+		return nil, nil, invalidTransfer(fmt.Sprintf("at most %d todos at once", maxTransferTodos))
+	}
+	if err := s.ensureWorkspaceOwner(ctx, userId, workspaceId); err != nil {
+		return nil, nil, err
+	}
+
+	var todos []model.Todo
+	var fromWorkspaceIds []string
+	seen := map[primitive.ObjectID]bool{}
+	owned := map[primitive.ObjectID]bool{}
+	for _, todoId := range todoIds {
+		todo, err := s.repo.GetTodo(ctx, todoId, userId)
+		if err != nil {
+			return nil, nil, err
+		}
+		if seen[todo.ID] {
+			continue
+		}
+
+		if !owned[todo.WorkspaceId] {
+			if err := s.ensureWorkspaceOwner(ctx, userId, todo.WorkspaceId.Hex()); err != nil {
+				return nil, nil, err
+			}
+			owned[todo.WorkspaceId] = true
+			fromWorkspaceIds = append(fromWorkspaceIds, todo.WorkspaceId.Hex())
+		}
+
+		seen[todo.ID] = true
+		todos = append(todos, todo)
+		if todo.ParentId != nil {
+			continue
+		}
+
+		children, err := s.repo.ListChildren(ctx, todoId, userId)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, child := range children {
+			if !seen[child.ID] {
+				seen[child.ID] = true
+				todos = append(todos, child)
+			}
+		}
+	}
+
+	// parents before subtasks, a subtask listed ahead of its parent still ends up under it
+	ordered := make([]model.Todo, 0, len(todos))
+	for _, todo := range todos {
+		if todo.ParentId == nil || !seen[*todo.ParentId] {
+			ordered = append(ordered, todo)
+		}
+	}
+	for _, todo := range todos {
+		if todo.ParentId != nil && seen[*todo.ParentId] {
+			ordered = append(ordered, todo)
+		}
+	}
+	return ordered, fromWorkspaceIds, nil
+}
+
+// carryLabels adds the labels of todos that the catalog of workspaceId is missing, with the
+// color they have in the workspace the todo comes from
+func (s *todoService) carryLabels(ctx context.Context, todos []model.Todo, workspaceId string, userId string) error {
+	catalog, err := s.labelRepo.ListLabels(ctx, userId, workspaceId)
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, label := range catalog {
+		known[label.Name] = true
+	}
+	order := 0
+	if len(catalog) > 0 {
+		order = catalog[len(catalog)-1].Order + 1
+	}
+
+	sourceCatalogs := map[primitive.ObjectID]map[string]model.Label{}
+	userOid, _ := primitive.ObjectIDFromHex(userId)
+	workspaceOid, _ := primitive.ObjectIDFromHex(workspaceId)
+	for _, todo := range todos {
+		for _, name := range todo.Labels {
+			if known[name] {
+				continue
+			}
+			if len(known) >= maxLabelsPerWorkspace {
+				return errors.New("a workspace holds at most 100 labels")
+			}
+
+			source, ok := sourceCatalogs[todo.WorkspaceId]
+			if !ok {
+				labels, err := s.labelRepo.ListLabels(ctx, userId, todo.WorkspaceId.Hex())
+				if err != nil {
+					return err
+				}
+				source = map[string]model.Label{}
+				for _, label := range labels {
+					source[label.Name] = label
+				}
+				sourceCatalogs[todo.WorkspaceId] = source
+			}
+			color := source[name].Color
+			if color == "" {
+				color = defaultLabelColor
+			}
+
+			created, err := s.labelRepo.CreateLabel(ctx, model.Label{UserId: userOid, WorkspaceId: workspaceOid, Name: name, Color: color, Order: order})
+			if err != nil && !errors.Is(err, repository.ErrLabelExists) {
+				return err
+			}
+			if err == nil {
+				s.audit.Record(ctx, auditEvent(model.AuditLabelCreate, userId, "label", created.ID.Hex(), nil, labelSummary(created)))
+			}
+			known[name] = true
+			order++
+		}
+	}
+	return nil
+}
+
+// columnRanks hands out ranks at the bottom of the status columns of a workspace, one after the other
+type columnRanks struct {
+	s           *todoService
+	userId      string
+	workspaceId string
+	last        map[string]string
+}
+
+func (c *columnRanks) next(ctx context.Context, status string) (string, error) {
+	var rank string
+	var err error
+	if last, ok := c.last[status]; ok {
+		rank, err = rankBetween(last, "")
+	} else {
+		rank, err = c.s.rankAtEnd(ctx, c.userId, c.workspaceId, status)
+	}
+	if err != nil {
+		return "", err
+	}
+	c.last[status] = rank
+	return rank, nil
+}
+
+// MoveTodos moves todos (and their subtasks) to workspaceId, they keep status, priority and
+// timestamps and go to the bottom of their column there, a subtask only moves with its parent
+// an error with a non empty transfer means the move stopped partway (no transaction)
+func (s *todoService) MoveTodos(ctx context.Context, todoIds []string, workspaceId string, userId string) (model.TodoTransfer, error) {
+	todos, fromWorkspaceIds, err := s.loadTransfer(ctx, todoIds, workspaceId, userId)
+	if err != nil {
+		return model.TodoTransfer{}, err
+	}
+
+	moving := map[primitive.ObjectID]bool{}
+	for _, todo := range todos {
+		moving[todo.ID] = true
+	}
+	for _, todo := range todos {
+		if todo.WorkspaceId.Hex() == workspaceId {
+			return model.TodoTransfer{}, invalidTransfer("todo " + todo.ID.Hex() + " is already in that workspace")
+		}
+		if todo.ParentId != nil && !moving[*todo.ParentId] {
+			return model.TodoTransfer{}, invalidTransfer("a subtask moves with its parent")
+		}
+	}
+	// in a transaction the move is all or nothing, without one a failure leaves the todos moved
+	// before it in workspaceId and the transfer returned with the error lists them
+	var moved []model.Todo
+	move := func(ctx context.Context) error {
+		moved = make([]model.Todo, 0, len(todos))
+		if err := s.carryLabels(ctx, todos, workspaceId, userId); err != nil {
+			return err
+		}
+
+		ranks := &columnRanks{s: s, userId: userId, workspaceId: workspaceId, last: map[string]string{}}
+		for _, before := range todos {
+			rank, err := ranks.next(ctx, before.Status)
+			if err != nil {
+				return err
+			}
+			after, err := s.repo.SetWorkspace(ctx, before.ID.Hex(), userId, workspaceId, rank)
+			if err != nil {
+				return err
+			}
+			s.audit.Record(ctx, auditEvent(model.AuditTodoUpdate, userId, "todo", after.ID.Hex(), todoSummary(before), todoSummary(after)))
+			moved = append(moved, after)
+		}
+		return nil
+	}
+
+	transfer := model.TodoTransfer{FromWorkspaceIds: fromWorkspaceIds, Transaction: s.tx.Supported()}
+	if transfer.Transaction {
+		if err := s.tx.WithTransaction(ctx, move); err != nil {
+			return model.TodoTransfer{}, err
+		}
+	} else if err := move(ctx); err != nil {
+		if len(moved) == 0 {
+			return model.TodoTransfer{}, err
+		}
+		transfer.Todos = moved
+		if progressErr := s.withProgress(ctx, userId, moved); progressErr != nil {
+			log.Printf("progress of a partial move to %s failed: %v", workspaceId, progressErr)
+		}
+		return transfer, fmt.Errorf("moved %d of %d todos: %w", len(moved), len(todos), err)
+	}
+
+	if err := s.withProgress(ctx, userId, moved); err != nil {
+		return model.TodoTransfer{}, err
+	}
+	transfer.Todos = moved
+	return transfer, nil
+}
+
+// copyOf is todo as a new todo of workspaceId, the checklist gets new item ids and a repeating
+// todo starts a series of its own so editing one series never touches the other
+func copyOf(todo model.Todo, workspaceId primitive.ObjectID, parentId *primitive.ObjectID, rank string) model.Todo {
+	copied := todo
+	copied.ID = primitive.NewObjectID()
+	copied.WorkspaceId = workspaceId
+	copied.ParentId = parentId
+	copied.Rank = rank
+	copied.Progress = nil
+
+	copied.Checklist = nil
+	for _, item := range todo.Checklist {
+		item.ID = primitive.NewObjectID()
+		copied.Checklist = append(copied.Checklist, item)
+	}
+	if todo.Recurrence != nil {
+		recurrence := *todo.Recurrence
+		recurrence.SeriesId = copied.ID
+		recurrence.NextCreated = false
+		recurrence.Exceptions = append([]time.Time(nil), todo.Recurrence.Exceptions...)
+		copied.Recurrence = &recurrence
+	}
+	return copied
+}
+
+// CopyTodos copies todos (and their subtasks) to workspaceId with the same status, priority and
+// timestamps, a subtask copied without its parent becomes a todo of its own
+func (s *todoService) CopyTodos(ctx context.Context, todoIds []string, workspaceId string, userId string) (model.TodoTransfer, error) {
+	todos, fromWorkspaceIds, err := s.loadTransfer(ctx, todoIds, workspaceId, userId)
+	if err != nil {
+		return model.TodoTransfer{}, err
+	}
+	if err := s.carryLabels(ctx, todos, workspaceId, userId); err != nil {
+		return model.TodoTransfer{}, err
+	}
+
+	// loadTransfer put parents before their subtasks, the id of a parent's copy is known in time
+	workspaceOid, _ := primitive.ObjectIDFromHex(workspaceId)
+	copyIds := map[primitive.ObjectID]primitive.ObjectID{}
+	ranks := &columnRanks{s: s, userId: userId, workspaceId: workspaceId, last: map[string]string{}}
+	copies := make([]model.Todo, 0, len(todos))
+	for _, todo := range todos {
+		var parentId *primitive.ObjectID
+		if todo.ParentId != nil {
+			if id, ok := copyIds[*todo.ParentId]; ok {
+				parentId = &id
+			}
+		}
+
+		rank, err := ranks.next(ctx, todo.Status)
+		if err != nil {
+			return model.TodoTransfer{}, err
+		}
+		copied := copyOf(todo, workspaceOid, parentId, rank)
+		copyIds[todo.ID] = copied.ID
+		copies = append(copies, copied)
+	}
+
+	if err := s.repo.InsertTodos(ctx, copies); err != nil {
+		return model.TodoTransfer{}, err
+	}
+	for _, copied := range copies {
+		s.audit.Record(ctx, auditEvent(model.AuditTodoCreate, userId, "todo", copied.ID.Hex(), nil, todoSummary(copied)))
+	}
+
+	if err := s.withProgress(ctx, userId, copies); err != nil {
+		return model.TodoTransfer{}, err
+	}
+	return model.TodoTransfer{Todos: copies, FromWorkspaceIds: fromWorkspaceIds}, nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testTransfer is a todo with a subtask and two more todos in one workspace, to be moved to another
+type testTransfer struct {
+	repo       *fakeTodoRepo
+	userId     string
+	from, to   primitive.ObjectID
+	ids        []primitive.ObjectID // parent, subtask, second, third
+	requestIds []string
+}
+
+func newTestTransfer() *testTransfer {
+	userId := primitive.NewObjectID()
+	transfer := &testTransfer{repo: newFakeTodoRepo(), userId: userId.Hex(), from: primitive.NewObjectID(), to: primitive.NewObjectID()}
+	for i := 0; i < 4; i++ {
+		todo := model.Todo{ID: primitive.NewObjectID(), UserId: userId, WorkspaceId: transfer.from, Status: model.TodoStatusNotStarted}
+		if i == 1 {
+			todo.ParentId = &transfer.ids[0]
+		}
+		transfer.repo.todos[todo.ID] = todo
+		transfer.ids = append(transfer.ids, todo.ID)
+	}
+	transfer.requestIds = []string{transfer.ids[0].Hex(), transfer.ids[2].Hex(), transfer.ids[3].Hex()}
+	return transfer
+}
+
+func (tr *testTransfer) service(supported bool) *todoService {
+	workspaces := &fakeWorkspaceRepo{owners: map[string]string{tr.from.Hex(): tr.userId, tr.to.Hex(): tr.userId}}
+	return &todoService{
+		repo:          tr.repo,
+		workspaceRepo: workspaces,
+		labelRepo:     &fakeLabelRepo{},
+		audit:         &fakeAudit{},
+		tx:            &fakeTransactor{repo: tr.repo, supported: supported},
+	}
+}
+
+// inTarget lists the indexes of the todos that are in the target workspace now
+func (tr *testTransfer) inTarget() []int {
+	var moved []int
+	for i, id := range tr.ids {
+		if tr.repo.get(id).WorkspaceId == tr.to {
+			moved = append(moved, i)
+		}
+	}
+	return moved
+}
+
+func TestMoveTodos(t *testing.T) {
+	for _, supported := range []bool{true, false} {
+		transfer := newTestTransfer()
+		result, err := transfer.service(supported).MoveTodos(context.Background(), transfer.requestIds, transfer.to.Hex(), transfer.userId)
+		if err != nil {
+			t.Fatalf("MoveTodos(transaction %v) error = %v", supported, err)
+		}
+		if result.Transaction != supported || len(result.Todos) != 4 {
+			t.Fatalf("MoveTodos(transaction %v) = %d todos, transaction %v", supported, len(result.Todos), result.Transaction)
+		}
+		if len(result.FromWorkspaceIds) != 1 || result.FromWorkspaceIds[0] != transfer.from.Hex() {
+			t.Fatalf("FromWorkspaceIds = %v, want [%s]", result.FromWorkspaceIds, transfer.from.Hex())
+		}
+		if got := transfer.inTarget(); !equalInts(got, []int{0, 1, 2, 3}) {
+			t.Fatalf("todos in the target = %v", got)
+		}
+		// the moved todos line up at the bottom of the column in the order asked
+		for i := 1; i < len(result.Todos); i++ {
+			if result.Todos[i].Rank <= result.Todos[i-1].Rank {
+				t.Fatalf("rank %q after %q", result.Todos[i].Rank, result.Todos[i-1].Rank)
+			}
+		}
+	}
+}
+
+func TestMoveTodosFailing(t *testing.T) {
+	tests := []struct {
+		name      string
+		supported bool
+		// fail is the index of the todo whose write fails
+		fail      int
+		wantMoved []int
+	}{
+		{name: "transaction rolls back", supported: true, fail: 3, wantMoved: nil},
+		{name: "transaction, first todo fails", supported: true, fail: 0, wantMoved: nil},
+		// parents go first, the subtask moves last
+		{name: "no transaction keeps what moved", supported: false, fail: 3, wantMoved: []int{0, 2}},
+		{name: "no transaction, the subtask fails", supported: false, fail: 1, wantMoved: []int{0, 2, 3}},
+		{name: "no transaction, first todo fails", supported: false, fail: 0, wantMoved: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfer := newTestTransfer()
+			transfer.repo.failSetWorkspace = transfer.ids[tt.fail]
+
+			result, err := transfer.service(tt.supported).MoveTodos(context.Background(), transfer.requestIds, transfer.to.Hex(), transfer.userId)
+			if !errors.Is(err, errFakeWrite) {
+				t.Fatalf("MoveTodos() error = %v, want errFakeWrite", err)
+			}
+			if got := transfer.inTarget(); !equalInts(got, tt.wantMoved) {
+				t.Fatalf("todos in the target = %v, want %v", got, tt.wantMoved)
+			}
+
+			// the result lists exactly the todos that stay moved
+			if len(result.Todos) != len(tt.wantMoved) {
+				t.Fatalf("MoveTodos() reported %d moved todos, want %d", len(result.Todos), len(tt.wantMoved))
+			}
+			for i, todo := range result.Todos {
+				if todo.ID != transfer.ids[tt.wantMoved[i]] || todo.WorkspaceId != transfer.to {
+					t.Fatalf("reported todo %d = %s in %s", i, todo.ID.Hex(), todo.WorkspaceId.Hex())
+				}
+			}
+			if len(result.Todos) > 0 && (result.Transaction || len(result.FromWorkspaceIds) != 1) {
+				t.Fatalf("partial result = %+v", result)
+			}
+		})
+	}
+}
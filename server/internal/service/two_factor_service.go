@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/pkg/njwt"
+	"github.com/ndk123-web/fast-todo/pkg/ntotp"
+)
+
+const (
+	twoFactorIssuer       = "Fast-Todo"
+	twoFactorChallengeTTL = 5 * time.Minute
+	recoveryCodeCount     = 10
+
+	// 5 wrong codes per user every 5 minutes, a 6 digit code can't be brute forced at that pace
+	twoFactorVerifyLimit  = 5
+	twoFactorVerifyWindow = 5 * time.Minute
+)
+
+var ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+// TwoFactorEnrollment is shown once, the secret and recovery codes can't be read back later
+type TwoFactorEnrollment struct {
+	OtpauthURI    string   `json:"otpauthUri"`
+	Secret        string   `json:"secret"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type TwoFactorService interface {
+	Enroll(ctx context.Context, userId string) (*TwoFactorEnrollment, error)
+	Confirm(ctx context.Context, userId string, code string) error
+	Disable(ctx context.Context, userId string, code string) error
+	Verify(ctx context.Context, challengeToken string, code string, client model.ClientInfo) (*repository.SignUpResponse, error)
+}
+
+type twoFactorService struct {
+	userRepo      repository.UserRepository
+	sessionRepo   repository.SessionRepository
+	rateLimitRepo repository.RateLimitRepository
+	audit         AuditService
+}
+
+// Enroll creates a pending secret, 2FA stays off until Confirm sees a valid code from it
+func (s *twoFactorService) Enroll(ctx context.Context, userId string) (*TwoFactorEnrollment, error) {
+	user, err := s.userRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.userRepo.GetTwoFactor(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if current.Enabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := ntotp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.SavePendingTwoFactor(ctx, userId, secret, hashes); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorEnrollment{
+		OtpauthURI:    ntotp.KeyURI(twoFactorIssuer, user.Email, secret),
+		Secret:        secret,
+		RecoveryCodes: codes,
+	}, nil
+}
+
+func (s *twoFactorService) Confirm(ctx context.Context, userId string, code string) error {
+	current, err := s.userRepo.GetTwoFactor(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if current.PendingSecret == "" {
+		return errors.New("no pending two-factor enrollment")
+	}
+
+	if err := s.checkTotp(ctx, userId, current.PendingSecret, normalizeCode(code)); err != nil {
+		return err
+	}
+
+	return s.userRepo.EnableTwoFactor(ctx, userId, current.PendingSecret, current.PendingRecoveryCodes)
+}
+
+// Disable needs a current code (or a recovery code) so a stolen access token can't turn 2FA off
+func (s *twoFactorService) Disable(ctx context.Context, userId string, code string) error {
+	current, err := s.userRepo.GetTwoFactor(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if !current.Enabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+
+	if err := s.checkCode(ctx, userId, current, code); err != nil {
+		return err
+	}
+
+	return s.userRepo.DisableTwoFactor(ctx, userId)
+}
+
+// Verify trades the sign in challenge plus a TOTP or recovery code for a real session
+func (s *twoFactorService) Verify(ctx context.Context, challengeToken string, code string, client model.ClientInfo) (*repository.SignUpResponse, error) {
+	claims, err := njwt.ParseToken(challengeToken, "2fa-challenge")
+	if err != nil {
+		return nil, repository.ErrInvalidToken
+	}
+
+	userId, _ := claims["userId"].(string)
+	if userId == "" {
+		return nil, repository.ErrInvalidToken
+	}
+
+	user, err := s.userRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	// the challenge may have been issued right before an admin disabled the account
+	if user.Disabled {
+		return nil, repository.ErrAccountDisabled
+	}
+
+	current, err := s.userRepo.GetTwoFactor(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !current.Enabled {
+		return nil, repository.ErrInvalidToken
+	}
+
+	if err := s.checkCode(ctx, userId, current, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.audit.Record(ctx, signInFailedEvent(userId, user.Email, "invalid two-factor code"))
+		}
+		return nil, err
+	}
+
+	response := &repository.SignUpResponse{
+		Email:         user.Email,
+		UserId:        userId,
+		FullName:      user.FullName,
+		EmailVerified: user.IsVerified(),
+	}
+	if err := cancelScheduledDeletion(ctx, s.userRepo, response); err != nil {
+		return nil, err
+	}
+	if err := issueTokens(ctx, s.sessionRepo, response, client); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, signInEvent(userId, user.Email, "two-factor"))
+	return response, nil
+}
+
+// checkCode accepts a TOTP code from the active secret or an unused recovery code
+func (s *twoFactorService) checkCode(ctx context.Context, userId string, current *model.TwoFactor, code string) error {
+	normalized := normalizeCode(code)
+	if len(normalized) != ntotp.Digits {
+		// recovery codes are 8 characters, anything that isn't a 6 digit code is tried as one
+		if err := s.allowAttempt(ctx, userId); err != nil {
+			return err
+		}
+		used, err := s.userRepo.UseRecoveryCode(ctx, userId, hashRecoveryCode(normalized))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	return s.checkTotp(ctx, userId, current.Secret, normalized)
+}
+
+func (s *twoFactorService) checkTotp(ctx context.Context, userId string, secret string, code string) error {
+	if err := s.allowAttempt(ctx, userId); err != nil {
+		return err
+	}
+
+	step, ok := ntotp.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	// a code is valid for ~90 seconds with skew, remember the step so it can't be replayed
+	fresh, _, err := s.rateLimitRepo.Allow(ctx, fmt.Sprintf("2fa-step:%s:%d", userId, step), 1, 3*ntotp.Period)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+func (s *twoFactorService) allowAttempt(ctx context.Context, userId string) error {
+	allowed, retryAfter, err := s.rateLimitRepo.Allow(ctx, "2fa-verify:"+userId, twoFactorVerifyLimit, twoFactorVerifyWindow)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &repository.RateLimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// generateRecoveryCodes returns codes like "k3v9-x2mq" and their hashes for storage
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(buf))
+		codes = append(codes, raw[:4]+"-"+raw[4:])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeCode drops dashes / spaces and case so users can type a TOTP code ("123 456") or a
+// recovery code ("K3V9-X2MQ") however they like
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+}
+
+func hashRecoveryCode(normalized string) string {
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func NewTwoFactorService(userRepo repository.UserRepository, sessionRepo repository.SessionRepository, rateLimitRepo repository.RateLimitRepository, audit AuditService) TwoFactorService {
+	return &twoFactorService{
+		userRepo:      userRepo,
+		sessionRepo:   sessionRepo,
+		rateLimitRepo: rateLimitRepo,
+		audit:         audit,
+	}
+}
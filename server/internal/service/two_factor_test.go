@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ndk123-web/fast-todo/internal/model"
+	"github.com/ndk123-web/fast-todo/pkg/ntotp"
+)
+
+func TestCheckCodeSeparators(t *testing.T) {
+	secret, err := ntotp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := ntotp.GenerateCode(secret, ntotp.Step(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := &model.TwoFactor{Enabled: true, Secret: secret}
+
+	tests := []string{
+		code,
+		code[:3] + " " + code[3:],
+		code[:3] + "-" + code[3:],
+		" " + code + " ",
+	}
+	for _, typed := range tests {
+		t.Run(typed, func(t *testing.T) {
+			// a fresh rate limit every time, the step of a code is only accepted once
+			service := &twoFactorService{rateLimitRepo: &fakeRateLimit{}}
+			if err := service.checkCode(context.Background(), "user", current, typed); err != nil {
+				t.Fatalf("checkCode(%q) error = %v", typed, err)
+			}
+			if err := service.checkCode(context.Background(), "user", current, typed); !errors.Is(err, ErrInvalidTwoFactorCode) {
+				t.Fatalf("replayed checkCode(%q) error = %v, want ErrInvalidTwoFactorCode", typed, err)
+			}
+		})
+	}
+}
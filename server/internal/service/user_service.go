@@ -2,36 +2,101 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/ndk123-web/fast-todo/internal/model"
 	"github.com/ndk123-web/fast-todo/internal/repository"
+	"github.com/ndk123-web/fast-todo/pkg/nbcrypt"
 	"github.com/ndk123-web/fast-todo/pkg/njwt"
+	"github.com/ndk123-web/fast-todo/pkg/nmailer"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var JWTSECRET = []byte(os.Getenv("JWT_SECRET"))
-
 type UserService interface {
 	GetUserTodos(ctx context.Context, userId string) ([]model.Todo, error)
-	SignUpUser(ctx context.Context, email string, password string, fullName string) (*repository.SignUpResponse, error)
-	SignInUser(ctx context.Context, email string, password string) (*repository.SignUpResponse, error)
+	SignUpUser(ctx context.Context, email string, password string, fullName string, client model.ClientInfo) (*repository.SignUpResponse, error)
+	SignInUser(ctx context.Context, email string, password string, client model.ClientInfo) (*repository.SignUpResponse, error)
 	UpdateUserName(ctx context.Context, userId string, newName string) (bool, error)
-	SignInGoogleUser(ctx context.Context, email string, fullName string) (*repository.SignUpResponse, error)
-	SignUpWithGoogle(ctx context.Context, email string, fullName string) (*repository.SignUpResponse, error)
+	SignInWithIdentity(ctx context.Context, identity *model.ExternalIdentity, client model.ClientInfo) (*repository.SignUpResponse, error)
+	SignUpWithIdentity(ctx context.Context, identity *model.ExternalIdentity, client model.ClientInfo) (*repository.SignUpResponse, error)
+	RefreshToken(ctx context.Context, refreshToken string) (string, string, error)
+	Logout(ctx context.Context, userId string, sessionId string) error
+	LogoutAll(ctx context.Context, userId string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ForcePasswordReset(ctx context.Context, userId string) error
+	ResetPassword(ctx context.Context, token string, newPassword string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerificationEmail(ctx context.Context, userId string) error
+	ChangePassword(ctx context.Context, userId string, sessionId string, currentPassword string, newPassword string) error
+	SetPassword(ctx context.Context, userId string, newPassword string) error
+	LinkIdentity(ctx context.Context, userId string, identity *model.ExternalIdentity) error
+	UnlinkIdentity(ctx context.Context, userId string, provider string) error
 }
 
 type userService struct {
-	repo repository.UserRepository
+	repo          repository.UserRepository
+	sessionRepo   repository.SessionRepository
+	tokenRepo     repository.OneTimeTokenRepository
+	rateLimitRepo repository.RateLimitRepository
+	mailer        nmailer.Mailer
+	loginGuard    LoginGuard
+	audit         AuditService
+}
+
+const (
+	passwordResetPurpose = "password-reset"
+	passwordResetTTL     = 30 * time.Minute
+	minPasswordLength    = 8
+
+	emailVerificationTTL = 48 * time.Hour
+	// at most 3 verification mails per user every 15 minutes
+	verificationResendLimit  = 3
+	verificationResendWindow = 15 * time.Minute
+
+	// a stolen session shouldn't be able to brute force the current password
+	changePasswordLimit  = 5
+	changePasswordWindow = 15 * time.Minute
+)
+
+// issueTokens starts a new session for the signed in user and injects its token pair into response
+func issueTokens(ctx context.Context, sessionRepo repository.SessionRepository, response *repository.SignUpResponse, client model.ClientInfo) error {
+	session := model.Session{
+		ID:        primitive.NewObjectID().Hex(),
+		UserId:    response.UserId,
+		Device:    DeviceFromUserAgent(client.UserAgent),
+		UserAgent: client.UserAgent,
+		IP:        client.IP,
+	}
+	tokenId := primitive.NewObjectID().Hex()
+
+	if err := sessionRepo.CreateSession(ctx, session, tokenId, njwt.RefreshTokenTTL); err != nil {
+		return err
+	}
+
+	accessString, refreshString, err := njwt.CreateAccessAndRefreshToken(response.Email, response.UserId, session.ID, tokenId)
+	if err != nil {
+		return err
+	}
+
+	// inject tokens with response
+	response.AccessToken = accessString
+	response.RefreshToken = refreshString
+	return nil
 }
 
 func (s *userService) GetUserTodos(ctx context.Context, userId string) ([]model.Todo, error) {
 	return s.repo.GetUserTodos(ctx, userId)
 }
 
-func (s *userService) SignUpUser(ctx context.Context, email string, password string, fullName string) (*repository.SignUpResponse, error) {
+func (s *userService) SignUpUser(ctx context.Context, email string, password string, fullName string, client model.ClientInfo) (*repository.SignUpResponse, error) {
 
 	// bcrypt the password
 	hashedPassword, err := BcryptForPassword(password)
@@ -44,71 +109,135 @@ func (s *userService) SignUpUser(ctx context.Context, email string, password str
 		return nil, err
 	}
 
-	accessString, refreshString, err := njwt.CreateAccessAndRefreshToken(email)
-	if err != nil {
+	// a failed mail shouldn't fail the sign up, the user can ask for another one
+	if err := s.sendVerificationEmail(ctx, response.UserId, email); err != nil {
+		fmt.Println("Verification mail error:", err)
+	}
+
+	if err := issueTokens(ctx, s.sessionRepo, response, client); err != nil {
 		return nil, err
 	}
-	// inject tokens with response
-	response.AccessToken = accessString
-	response.RefreshToken = refreshString
 
 	return response, nil
 }
 
-func (s *userService) SignUpWithGoogle(ctx context.Context, email string, fullName string) (*repository.SignUpResponse, error) {
-	if email == "" || fullName == "" {
-		return nil, errors.New("Email/FullName is Missing in Service")
+// SignUpWithIdentity creates an account for an identity verified by its provider, the account
+// counts as email verified so the provider must have verified the email itself
+func (s *userService) SignUpWithIdentity(ctx context.Context, identity *model.ExternalIdentity, client model.ClientInfo) (*repository.SignUpResponse, error) {
+	if identity.Subject == "" || identity.Email == "" {
+		return nil, errors.New("Subject/Email is Missing in Service")
+	}
+	if !identity.EmailVerified {
+		return nil, errors.New("The email of this account isn't verified by the provider")
 	}
 
-	response, err := s.repo.SignUpWithGoogle(ctx, email, fullName)
+	response, err := s.repo.SignUpWithIdentity(ctx, identity)
 	if err != nil {
 		return nil, err
 	}
 
-	accessString, refreshString, err := njwt.CreateAccessAndRefreshToken(email)
-	if err != nil {
+	if err := issueTokens(ctx, s.sessionRepo, response, client); err != nil {
 		return nil, err
 	}
-	// inject tokens with response
-	response.AccessToken = accessString
-	response.RefreshToken = refreshString
 
 	return response, nil
 }
 
-func (s *userService) SignInUser(ctx context.Context, email string, password string) (*repository.SignUpResponse, error) {
+func (s *userService) SignInUser(ctx context.Context, email string, password string, client model.ClientInfo) (*repository.SignUpResponse, error) {
+
+	// locked emails / ips are refused before the password is even looked at
+	if err := s.loginGuard.Check(ctx, email, client.IP); err != nil {
+		s.auditSignInFailed(ctx, email, "locked")
+		return nil, err
+	}
 
 	// if response is all right then
 	response, err := s.repo.SignInUser(ctx, email, password)
+	if errors.Is(err, repository.ErrInvalidCredentials) {
+		s.auditSignInFailed(ctx, email, "invalid credentials")
+		if lockErr := s.loginGuard.RecordFailure(ctx, email, client.IP); lockErr != nil {
+			return nil, lockErr
+		}
+		return nil, err
+	}
+	if errors.Is(err, repository.ErrAccountDisabled) {
+		s.auditSignInFailed(ctx, email, "disabled")
+		return nil, err
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// get the accessToken and Refresh token
-	accessString, refreshString, err := njwt.CreateAccessAndRefreshToken(response.Email)
-	if err != nil {
+	if err := s.loginGuard.RecordSuccess(ctx, email); err != nil {
 		return nil, err
 	}
 
-	// inject access and refresh token to the response and send it to the handler
-	response.AccessToken = accessString
-	response.RefreshToken = refreshString
+	// with 2FA on the password alone only buys a challenge token
+	if challenge, err := s.twoFactorChallenge(ctx, response); challenge != nil || err != nil {
+		return challenge, err
+	}
 
+	if err := cancelScheduledDeletion(ctx, s.repo, response); err != nil {
+		return nil, err
+	}
+
+	// get the accessToken and Refresh token and inject them to the response
+	if err := issueTokens(ctx, s.sessionRepo, response, client); err != nil {
+		return nil, err
+	}
+
+	s.audit.Record(ctx, signInEvent(response.UserId, response.Email, "password"))
 	return response, nil
 }
 
-// SignInGoogleUser: assumes ID token already verified and supplies email (+ optional name)
-func (s *userService) SignInGoogleUser(ctx context.Context, email string, fullName string) (*repository.SignUpResponse, error) {
-	resp, err := s.repo.SignInGoogleUser(ctx, email, fullName)
+// twoFactorChallenge is the response of a sign in that still needs the TOTP code, nil when the
+// user has 2FA off and the sign in is complete
+func (s *userService) twoFactorChallenge(ctx context.Context, response *repository.SignUpResponse) (*repository.SignUpResponse, error) {
+	twoFactor, err := s.repo.GetTwoFactor(ctx, response.UserId)
 	if err != nil {
 		return nil, err
 	}
-	accessString, refreshString, err := njwt.CreateAccessAndRefreshToken(resp.Email)
+	if !twoFactor.Enabled {
+		return nil, nil
+	}
+
+	challenge, err := njwt.CreateChallengeToken(response.UserId, response.Email, twoFactorChallengeTTL)
 	if err != nil {
 		return nil, err
 	}
-	resp.AccessToken = accessString
-	resp.RefreshToken = refreshString
+	return &repository.SignUpResponse{Email: response.Email, TwoFactorRequired: true, ChallengeToken: challenge}, nil
+}
+
+// auditSignInFailed ties the failure to the account of email when there is one
+func (s *userService) auditSignInFailed(ctx context.Context, email string, reason string) {
+	userId := ""
+	if user, err := s.repo.GetUserByEmail(ctx, email); err == nil {
+		userId = user.ID.Hex()
+	}
+	s.audit.Record(ctx, signInFailedEvent(userId, email, reason))
+}
+
+// SignInWithIdentity: assumes the ID token was already verified by its provider
+func (s *userService) SignInWithIdentity(ctx context.Context, identity *model.ExternalIdentity, client model.ClientInfo) (*repository.SignUpResponse, error) {
+	resp, err := s.repo.SignInWithIdentity(ctx, identity)
+	if errors.Is(err, repository.ErrAccountDisabled) {
+		s.auditSignInFailed(ctx, identity.Email, "disabled")
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the provider stands in for the password, 2FA is still asked for
+	if challenge, err := s.twoFactorChallenge(ctx, resp); challenge != nil || err != nil {
+		return challenge, err
+	}
+	if err := cancelScheduledDeletion(ctx, s.repo, resp); err != nil {
+		return nil, err
+	}
+	if err := issueTokens(ctx, s.sessionRepo, resp, client); err != nil {
+		return nil, err
+	}
+	s.audit.Record(ctx, signInEvent(resp.UserId, resp.Email, identity.Provider))
 	return resp, nil
 }
 
@@ -128,11 +257,366 @@ func (s *userService) UpdateUserName(ctx context.Context, userId string, newName
 		return false, fmt.Errorf("userId or newName is empty")
 	}
 
-	return s.repo.UpdateUserName(ctx, userId, newName)
+	before, err := s.repo.GetUserById(ctx, userId)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := s.repo.UpdateUserName(ctx, userId, newName)
+	if err != nil {
+		return false, err
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditNameChange, userId, "user", userId, map[string]any{"fullName": before.FullName}, map[string]any{"fullName": newName}))
+	return updated, nil
+}
+
+// RefreshToken rotates the refresh token of a session and returns a new access/refresh pair
+// presenting an already rotated refresh token revokes the whole session
+func (s *userService) RefreshToken(ctx context.Context, refreshToken string) (string, string, error) {
+	claims, err := njwt.ParseToken(refreshToken, "refresh")
+	if err != nil {
+		return "", "", err
+	}
+
+	email, _ := claims["email"].(string)
+	userId, _ := claims["userId"].(string)
+	sessionId, _ := claims["sid"].(string)
+	oldTokenId, _ := claims["jti"].(string)
+	if email == "" || userId == "" || sessionId == "" || oldTokenId == "" {
+		return "", "", errors.New("refresh token is missing session claims")
+	}
+
+	newTokenId := primitive.NewObjectID().Hex()
+	if err := s.sessionRepo.RotateRefreshToken(ctx, userId, sessionId, oldTokenId, newTokenId, njwt.RefreshTokenTTL); err != nil {
+		return "", "", err
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditTokenRefresh, userId, "session", sessionId, nil, nil))
+	return njwt.CreateAccessAndRefreshToken(email, userId, sessionId, newTokenId)
+}
+
+func (s *userService) Logout(ctx context.Context, userId string, sessionId string) error {
+	if userId == "" || sessionId == "" {
+		return errors.New("UserId / SessionId is Empty in Service")
+	}
+
+	return s.sessionRepo.RevokeSession(ctx, userId, sessionId)
+}
+
+func (s *userService) LogoutAll(ctx context.Context, userId string) error {
+	if userId == "" {
+		return errors.New("UserId is Empty in Service")
+	}
+
+	return s.sessionRepo.RevokeAllSessions(ctx, userId)
+}
+
+// ForgotPassword mails a single-use reset link to the account owner
+// it returns nil for unknown emails too so the endpoint can't be used to find accounts
+func (s *userService) ForgotPassword(ctx context.Context, email string) error {
+	if email == "" {
+		return errors.New("Email is Empty in Service")
+	}
+
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	link, err := s.createResetLink(ctx, user.ID.Hex())
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, nmailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Fast-Todo password",
+		Body: "Someone asked to reset the password of your Fast-Todo account.\n\n" +
+			"Open this link within 30 minutes to choose a new password:\n" + link + "\n\n" +
+			"If it wasn't you, ignore this mail, your password stays the same.",
+	})
+}
+
+// ForcePasswordReset is the admin action: the current password stops working, every
+// session is signed out and the owner gets a reset link to choose a new one
+func (s *userService) ForcePasswordReset(ctx context.Context, userId string) error {
+	user, err := s.repo.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	// a random hash nobody knows, the reset link is the only way back in with a password
+	random, err := generateToken()
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := nbcrypt.BcryptForPassword(random)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, userId, hashedPassword); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.RevokeAllSessions(ctx, userId); err != nil {
+		return err
+	}
+
+	link, err := s.createResetLink(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, nmailer.Message{
+		To:      user.Email,
+		Subject: "Choose a new Fast-Todo password",
+		Body: "An administrator reset the password of your Fast-Todo account and signed you out everywhere.\n\n" +
+			"Open this link within 30 minutes to choose a new password:\n" + link + "\n\n" +
+			"Once it expires, use \"Forgot password\" on the sign in page to get a new one.",
+	})
+}
+
+// createResetLink stores a single-use reset token for userId and returns the client link for it
+func (s *userService) createResetLink(ctx context.Context, userId string) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.tokenRepo.SaveToken(ctx, passwordResetPurpose, token, userId, passwordResetTTL); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/reset-password?token=%s", clientURL(), url.QueryEscape(token)), nil
+}
+
+// ResetPassword consumes the reset token, stores the new hash and signs out every session
+func (s *userService) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	if token == "" {
+		return repository.ErrInvalidToken
+	}
+	if err := validateNewPassword(newPassword); err != nil {
+		return err
+	}
+
+	userId, err := s.tokenRepo.ConsumeToken(ctx, passwordResetPurpose, token)
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := nbcrypt.BcryptForPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, userId, hashedPassword); err != nil {
+		return err
+	}
+
+	// whoever knew the old password may still hold tokens
+	return s.sessionRepo.RevokeAllSessions(ctx, userId)
+}
+
+// VerifyEmail marks the account of a signed verification link as verified
+func (s *userService) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := njwt.ParseToken(token, "verify-email")
+	if err != nil {
+		return repository.ErrInvalidToken
+	}
+
+	userId, _ := claims["userId"].(string)
+	email, _ := claims["email"].(string)
+	if userId == "" || email == "" {
+		return repository.ErrInvalidToken
+	}
+
+	if err := s.repo.MarkEmailVerified(ctx, userId, email); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return repository.ErrInvalidToken
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ResendVerificationEmail sends a fresh link, limited per user so it can't be used to spam an inbox
+func (s *userService) ResendVerificationEmail(ctx context.Context, userId string) error {
+	if userId == "" {
+		return errors.New("UserId is Empty in Service")
+	}
+
+	user, err := s.repo.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if user.IsVerified() {
+		return errors.New("email is already verified")
+	}
+
+	allowed, retryAfter, err := s.rateLimitRepo.Allow(ctx, "verify-email:"+userId, verificationResendLimit, verificationResendWindow)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &repository.RateLimitError{RetryAfter: retryAfter}
+	}
+
+	return s.sendVerificationEmail(ctx, userId, user.Email)
+}
+
+// ChangePassword needs the current password, every other session of the user is signed out
+func (s *userService) ChangePassword(ctx context.Context, userId string, sessionId string, currentPassword string, newPassword string) error {
+	if err := validateNewPassword(newPassword); err != nil {
+		return err
+	}
+
+	allowed, retryAfter, err := s.rateLimitRepo.Allow(ctx, "change-password:"+userId, changePasswordLimit, changePasswordWindow)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &repository.RateLimitError{RetryAfter: retryAfter}
+	}
+
+	user, err := s.repo.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if !user.HasPassword() {
+		return errors.New("This account has no password yet, use set-password")
+	}
+	if ok, err := nbcrypt.ValidatePassword(currentPassword, user.HashedPassword); !ok || err != nil {
+		return errors.New("Current password is incorrect")
+	}
+
+	hashedPassword, err := nbcrypt.BcryptForPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, userId, hashedPassword); err != nil {
+		return err
+	}
+
+	return s.revokeOtherSessions(ctx, userId, sessionId)
+}
+
+// SetPassword lets a Google sign up add a password, it is refused once one is set
+func (s *userService) SetPassword(ctx context.Context, userId string, newPassword string) error {
+	if err := validateNewPassword(newPassword); err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if user.HasPassword() {
+		return errors.New("Password already set, use change-password")
+	}
+
+	hashedPassword, err := nbcrypt.BcryptForPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	return s.repo.UpdatePassword(ctx, userId, hashedPassword)
+}
+
+// LinkIdentity attaches an already verified identity to the signed in user
+func (s *userService) LinkIdentity(ctx context.Context, userId string, identity *model.ExternalIdentity) error {
+	if userId == "" || identity.Subject == "" {
+		return errors.New("UserId / Subject is Empty in Service")
+	}
+
+	return s.repo.LinkIdentity(ctx, userId, identity.Provider, identity.Subject)
+}
+
+// UnlinkIdentity is refused while the identity is the only way into the account
+func (s *userService) UnlinkIdentity(ctx context.Context, userId string, provider string) error {
+	user, err := s.repo.GetUserById(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if !user.HasIdentity(provider) {
+		return fmt.Errorf("No %s account is linked", provider)
+	}
+	if !user.HasPassword() && len(user.LinkedProviders()) == 1 {
+		return errors.New("Set a password before unlinking your last sign in method, otherwise you can't sign in anymore")
+	}
+
+	return s.repo.UnlinkIdentity(ctx, userId, provider)
+}
+
+// revokeOtherSessions signs out every session of the user except keepSessionId
+func (s *userService) revokeOtherSessions(ctx context.Context, userId string, keepSessionId string) error {
+	sessions, err := s.sessionRepo.ListSessions(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if session.ID == keepSessionId {
+			continue
+		}
+		if err := s.sessionRepo.RevokeSession(ctx, userId, session.ID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *userService) sendVerificationEmail(ctx context.Context, userId string, email string) error {
+	token, err := njwt.CreateEmailVerificationToken(userId, email, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", clientURL(), url.QueryEscape(token))
+	return s.mailer.Send(ctx, nmailer.Message{
+		To:      email,
+		Subject: "Verify your Fast-Todo email",
+		Body: "Welcome to Fast-Todo!\n\n" +
+			"Open this link within 48 hours to verify your email address:\n" + link + "\n\n" +
+			"If you didn't create an account, ignore this mail.",
+	})
+}
+
+func validateNewPassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return nil
+}
+
+// generateToken returns 32 random bytes as hex, used for links sent by mail
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// clientURL is the frontend base url used in links sent by mail
+func clientURL() string {
+	if u := os.Getenv("CLIENT_URL"); u != "" {
+		return strings.TrimRight(u, "/")
+	}
+	return "http://localhost:5173"
 }
 
-func NewUserService(repo repository.UserRepository) UserService {
+func NewUserService(repo repository.UserRepository, sessionRepo repository.SessionRepository, tokenRepo repository.OneTimeTokenRepository, rateLimitRepo repository.RateLimitRepository, mailer nmailer.Mailer, loginGuard LoginGuard, audit AuditService) UserService {
 	return &userService{
-		repo: repo,
+		repo:          repo,
+		sessionRepo:   sessionRepo,
+		tokenRepo:     tokenRepo,
+		rateLimitRepo: rateLimitRepo,
+		mailer:        mailer,
+		loginGuard:    loginGuard,
+		audit:         audit,
 	}
 }
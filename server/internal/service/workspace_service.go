@@ -14,12 +14,13 @@ type WorkspaceService interface {
 	CreateWorkspace(ctx context.Context, userId string, workspaceName string) (string, error)
 	UpdatedWorkspace(ctx context.Context, userId string, workspaceName string, updatedWorkspace string) error
 	DeleteWorkspace(ctx context.Context, userId string, workspaceName string) error
-	UpdateWorkspaceLayout(ctx context.Context, workspaceId string, nodes, edges []map[string]interface{}) (bool, error)
+	UpdateWorkspaceLayout(ctx context.Context, userId string, workspaceId string, nodes, edges []map[string]interface{}) (bool, error)
 }
 
 // workspaceService struct
 type workspaceService struct {
-	repo repository.WorkSpaceRepository
+	repo  repository.WorkSpaceRepository
+	audit AuditService
 }
 
 func (s *workspaceService) GetAllUserWorkspace(ctx context.Context, userId string) ([]model.Workspace, error) {
@@ -37,7 +38,13 @@ func (s *workspaceService) CreateWorkspace(ctx context.Context, userId string, w
 	}
 
 	// call the repo create method
-	return s.repo.CreateWorkspace(ctx, userId, workspaceName)
+	workspaceId, err := s.repo.CreateWorkspace(ctx, userId, workspaceName)
+	if err != nil {
+		return "", err
+	}
+
+	s.audit.Record(ctx, auditEvent(model.AuditWorkspaceCreate, userId, "workspace", workspaceId, nil, workspaceSummary(model.Workspace{WorkspaceName: workspaceName})))
+	return workspaceId, nil
 }
 
 func (s *workspaceService) UpdatedWorkspace(ctx context.Context, userId string, workspaceName string, updatedWorkspace string) error {
@@ -45,11 +52,19 @@ func (s *workspaceService) UpdatedWorkspace(ctx context.Context, userId string,
 		return errors.New("UserId / workspace name empty in Service")
 	}
 
+	before, err := s.repo.GetWorkspaceByName(ctx, userId, workspaceName)
+	if err != nil {
+		return err
+	}
+
 	// call the repo update method
 	if err := s.repo.UpdatedWorkspace(ctx, userId, workspaceName, updatedWorkspace); err != nil {
 		return err
 	}
 
+	after := before
+	after.WorkspaceName = updatedWorkspace
+	s.audit.Record(ctx, auditEvent(model.AuditWorkspaceUpdate, userId, "workspace", before.ID.Hex(), workspaceSummary(before), workspaceSummary(after)))
 	return nil
 }
 
@@ -58,25 +73,33 @@ func (s *workspaceService) DeleteWorkspace(ctx context.Context, userId string, w
 		return errors.New("UserId / workspace name empty in Service")
 	}
 
+	before, err := s.repo.GetWorkspaceByName(ctx, userId, workspaceName)
+	if err != nil {
+		return err
+	}
+
 	// call the repo delete method
 	if err := s.repo.DeleteWorkspace(ctx, userId, workspaceName); err != nil {
 		return err
 	}
 
+	s.audit.Record(ctx, auditEvent(model.AuditWorkspaceDelete, userId, "workspace", before.ID.Hex(), workspaceSummary(before), nil))
 	return nil
 }
 
-func (s *workspaceService) UpdateWorkspaceLayout(ctx context.Context, workspaceId string, nodes, edges []map[string]interface{}) (bool, error) {
-	if workspaceId == "" {
-		return false, errors.New("workspaceId is empty in Service")
+func (s *workspaceService) UpdateWorkspaceLayout(ctx context.Context, userId string, workspaceId string, nodes, edges []map[string]interface{}) (bool, error) {
+	if userId == "" || workspaceId == "" {
+		return false, errors.New("userId / workspaceId is empty in Service")
 	}
 
 	// call the repo update layout method
-	return s.repo.UpdateWorkspaceLayout(ctx, workspaceId, nodes, edges)
+	// not audited, the canvas autosaves its layout while nodes are dragged
+	return s.repo.UpdateWorkspaceLayout(ctx, userId, workspaceId, nodes, edges)
 }
 
-func NewWorkSpaceService(repo repository.WorkSpaceRepository) WorkspaceService {
+func NewWorkSpaceService(repo repository.WorkSpaceRepository, audit AuditService) WorkspaceService {
 	return &workspaceService{
-		repo: repo,
+		repo:  repo,
+		audit: audit,
 	}
 }
@@ -1,39 +1,119 @@
 package njwt
 
 import (
-	"github.com/golang-jwt/jwt/v5"
-	"os"
+	"errors"
 	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
-var JWTSECRET = []byte(os.Getenv("JWT_SECRET"))
+// keys is set once at startup by config.InitJWT, after the .env file is loaded
+var keys *KeyManager
+
+// ErrNoKeys is returned when tokens are signed / parsed before SetKeyManager was called
+var ErrNoKeys = errors.New("njwt: key manager not initialized")
+
+// SetKeyManager sets the keys used by every function of this package
+func SetKeyManager(km *KeyManager) {
+	keys = km
+}
+
+// JWKS returns the public keys of the current key manager
+func JWKS() (JWKSet, error) {
+	if keys == nil {
+		return JWKSet{}, ErrNoKeys
+	}
+	return keys.JWKS(), nil
+}
 
-func CreateAccessAndRefreshToken(email string) (string, string, error) {
+func sign(claims jwt.MapClaims) (string, error) {
+	if keys == nil {
+		return "", ErrNoKeys
+	}
+	return keys.Sign(claims)
+}
+
+const (
+	AccessTokenTTL  = 48 * time.Hour
+	RefreshTokenTTL = 7 * 24 * time.Hour // 7 days
+)
+
+// CreateAccessAndRefreshToken signs a token pair for the user
+// userId is stamped on both tokens so AuthMiddleware can scope every request to its owner
+// sessionId (sid) ties both tokens to a revocable session, tokenId (jti) identifies this refresh token for rotation
+func CreateAccessAndRefreshToken(email string, userId string, sessionId string, tokenId string) (string, string, error) {
 	// create refresh token
 	refreshClaims := jwt.MapClaims{
-		"email": email,
-		"type":  "refresh",
-		"exp":   time.Now().Add(7 * 24 * time.Hour).Unix(), // 7 days
+		"email":  email,
+		"userId": userId,
+		"sid":    sessionId,
+		"jti":    tokenId,
+		"type":   "refresh",
+		"exp":    time.Now().Add(RefreshTokenTTL).Unix(),
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refreshString, err := refreshToken.SignedString(JWTSECRET)
+	refreshString, err := sign(refreshClaims)
 	if err != nil {
-		return "", "", nil
+		return "", "", err
 	}
 
 	// create Access token
 	accessClaims := jwt.MapClaims{
-		"email": email,
-		"type":  "access",
-		"exp":   time.Now().Add(48 * time.Hour).Unix(), // 7 days
+		"email":  email,
+		"userId": userId,
+		"sid":    sessionId,
+		"type":   "access",
+		"exp":    time.Now().Add(AccessTokenTTL).Unix(),
 	}
-
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	accessString, err := accessToken.SignedString(JWTSECRET)
-	
+	accessString, err := sign(accessClaims)
 	if err != nil {
 		return "", "", err
 	}
 
 	return accessString, refreshString, nil
 }
+
+// CreateEmailVerificationToken signs the token put in the verification link
+// email is part of it so the link stops working if the account email changes
+func CreateEmailVerificationToken(userId string, email string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"userId": userId,
+		"email":  email,
+		"type":   "verify-email",
+		"exp":    time.Now().Add(ttl).Unix(),
+	}
+
+	return sign(claims)
+}
+
+// CreateChallengeToken signs the short lived token returned by sign in when 2FA is on
+// it only proves the password was right, /2fa/verify trades it plus a code for real tokens
+func CreateChallengeToken(userId string, email string, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"userId": userId,
+		"email":  email,
+		"type":   "2fa-challenge",
+		"exp":    time.Now().Add(ttl).Unix(),
+	}
+
+	return sign(claims)
+}
+
+// ParseToken verifies the signature and expiry of tokenString and checks its "type" claim
+// so an access token can't be used where a refresh token is expected and vice versa
+func ParseToken(tokenString string, tokenType string) (jwt.MapClaims, error) {
+	if keys == nil {
+		return nil, ErrNoKeys
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims["type"] != tokenType {
+		return nil, errors.New("unexpected token type")
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,279 @@
+package njwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyManager signs tokens with the current key and verifies them against the current and previous keys
+// rotating a key: make the new key current and keep the old one as previous until every token
+// signed by it has expired (RefreshTokenTTL), then drop it
+type KeyManager struct {
+	current  *signingKey
+	previous []*signingKey
+}
+
+type signingKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// JWK is the public part of a signing key as served on /.well-known/jwks.json (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 (OKP) and EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeyManager builds a KeyManager from a PEM private key (RSA or Ed25519) and
+// any number of previous keys, which may be private or public PEM keys
+func NewKeyManager(currentPEM []byte, previousPEMs ...[]byte) (*KeyManager, error) {
+	current, err := parseKey(currentPEM)
+	if err != nil {
+		return nil, fmt.Errorf("njwt: current key: %w", err)
+	}
+	if current.private == nil {
+		return nil, errors.New("njwt: current key must be a private key")
+	}
+
+	km := &KeyManager{current: current}
+	for i, p := range previousPEMs {
+		key, err := parseKey(p)
+		if err != nil {
+			return nil, fmt.Errorf("njwt: previous key %d: %w", i, err)
+		}
+		if key.kid != current.kid {
+			km.previous = append(km.previous, key)
+		}
+	}
+
+	return km, nil
+}
+
+// NewEphemeralKeyManager generates an in-memory Ed25519 key, tokens die with the process
+// meant for local development only
+func NewEphemeralKeyManager() (*KeyManager, error) {
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := newSigningKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyManager{current: key}, nil
+}
+
+// Sign signs claims with the current key and stamps its kid in the header
+func (km *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(km.current.method, claims)
+	token.Header["kid"] = km.current.kid
+	return token.SignedString(km.current.private)
+}
+
+// Keyfunc picks the verification key by kid and refuses tokens whose alg doesn't match that key
+func (km *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	// tokens of the old shared HS256 secret have no kid, they are refused and their users sign in again
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+
+	for _, key := range km.keys() {
+		if key.kid != kid {
+			continue
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return key.public, nil
+	}
+
+	return nil, fmt.Errorf("unknown kid %q", kid)
+}
+
+// JWKS returns the public keys other services need to verify our tokens
+func (km *KeyManager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range km.keys() {
+		set.Keys = append(set.Keys, key.jwk())
+	}
+	return set
+}
+
+func (km *KeyManager) keys() []*signingKey {
+	return append([]*signingKey{km.current}, km.previous...)
+}
+
+func (k *signingKey) jwk() JWK {
+	jwk := JWK{Kid: k.kid, Use: "sig", Alg: k.method.Alg()}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+// PublicKey decodes a key read from someone else's JWKS (OpenID providers)
+// RSA, Ed25519 and EC P-256 / P-384 keys are supported
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("bad Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("bad EC key size")
+		}
+		return ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// parseKey accepts PKCS#8 / PKCS#1 private keys and PKIX public keys
+func parseKey(data []byte) (*signingKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return newVerifyKey(public)
+	case "RSA PRIVATE KEY":
+		private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return newSigningKey(private)
+	case "PRIVATE KEY":
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key")
+		}
+		return newSigningKey(signer)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+}
+
+func newSigningKey(private crypto.Signer) (*signingKey, error) {
+	key, err := newVerifyKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.private = private
+	return key, nil
+}
+
+// newVerifyKey picks RS256 for RSA and EdDSA for Ed25519, other key types are refused
+func newVerifyKey(public crypto.PublicKey) (*signingKey, error) {
+	var method jwt.SigningMethod
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(der)
+
+	return &signingKey{
+		kid:    base64.RawURLEncoding.EncodeToString(sum[:12]),
+		method: method,
+		public: public,
+	}, nil
+}
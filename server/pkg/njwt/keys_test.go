@@ -0,0 +1,60 @@
+package njwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestKeyfunc(t *testing.T) {
+	km, err := NewEphemeralKeyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewEphemeralKeyManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims{"type": "access", "exp": time.Now().Add(time.Hour).Unix()}
+
+	current, err := km.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, err := other.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a token like the ones of the old shared secret, HS256 without a kid
+	sharedSecret, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("old shared secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// HS256 under the kid of the current key, the alg has to match the key the kid names
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	confused.Header["kid"] = km.current.kid
+	confusedString, err := confused.SignedString([]byte(km.current.kid))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "current key", token: current},
+		{name: "another key", token: unknown, wantErr: true},
+		{name: "old shared secret", token: sharedSecret, wantErr: true},
+		{name: "alg of the kid swapped", token: confusedString, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := jwt.ParseWithClaims(tt.token, jwt.MapClaims{}, km.Keyfunc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWithClaims() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
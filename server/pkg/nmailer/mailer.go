@@ -0,0 +1,15 @@
+// Package nmailer sends transactional mail (password reset, verification...)
+// through a pluggable Mailer so local runs don't need a real mail server
+package nmailer
+
+import "context"
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
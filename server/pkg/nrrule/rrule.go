@@ -0,0 +1,438 @@
+// Package nrrule implements the RFC 5545 recurrence rules (RRULE) todos repeat with
+// supported: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL, BYDAY (with
+// ordinals like 1MO / -1FR for MONTHLY and YEARLY), BYMONTHDAY, BYMONTH and WKST
+package nrrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods bounds the search for the next occurrence, a rule that can never
+// match (BYMONTH=2;BYMONTHDAY=30) ends there instead of looping forever
+const maxPeriods = 5000
+
+// WeekdayNum is one BYDAY entry, N is the ordinal inside the month / year (0 for every one)
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	// Count and Until both end the series, a rule has at most one of them
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+func parseWeekday(value string) (time.Weekday, error) {
+	for i, name := range weekdayNames {
+		if name == value {
+			return time.Weekday(i), nil
+		}
+	}
+	return 0, fmt.Errorf("nrrule: invalid weekday %q", value)
+}
+
+// parseUntil accepts the UTC, floating (read as UTC) and date only forms of UNTIL
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if until, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				// a date only UNTIL includes that whole day
+				until = until.Add(24*time.Hour - time.Second)
+			}
+			return until, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("nrrule: invalid UNTIL %q", value)
+}
+
+func parseInts(value string, min int, max int, name string) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < min || n > max || n == 0 {
+			return nil, fmt.Errorf("nrrule: invalid %s %q", name, part)
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
+
+// Parse reads a rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", an "RRULE:" prefix is allowed
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "RRULE:")
+	if value == "" {
+		return nil, errors.New("nrrule: empty rule")
+	}
+
+	rule := &Rule{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("nrrule: invalid part %q", part)
+		}
+
+		switch name {
+		case "FREQ":
+			switch Frequency(val) {
+			case Daily, Weekly, Monthly, Yearly:
+				rule.Freq = Frequency(val)
+			default:
+				return nil, fmt.Errorf("nrrule: unsupported FREQ %q", val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("nrrule: invalid INTERVAL %q", val)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("nrrule: invalid COUNT %q", val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				if len(day) < 2 {
+					return nil, fmt.Errorf("nrrule: invalid BYDAY %q", day)
+				}
+				weekday, err := parseWeekday(day[len(day)-2:])
+				if err != nil {
+					return nil, err
+				}
+				n := 0
+				if ordinal := day[:len(day)-2]; ordinal != "" {
+					if n, err = strconv.Atoi(ordinal); err != nil || n == 0 || n < -53 || n > 53 {
+						return nil, fmt.Errorf("nrrule: invalid BYDAY %q", day)
+					}
+				}
+				rule.ByDay = append(rule.ByDay, WeekdayNum{N: n, Weekday: weekday})
+			}
+		case "BYMONTHDAY":
+			days, err := parseInts(val, -31, 31, "BYMONTHDAY")
+			if err != nil {
+				return nil, err
+			}
+			rule.ByMonthDay = days
+		case "BYMONTH":
+			months, err := parseInts(val, 1, 12, "BYMONTH")
+			if err != nil {
+				return nil, err
+			}
+			for _, month := range months {
+				rule.ByMonth = append(rule.ByMonth, time.Month(month))
+			}
+		case "WKST":
+			weekday, err := parseWeekday(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.WeekStart = weekday
+		default:
+			return nil, fmt.Errorf("nrrule: unsupported part %q", name)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, errors.New("nrrule: FREQ is required")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return nil, errors.New("nrrule: COUNT and UNTIL can't be used together")
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return nil, errors.New("nrrule: BYDAY ordinals need FREQ=MONTHLY or YEARLY")
+		}
+	}
+	if rule.Freq == Yearly && len(rule.ByDay) > 0 && len(rule.ByMonth) == 0 {
+		return nil, errors.New("nrrule: BYDAY with FREQ=YEARLY needs BYMONTH")
+	}
+
+	return rule, nil
+}
+
+// String renders the rule in canonical order, this is what gets stored
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = weekdayNames[day.Weekday]
+			if day.N != 0 {
+				days[i] = strconv.Itoa(day.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, month := range r.ByMonth {
+			months[i] = strconv.Itoa(int(month))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayNames[r.WeekStart])
+	}
+	return strings.Join(parts, ";")
+}
+
+// After returns the first occurrence strictly after t of the series starting at dtstart,
+// false once the series ended (COUNT / UNTIL) before that
+// dtstart is always the first occurrence and occurrences keep its time of day and location
+func (r *Rule) After(dtstart time.Time, t time.Time) (time.Time, bool) {
+	t = t.In(dtstart.Location())
+
+	// without COUNT nothing has to be counted, so the periods before t are skipped
+	count := 1
+	first := 0
+	if r.Count == 0 {
+		first = max(r.periodsBetween(dtstart, t)-1, 0)
+	}
+
+	for period := first; period < first+maxPeriods; period++ {
+		for _, occurrence := range r.expand(dtstart, period) {
+			if !occurrence.After(dtstart) {
+				continue
+			}
+			if !r.Until.IsZero() && occurrence.After(r.Until) {
+				return time.Time{}, false
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return time.Time{}, false
+			}
+			if occurrence.After(t) {
+				return occurrence, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// civilDays counts calendar days, so DST changes don't shift the period math
+func civilDays(t time.Time) int {
+	return int(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func (r *Rule) periodsBetween(dtstart time.Time, t time.Time) int {
+	if t.Before(dtstart) {
+		return 0
+	}
+	switch r.Freq {
+	case Daily:
+		return (civilDays(t) - civilDays(dtstart)) / r.Interval
+	case Weekly:
+		return (civilDays(t) - civilDays(dtstart)) / 7 / r.Interval
+	case Monthly:
+		return ((t.Year()-dtstart.Year())*12 + int(t.Month()) - int(dtstart.Month())) / r.Interval
+	default:
+		return (t.Year() - dtstart.Year()) / r.Interval
+	}
+}
+
+// expand returns the occurrences of one period (day / week / month / year) in order
+func (r *Rule) expand(dtstart time.Time, period int) []time.Time {
+	year, month, day := dtstart.Date()
+	hour, minute, second := dtstart.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, minute, second, 0, dtstart.Location())
+	}
+
+	var occurrences []time.Time
+	switch r.Freq {
+	case Daily:
+		date := at(year, month, day+period*r.Interval)
+		if r.matchesMonth(date.Month()) && r.matchesMonthDay(date) && r.matchesWeekday(date.Weekday()) {
+			occurrences = append(occurrences, date)
+		}
+	case Weekly:
+		weekStart := day - (int(dtstart.Weekday())-int(r.WeekStart)+7)%7 + period*r.Interval*7
+		weekdays := []time.Weekday{dtstart.Weekday()}
+		if len(r.ByDay) > 0 {
+			weekdays = nil
+			for _, byDay := range r.ByDay {
+				weekdays = append(weekdays, byDay.Weekday)
+			}
+		}
+		for _, weekday := range weekdays {
+			date := at(year, month, weekStart+(int(weekday)-int(r.WeekStart)+7)%7)
+			if r.matchesMonth(date.Month()) {
+				occurrences = append(occurrences, date)
+			}
+		}
+	case Monthly:
+		first := at(year, month+time.Month(period*r.Interval), 1)
+		if r.matchesMonth(first.Month()) {
+			for _, d := range r.monthDays(first.Year(), first.Month(), day) {
+				occurrences = append(occurrences, at(first.Year(), first.Month(), d))
+			}
+		}
+	case Yearly:
+		y := year + period*r.Interval
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{month}
+			// BYMONTHDAY alone repeats in every month of the year
+			if len(r.ByMonthDay) > 0 {
+				months = []time.Month{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+			}
+		}
+		for _, m := range months {
+			for _, d := range r.monthDays(y, m, day) {
+				occurrences = append(occurrences, at(y, m, d))
+			}
+		}
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool { return occurrences[i].Before(occurrences[j]) })
+	return occurrences
+}
+
+// monthDays are the days of the month BYMONTHDAY / BYDAY pick, both set means both must match
+// without either it is the day of dtstart, skipped in months too short for it
+func (r *Rule) monthDays(year int, month time.Month, startDay int) []int {
+	lastDay := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if startDay > lastDay {
+			return nil
+		}
+		return []int{startDay}
+	}
+
+	picked := map[int]bool{}
+	if len(r.ByMonthDay) > 0 {
+		for _, d := range r.ByMonthDay {
+			if d < 0 {
+				d = lastDay + 1 + d
+			}
+			if d >= 1 && d <= lastDay {
+				picked[d] = true
+			}
+		}
+	}
+
+	if len(r.ByDay) > 0 {
+		byDay := map[int]bool{}
+		firstWeekday := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC).Weekday()
+		for _, wd := range r.ByDay {
+			var days []int
+			for d := 1 + (int(wd.Weekday)-int(firstWeekday)+7)%7; d <= lastDay; d += 7 {
+				days = append(days, d)
+			}
+			switch {
+			case wd.N == 0:
+				for _, d := range days {
+					byDay[d] = true
+				}
+			case wd.N > 0 && wd.N <= len(days):
+				byDay[days[wd.N-1]] = true
+			case wd.N < 0 && -wd.N <= len(days):
+				byDay[days[len(days)+wd.N]] = true
+			}
+		}
+
+		if len(r.ByMonthDay) == 0 {
+			picked = byDay
+		} else {
+			for d := range picked {
+				if !byDay[d] {
+					delete(picked, d)
+				}
+			}
+		}
+	}
+
+	days := make([]int, 0, len(picked))
+	for d := range picked {
+		days = append(days, d)
+	}
+	sort.Ints(days)
+	return days
+}
+
+func (r *Rule) matchesMonth(month time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesMonthDay(date time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	lastDay := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, d := range r.ByMonthDay {
+		if d == date.Day() || lastDay+1+d == date.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Rule) matchesWeekday(weekday time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, day := range r.ByDay {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package nrrule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("no time zone data for %s: %v", name, err)
+	}
+	return loc
+}
+
+// occurrences walks the series with After, at most limit occurrences past dtstart,
+// ended is true when the series ran out before that
+func occurrences(rule *Rule, dtstart time.Time, limit int) (dates []string, ended bool) {
+	t := dtstart
+	for len(dates) < limit {
+		next, ok := rule.After(dtstart, t)
+		if !ok {
+			return dates, true
+		}
+		dates = append(dates, next.Format(time.RFC3339))
+		t = next
+	}
+	return dates, false
+}
+
+func TestRuleAfter(t *testing.T) {
+	newYork := mustLocation(t, "America/New_York")
+
+	tests := []struct {
+		name    string
+		rule    string
+		dtstart time.Time
+		want    []string
+		// ended means the series stops right after want
+		ended bool
+	}{
+		{
+			name:    "31st of the month skips the short months",
+			rule:    "FREQ=MONTHLY",
+			dtstart: time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:    []string{"2026-03-31T09:00:00Z", "2026-05-31T09:00:00Z", "2026-07-31T09:00:00Z", "2026-08-31T09:00:00Z"},
+		},
+		{
+			name:    "BYMONTHDAY=-1 is the last day of every month",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=-1",
+			dtstart: time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:    []string{"2026-02-28T09:00:00Z", "2026-03-31T09:00:00Z", "2026-04-30T09:00:00Z"},
+		},
+		{
+			name:    "BYMONTHDAY=-1 in a leap year",
+			rule:    "FREQ=MONTHLY;BYMONTHDAY=-1",
+			dtstart: time.Date(2028, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:    []string{"2028-02-29T09:00:00Z", "2028-03-31T09:00:00Z"},
+		},
+		{
+			name:    "-1FR is the last friday of the month",
+			rule:    "FREQ=MONTHLY;BYDAY=-1FR",
+			dtstart: time.Date(2026, 1, 30, 17, 0, 0, 0, time.UTC),
+			want:    []string{"2026-02-27T17:00:00Z", "2026-03-27T17:00:00Z", "2026-04-24T17:00:00Z", "2026-05-29T17:00:00Z"},
+		},
+		{
+			name:    "2MO every other month",
+			rule:    "FREQ=MONTHLY;INTERVAL=2;BYDAY=2MO",
+			dtstart: time.Date(2026, 1, 12, 8, 0, 0, 0, time.UTC),
+			want:    []string{"2026-03-09T08:00:00Z", "2026-05-11T08:00:00Z"},
+		},
+		{
+			// RFC 5545 3.8.5.3, WKST changes which weeks are skipped
+			name:    "INTERVAL=2 with WKST=MO",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=MO",
+			dtstart: time.Date(1997, 8, 5, 9, 0, 0, 0, time.UTC),
+			want:    []string{"1997-08-10T09:00:00Z", "1997-08-19T09:00:00Z", "1997-08-24T09:00:00Z"},
+			ended:   true,
+		},
+		{
+			name:    "INTERVAL=2 with WKST=SU",
+			rule:    "FREQ=WEEKLY;INTERVAL=2;COUNT=4;BYDAY=TU,SU;WKST=SU",
+			dtstart: time.Date(1997, 8, 5, 9, 0, 0, 0, time.UTC),
+			want:    []string{"1997-08-17T09:00:00Z", "1997-08-19T09:00:00Z", "1997-08-31T09:00:00Z"},
+			ended:   true,
+		},
+		{
+			name:    "COUNT includes dtstart",
+			rule:    "FREQ=DAILY;COUNT=3",
+			dtstart: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+			want:    []string{"2026-03-02T09:00:00Z", "2026-03-03T09:00:00Z"},
+			ended:   true,
+		},
+		{
+			name:    "COUNT=1 has nothing after dtstart",
+			rule:    "FREQ=WEEKLY;COUNT=1",
+			dtstart: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+			want:    nil,
+			ended:   true,
+		},
+		{
+			name:    "a date only UNTIL includes that day",
+			rule:    "FREQ=WEEKLY;UNTIL=20260120",
+			dtstart: time.Date(2026, 1, 6, 18, 0, 0, 0, time.UTC),
+			want:    []string{"2026-01-13T18:00:00Z", "2026-01-20T18:00:00Z"},
+			ended:   true,
+		},
+		{
+			name:    "daily keeps the local time over the spring DST change",
+			rule:    "FREQ=DAILY",
+			dtstart: time.Date(2026, 3, 7, 9, 0, 0, 0, newYork),
+			want:    []string{"2026-03-08T09:00:00-04:00", "2026-03-09T09:00:00-04:00"},
+		},
+		{
+			name:    "weekly keeps the local time over the fall DST change",
+			rule:    "FREQ=WEEKLY;BYDAY=SA,SU",
+			dtstart: time.Date(2026, 10, 31, 9, 0, 0, 0, newYork),
+			want:    []string{"2026-11-01T09:00:00-05:00", "2026-11-07T09:00:00-05:00"},
+		},
+		{
+			name:    "yearly on february 29 waits for the next leap year",
+			rule:    "FREQ=YEARLY",
+			dtstart: time.Date(2024, 2, 29, 9, 0, 0, 0, time.UTC),
+			want:    []string{"2028-02-29T09:00:00Z", "2032-02-29T09:00:00Z"},
+		},
+		{
+			name:    "yearly last friday of november",
+			rule:    "FREQ=YEARLY;BYMONTH=11;BYDAY=-1FR",
+			dtstart: time.Date(2026, 11, 27, 9, 0, 0, 0, time.UTC),
+			want:    []string{"2027-11-26T09:00:00Z", "2028-11-24T09:00:00Z"},
+		},
+		{
+			name:    "a rule that never matches ends",
+			rule:    "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+			dtstart: time.Date(2026, 1, 30, 9, 0, 0, 0, time.UTC),
+			want:    nil,
+			ended:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.rule, err)
+			}
+			limit := len(tt.want)
+			if tt.ended {
+				limit++
+			}
+			got, ended := occurrences(rule, tt.dtstart, limit)
+			if !reflect.DeepEqual(got, tt.want) || ended != tt.ended {
+				t.Fatalf("occurrences = %v (ended %v), want %v (ended %v)", got, ended, tt.want, tt.ended)
+			}
+		})
+	}
+}
+
+func TestRuleAfterSkipsAhead(t *testing.T) {
+	rule, err := Parse("FREQ=WEEKLY;INTERVAL=3;BYDAY=MO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtstart := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	// a moment long after dtstart, the weeks before it aren't walked one by one
+	next, ok := rule.After(dtstart, time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC))
+	if want := time.Date(2030, 6, 10, 9, 0, 0, 0, time.UTC); !ok || !next.Equal(want) {
+		t.Fatalf("After() = %v, %v, want %v", next, ok, want)
+	}
+
+	// a moment before dtstart still starts counting at dtstart
+	next, ok = rule.After(dtstart, dtstart.Add(-48*time.Hour))
+	if want := time.Date(2026, 1, 26, 9, 0, 0, 0, time.UTC); !ok || !next.Equal(want) {
+		t.Fatalf("After() = %v, %v, want %v", next, ok, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{rule: "FREQ=DAILY", want: "FREQ=DAILY"},
+		{rule: "RRULE:freq=weekly;byday=mo,fr;interval=2;wkst=su", want: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;WKST=SU"},
+		{rule: "FREQ=MONTHLY;BYDAY=-1FR;COUNT=5", want: "FREQ=MONTHLY;COUNT=5;BYDAY=-1FR"},
+		{rule: "FREQ=MONTHLY;BYMONTHDAY=1,-1;INTERVAL=1", want: "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{rule: "FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=15;WKST=MO", want: "FREQ=YEARLY;BYMONTHDAY=15;BYMONTH=3,9"},
+		{rule: "FREQ=WEEKLY;UNTIL=20260120T100000Z;", want: "FREQ=WEEKLY;UNTIL=20260120T100000Z"},
+		{rule: "FREQ=WEEKLY;UNTIL=20260120", want: "FREQ=WEEKLY;UNTIL=20260120T235959Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := rule.String(); got != tt.want {
+				t.Fatalf("String() = %q, want %q", got, tt.want)
+			}
+			// the canonical form parses to the same rule
+			again, err := Parse(rule.String())
+			if err != nil || !reflect.DeepEqual(again, rule) {
+				t.Fatalf("Parse(String()) = %+v, %v, want %+v", again, err, rule)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"RRULE:",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;FREQ",
+		"FREQ=DAILY;COUNT=",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;INTERVAL=two",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20260101",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;BYDAY=XX",
+		"FREQ=DAILY;BYDAY=M",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO0",
+		"FREQ=MONTHLY;BYDAY=0MO",
+		"FREQ=MONTHLY;BYDAY=54MO",
+		"FREQ=YEARLY;BYDAY=MO",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;BYMONTHDAY=-32",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=WEEKLY;WKST=XX",
+		"FREQ=DAILY;BYHOUR=9",
+	}
+	for _, rule := range tests {
+		t.Run(rule, func(t *testing.T) {
+			if parsed, err := Parse(rule); err == nil {
+				t.Fatalf("Parse(%q) = %v, want an error", rule, parsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package ntotp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors, "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// rfcVectors are the SHA1 rows of RFC 6238 appendix B, the last 6 of the 8 digits
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestGenerateCode(t *testing.T) {
+	for _, tt := range rfcVectors {
+		got, err := GenerateCode(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("GenerateCode(%d) error = %v", tt.unix, err)
+		}
+		if got != tt.code {
+			t.Fatalf("GenerateCode(%d) = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+
+	// authenticator apps show the secret in lower case / with spaces around
+	if got, err := GenerateCode(" "+"gezdgnbvgy3tqojqgezdgnbvgy3tqojq"+" ", Step(time.Unix(59, 0))); err != nil || got != "287082" {
+		t.Fatalf("GenerateCode(lower case) = %s, %v", got, err)
+	}
+	if _, err := GenerateCode("not base32!", 1); err == nil {
+		t.Fatalf("GenerateCode(invalid secret) succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range rfcVectors {
+		now := time.Unix(tt.unix, 0)
+		step := Step(now)
+
+		tests := []struct {
+			name     string
+			at       time.Time
+			code     string
+			wantStep int64
+			wantOk   bool
+		}{
+			{name: "same step", at: now, code: tt.code, wantStep: step, wantOk: true},
+			{name: "spaces around", at: now, code: " " + tt.code + "\n", wantStep: step, wantOk: true},
+			{name: "one step later", at: now.Add(Period), code: tt.code, wantStep: step, wantOk: true},
+			{name: "one step earlier", at: now.Add(-Period), code: tt.code, wantStep: step, wantOk: true},
+			{name: "two steps later", at: now.Add(2 * Period), code: tt.code},
+			{name: "two steps earlier", at: now.Add(-2 * Period), code: tt.code},
+			{name: "wrong length", at: now, code: tt.code[:5]},
+			{name: "space inside", at: now, code: tt.code[:3] + " " + tt.code[3:]},
+		}
+		for _, c := range tests {
+			if c.at.Unix() < 0 {
+				// Step truncates toward zero, the epoch itself isn't a meaningful edge
+				continue
+			}
+			t.Run(tt.code+" "+c.name, func(t *testing.T) {
+				gotStep, ok := Validate(rfcSecret, c.code, c.at)
+				if ok != c.wantOk || (ok && gotStep != c.wantStep) {
+					t.Fatalf("Validate() = %d, %v, want %d, %v", gotStep, ok, c.wantStep, c.wantOk)
+				}
+			})
+		}
+	}
+}